@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/martinsdevv/aegis/internal/aegisctl"
+)
+
+func main() {
+	if err := aegisctl.Run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "aegisctl:", err)
+		os.Exit(1)
+	}
+}
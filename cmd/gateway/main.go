@@ -2,24 +2,33 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/martinsdevv/aegis/internal/certs"
 	"github.com/martinsdevv/aegis/internal/config"
 	"github.com/martinsdevv/aegis/internal/gateway/gtwhttp"
 	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
 	"github.com/martinsdevv/aegis/internal/health"
+	"github.com/martinsdevv/aegis/internal/metrics"
 	"github.com/martinsdevv/aegis/internal/seed"
+	"github.com/martinsdevv/aegis/internal/tracing"
+	"github.com/martinsdevv/aegis/internal/usage"
 )
 
 func main() {
@@ -31,11 +40,26 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// Tracing
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  "aegis-gateway",
+		Exporter:     cfg.AEGIS_TRACING_EXPORTER,
+		OTLPEndpoint: cfg.AEGIS_TRACING_OTLP_ENDPOINT,
+		File:         cfg.AEGIS_TRACING_FILE,
+		SampleRatio:  cfg.AEGIS_TRACING_SAMPLE_RATIO,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// DB
-	db, err := sql.Open("pgx", cfg.AEGIS_DATABASE_URL)
+	pgConfig, err := pgx.ParseConfig(cfg.AEGIS_DATABASE_URL)
 	if err != nil {
 		log.Fatal(err)
 	}
+	pgConfig.Tracer = metrics.PostgresTracer{}
+
+	db := stdlib.OpenDB(*pgConfig)
 	defer db.Close()
 
 	if err := db.PingContext(ctx); err != nil {
@@ -50,27 +74,209 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Sem AEGIS_ADMIN_TOKEN o token de bootstrap anterior é desativado
+	if err := seed.RunAdminSeed(ctx, db, cfg.AEGIS_ADMIN_TOKEN); err != nil {
+		log.Fatal(err)
+	}
+
 	healthCheck := health.New()
-	store := middleware.NewRLStore(5, 10, 30*time.Minute)
+	store := middleware.NewRLStore(cfg.AEGIS_RATELIMIT_MEMORY_TTL)
 	redisClient := middleware.NewRedisClient(cfg.AEGIS_REDIS_ADDR)
-	apiKeyStore := middleware.NewAPIKeyStore(db, redisClient, 60*time.Second)
+	limiter := middleware.NewRedisRateLimiter(redisClient, store)
+	usagePublisher, err := middleware.NewUsagePublisher(redisClient, cfg.AEGIS_USAGE_STREAM, cfg.AEGIS_USAGE_SPOOL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	usagePublisher.Start()
+	apiKeyStore := middleware.NewAPIKeyStore(db, redisClient, cfg.AEGIS_APIKEY_CACHE_TTL)
+	adminTokenStore := middleware.NewAdminTokenStore(db)
+	routeStore := proxy.NewRouteStore(db)
+	poolStore := proxy.NewPoolStore(db)
+	breakers := proxy.NewBreakers(breakerConfig(cfg))
+	retries := proxy.NewRetries(retryPolicy(cfg))
+	conns := middleware.NewConnLimiter()
+	transports := proxy.NewTransports(gtwhttp.UpstreamTransport(cfg), retries)
+	quotaMgr := middleware.NewQuotaManager(redisClient, cfg.AEGIS_DEFAULT_MONTHLY_QUOTA)
+	usageStore := usage.NewStore(db)
 
-	router := gtwhttp.NewRouter(healthCheck, cfg, store, redisClient, apiKeyStore)
+	if err := routeStore.Reload(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := poolStore.Reload(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// JWKS: arquivo local é obrigatório na subida; URL indisponível só adia a
+	// primeira carga para o próximo refresh
+	var jwks *middleware.JWKS
+	if slices.Contains(cfg.AEGIS_AUTH_METHODS, "jwt") {
+		jwks = middleware.NewJWKS(cfg.AEGIS_JWT_JWKS_URL, cfg.AEGIS_JWT_JWKS_FILE)
+		if err := jwks.Load(ctx); err != nil {
+			if cfg.AEGIS_JWT_JWKS_FILE != "" {
+				log.Fatal(err)
+			}
+			log.Printf("jwks load failed: %v", err)
+		}
+		go jwks.Run(ctx, cfg.AEGIS_JWT_JWKS_REFRESH_INTERVAL)
+	}
+
+	buildRouter := func(cfg config.Config) http.Handler {
+		return gtwhttp.NewRouter(healthCheck, cfg, limiter, conns, quotaMgr, redisClient, usagePublisher, apiKeyStore, jwks, routeStore, poolStore, breakers, transports, usageStore)
+	}
+	router := gtwhttp.NewReloadable(buildRouter(cfg))
+	adminRouter := gtwhttp.NewAdminRouter(apiKeyStore, routeStore, poolStore, breakers, adminTokenStore, middleware.EnabledAuthMethods(cfg, jwks))
 
 	server := &http.Server{
-		Addr:    ":" + cfg.AEGIS_LISTEN_PORT,
-		Handler: router,
+		Addr:              ":" + cfg.AEGIS_LISTEN_PORT,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.AEGIS_SERVER_READ_HEADER_TIMEOUT,
+		ReadTimeout:       cfg.AEGIS_SERVER_READ_TIMEOUT,
+		WriteTimeout:      cfg.AEGIS_SERVER_WRITE_TIMEOUT,
+		IdleTimeout:       cfg.AEGIS_SERVER_IDLE_TIMEOUT,
+		Protocols:         serverProtocols(cfg),
+	}
+
+	// HTTPS: com certificados configurados o listener HTTP pode virar só redirect
+	var tlsServer *http.Server
+	var certStore *certs.Store
+	if len(cfg.AEGIS_TLS_CERTS) > 0 {
+		pairs, err := certs.ParsePairs(cfg.AEGIS_TLS_CERTS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		certStore, err = certs.Load(certs.Options{
+			Pairs:         pairs,
+			ClientCAFiles: cfg.AEGIS_TLS_CLIENT_CA_FILES,
+			CRLFiles:      cfg.AEGIS_TLS_CLIENT_CRL_FILES,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig, err := certs.ServerConfig(certStore, cfg.AEGIS_TLS_MIN_VERSION, cfg.AEGIS_TLS_CIPHER_SUITES, cfg.AEGIS_TLS_CLIENT_AUTH)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		tlsServer = &http.Server{
+			Addr:              cfg.AEGIS_TLS_ADDR,
+			Handler:           router,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: cfg.AEGIS_SERVER_READ_HEADER_TIMEOUT,
+			ReadTimeout:       cfg.AEGIS_SERVER_READ_TIMEOUT,
+			WriteTimeout:      cfg.AEGIS_SERVER_WRITE_TIMEOUT,
+			IdleTimeout:       cfg.AEGIS_SERVER_IDLE_TIMEOUT,
+		}
+
+		if cfg.AEGIS_TLS_REDIRECT_HTTP {
+			_, tlsPort, _ := net.SplitHostPort(cfg.AEGIS_TLS_ADDR)
+			server.Handler = gtwhttp.NewHTTPSRedirect(tlsPort, certStore.Covers)
+		}
+	}
+
+	adminServer := &http.Server{
+		Addr:              cfg.AEGIS_ADMIN_ADDR,
+		Handler:           adminRouter,
+		ReadHeaderTimeout: cfg.AEGIS_SERVER_READ_HEADER_TIMEOUT,
+		IdleTimeout:       cfg.AEGIS_SERVER_IDLE_TIMEOUT,
+	}
+
+	// Métricas num listener interno, fora do tráfego dos consumidores
+	setLabelLimits(cfg)
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:              cfg.AEGIS_METRICS_ADDR,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: cfg.AEGIS_SERVER_READ_HEADER_TIMEOUT,
 	}
 
 	// Cleanup goroutine
 	go func() {
-		t := time.NewTicker(5 * time.Minute)
+		t := time.NewTicker(cfg.AEGIS_CLEANUP_INTERVAL)
 		defer t.Stop()
 		for range t.C {
 			store.Cleanup()
 		}
 	}()
 
+	// Recarrega rotas e pools alterados por outras réplicas
+	go func() {
+		t := time.NewTicker(cfg.AEGIS_RELOAD_INTERVAL)
+		defer t.Stop()
+		for range t.C {
+			if err := routeStore.Reload(ctx); err != nil {
+				log.Printf("route reload failed: %v", err)
+			}
+			if err := poolStore.Reload(ctx); err != nil {
+				log.Printf("pool reload failed: %v", err)
+			}
+		}
+	}()
+
+	// Reload da configuração por SIGHUP ou alteração do arquivo; a configuração
+	// atual fica num ponteiro atômico porque o shutdown também a lê
+	var current atomic.Pointer[config.Config]
+	current.Store(&cfg)
+
+	reloadCh := make(chan string, 1)
+	requestReload := func(reason string) {
+		select {
+		case reloadCh <- reason:
+		default:
+			// Já há um reload pendente, que vai ler o arquivo mais recente
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			requestReload("SIGHUP")
+		}
+	}()
+	go config.Watch(ctx, cfg.AEGIS_CONFIG_FILE, cfg.AEGIS_CONFIG_WATCH_INTERVAL, func() {
+		requestReload("file change")
+	})
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case reason := <-reloadCh:
+				prev := *current.Load()
+				next, err := config.Load()
+				if err != nil {
+					// Configuração inválida não substitui a atual
+					log.Printf("config reload (%s) rejected: %v", reason, err)
+					continue
+				}
+				if changed := config.RestartRequired(prev, next); len(changed) > 0 {
+					log.Printf("config reload (%s): changes to %s require a restart and were ignored", reason, strings.Join(changed, ", "))
+				}
+
+				setLabelLimits(next)
+				breakers.SetConfig(breakerConfig(next))
+				retries.SetPolicy(retryPolicy(next))
+				transports.SetDefaults(gtwhttp.UpstreamTransport(next))
+				quotaMgr.SetDefaultLimit(next.AEGIS_DEFAULT_MONTHLY_QUOTA)
+				router.Swap(buildRouter(next))
+				current.Store(&next)
+
+				if err := routeStore.Reload(ctx); err != nil {
+					log.Printf("route reload failed: %v", err)
+				}
+				if err := poolStore.Reload(ctx); err != nil {
+					log.Printf("pool reload failed: %v", err)
+				}
+				log.Printf("config reloaded (%s)", reason)
+			}
+		}
+	}()
+
+	// Health check ativo dos targets dos pools
+	go proxy.NewHealthChecker(poolStore).Run(ctx)
+
 	// Ready after boot
 	go func() {
 		time.Sleep(2 * time.Second)
@@ -85,16 +291,103 @@ func main() {
 		}
 	}()
 
+	// Start HTTPS server
+	if tlsServer != nil {
+		go certStore.Watch(ctx, cfg.AEGIS_TLS_CERT_WATCH_INTERVAL)
+		go func() {
+			log.Printf("Aegis listening on %s (TLS)", tlsServer.Addr)
+			if err := tlsServer.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("tls listen: %s\n", err)
+			}
+		}()
+	}
+
+	// Start admin server
+	go func() {
+		log.Printf("Aegis admin listening on %s", adminServer.Addr)
+		if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("admin listen: %s\n", err)
+		}
+	}()
+
+	// Start metrics server
+	go func() {
+		log.Printf("Aegis metrics listening on %s", metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("metrics listen: %s\n", err)
+		}
+	}()
+
 	// Wait for shutdown signal
 	<-ctx.Done()
 	log.Println("Shutdown signal received")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), current.Load().AEGIS_SHUTDOWN_TIMEOUT)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Server shutdown failed: %v\n", err)
 	}
 
+	if tlsServer != nil {
+		if err := tlsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("TLS server shutdown failed: %v\n", err)
+		}
+	}
+
+	if err := adminServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Admin server shutdown failed: %v\n", err)
+	}
+
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Metrics server shutdown failed: %v\n", err)
+	}
+
+	// Entrega (ou grava no spool) os eventos de uso das últimas requisições
+	if err := usagePublisher.Close(shutdownCtx); err != nil {
+		log.Printf("Usage publisher shutdown failed: %v\n", err)
+	}
+
+	// Exporta os spans que ainda estão no batch
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Tracing shutdown failed: %v\n", err)
+	}
+
 	log.Println("Aegis stopped gracefully")
 }
+
+func breakerConfig(cfg config.Config) proxy.BreakerConfig {
+	return proxy.BreakerConfig{
+		ConsecutiveFailures: cfg.AEGIS_BREAKER_CONSECUTIVE_FAILURES,
+		ErrorRate:           cfg.AEGIS_BREAKER_ERROR_RATE,
+		MinRequests:         cfg.AEGIS_BREAKER_MIN_REQUESTS,
+		Cooldown:            cfg.AEGIS_BREAKER_COOLDOWN,
+	}
+}
+
+// serverProtocols habilita HTTP/2 junto com HTTP/1.1; sem TLS o HTTP/2 é o
+// h2c com prior knowledge, usado pelos clientes gRPC
+func serverProtocols(cfg config.Config) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(cfg.AEGIS_HTTP2_CLEARTEXT)
+	return p
+}
+
+func retryPolicy(cfg config.Config) proxy.RetryPolicy {
+	return proxy.RetryPolicy{
+		MaxAttempts:        cfg.AEGIS_RETRY_MAX_ATTEMPTS,
+		RetryOn:            cfg.AEGIS_RETRY_ON_STATUS,
+		BackoffBase:        cfg.AEGIS_RETRY_BACKOFF_BASE,
+		BackoffMax:         cfg.AEGIS_RETRY_BACKOFF_MAX,
+		BudgetRatio:        cfg.AEGIS_RETRY_BUDGET_RATIO,
+		BudgetMinPerSecond: cfg.AEGIS_RETRY_BUDGET_MIN_PER_SECOND,
+	}
+}
+
+func setLabelLimits(cfg config.Config) {
+	for _, l := range []*metrics.LabelLimiter{metrics.APIKeyLabels, metrics.RouteLabels, metrics.UpstreamLabels} {
+		l.SetMax(cfg.AEGIS_METRICS_MAX_LABEL_VALUES)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/martinsdevv/aegis/internal/config"
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/seed"
+	"github.com/martinsdevv/aegis/internal/usage"
+)
+
+func main() {
+	cfg, err := config.LoadAggregator()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// DB
+	db, err := sql.Open("pgx", cfg.AEGIS_DATABASE_URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := seed.RunMigrations(cfg.AEGIS_DATABASE_URL); err != nil {
+		log.Fatal(err)
+	}
+
+	redisClient := middleware.NewRedisClient(cfg.AEGIS_REDIS_ADDR)
+	defer redisClient.Close()
+
+	consumer := usage.NewConsumer(redisClient, usage.NewStore(db), cfg.AEGIS_USAGE_STREAM, cfg.AEGIS_USAGE_GROUP, cfg.AEGIS_USAGE_CONSUMER)
+
+	log.Printf("Usage aggregator consuming %s as %s/%s", cfg.AEGIS_USAGE_STREAM, cfg.AEGIS_USAGE_GROUP, cfg.AEGIS_USAGE_CONSUMER)
+	if err := consumer.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Usage aggregator stopped")
+}
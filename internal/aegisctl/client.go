@@ -0,0 +1,173 @@
+package aegisctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key espelha a representação JSON de uma API Key na API admin
+type Key struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	KeyHash        string    `json:"key_hash"`
+	Key            string    `json:"key,omitempty"`
+	UpstreamHost   string    `json:"upstream_host"`
+	Active         bool      `json:"is_active"`
+	MonthlyQuota   int       `json:"monthly_quota"`
+	RateLimitRPS   float64   `json:"rate_limit_rps"`
+	RateLimitBurst int       `json:"rate_limit_burst"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Route espelha a representação JSON de uma rota na API admin
+type Route struct {
+	ID            int64    `json:"id"`
+	Name          string   `json:"name"`
+	PathPrefix    string   `json:"path_prefix"`
+	Methods       []string `json:"methods"`
+	Host          string   `json:"host"`
+	Upstream      string   `json:"upstream"`
+	StripPrefix   bool     `json:"strip_prefix"`
+	RewritePrefix string   `json:"rewrite_prefix"`
+	Active        bool     `json:"is_active"`
+}
+
+// APIError é uma resposta fora de 2xx da API admin
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, e.Status, e.Message)
+}
+
+// Client fala com o listener admin do gateway
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Client) CreateKey(ctx context.Context, body map[string]any) (*Key, error) {
+	var k Key
+	return &k, c.do(ctx, http.MethodPost, "/admin/keys", body, &k)
+}
+
+func (c *Client) ListKeys(ctx context.Context) ([]Key, error) {
+	var keys []Key
+	return keys, c.do(ctx, http.MethodGet, "/admin/keys", nil, &keys)
+}
+
+func (c *Client) GetKey(ctx context.Context, id int64) (*Key, error) {
+	var k Key
+	return &k, c.do(ctx, http.MethodGet, keyPath(id), nil, &k)
+}
+
+func (c *Client) UpdateKey(ctx context.Context, id int64, body map[string]any) (*Key, error) {
+	var k Key
+	return &k, c.do(ctx, http.MethodPatch, keyPath(id), body, &k)
+}
+
+func (c *Client) DeleteKey(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, keyPath(id), nil, nil)
+}
+
+func (c *Client) CreateRoute(ctx context.Context, body map[string]any) (*Route, error) {
+	var rt Route
+	return &rt, c.do(ctx, http.MethodPost, "/admin/routes", body, &rt)
+}
+
+func (c *Client) ListRoutes(ctx context.Context) ([]Route, error) {
+	var routes []Route
+	return routes, c.do(ctx, http.MethodGet, "/admin/routes", nil, &routes)
+}
+
+func (c *Client) UpdateRoute(ctx context.Context, id int64, body map[string]any) (*Route, error) {
+	var rt Route
+	return &rt, c.do(ctx, http.MethodPatch, routePath(id), body, &rt)
+}
+
+func (c *Client) DeleteRoute(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, routePath(id), nil, nil)
+}
+
+func (c *Client) GrantRoute(ctx context.Context, routeID, keyID int64) error {
+	return c.do(ctx, http.MethodPut, routePath(routeID)+"/keys/"+strconv.FormatInt(keyID, 10), nil, nil)
+}
+
+func (c *Client) RevokeRoute(ctx context.Context, routeID, keyID int64) error {
+	return c.do(ctx, http.MethodDelete, routePath(routeID)+"/keys/"+strconv.FormatInt(keyID, 10), nil, nil)
+}
+
+func (c *Client) InvalidateCache(ctx context.Context, hash string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/cache/apikey?hash="+url.QueryEscape(hash), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return &APIError{Method: method, Path: path, StatusCode: res.StatusCode, Status: res.Status, Message: strings.TrimSpace(string(msg))}
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func keyPath(id int64) string {
+	return "/admin/keys/" + strconv.FormatInt(id, 10)
+}
+
+func routePath(id int64) string {
+	return "/admin/routes/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,533 @@
+package aegisctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+const usage = `usage: aegisctl <command> [flags]
+
+commands:
+  hash <raw-key>                    SHA256 de uma chave, como gravado no banco
+  keys create -name N [-upstream U] [-quota Q] [-rps R] [-burst B]
+  keys list
+  keys get <id>
+  keys update <id> [-name N] [-upstream U] [-quota Q] [-rps R] [-burst B] [-active=true|false]
+  keys delete <id>
+  routes create -name N -prefix P -upstream U [-methods GET,POST] [-host H] [-strip] [-rewrite R]
+  routes list
+  routes update <id> [-name N] [-prefix P] [-upstream U] [-methods GET,POST] [-host H] [-strip=true|false] [-rewrite R] [-active=true|false]
+  routes delete <id>
+  routes grant <route-id> <key-id>
+  routes revoke <route-id> <key-id>
+  quota set <id> <monthly-quota>
+  usage <id> [-month YYYY-MM]       consumo do mês lido do contador quota:<id>:<YYYY-MM>
+  cache invalidate <hash>           remove a chave do cache Redis do gateway
+
+global flags (env):
+  -addr   endereço da API admin (AEGIS_ADMIN_ADDR, padrão 127.0.0.1:8001)
+  -token  token de operador (AEGIS_ADMIN_TOKEN)
+  -redis  endereço do Redis para "usage" (AEGIS_REDIS_ADDR, padrão localhost:6379)
+  -o      formato de saída: table ou json (padrão table)
+`
+
+var errUsage = errors.New("invalid usage")
+
+type globals struct {
+	addr      string
+	token     string
+	redisAddr string
+	output    string
+	out       io.Writer
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.addr, "addr", envOr("AEGIS_ADMIN_ADDR", "127.0.0.1:8001"), "admin API address")
+	fs.StringVar(&g.token, "token", os.Getenv("AEGIS_ADMIN_TOKEN"), "operator token")
+	fs.StringVar(&g.redisAddr, "redis", envOr("AEGIS_REDIS_ADDR", "localhost:6379"), "redis address")
+	fs.StringVar(&g.output, "o", "table", "output format: table or json")
+}
+
+func (g *globals) client() *Client {
+	return NewClient(g.addr, g.token)
+}
+
+// Run executa o comando descrito por args e escreve o resultado em out
+func Run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errUsage
+	}
+
+	g := &globals{out: out}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "hash":
+		return runHash(g, args[1:])
+	case "keys":
+		if len(args) < 2 {
+			fmt.Fprint(out, usage)
+			return errUsage
+		}
+		switch args[1] {
+		case "create":
+			return runKeysCreate(ctx, g, args[2:])
+		case "list":
+			return runKeysList(ctx, g, args[2:])
+		case "get":
+			return runKeysGet(ctx, g, args[2:])
+		case "update":
+			return runKeysUpdate(ctx, g, args[2:])
+		case "delete":
+			return runKeysDelete(ctx, g, args[2:])
+		}
+	case "routes":
+		if len(args) < 2 {
+			fmt.Fprint(out, usage)
+			return errUsage
+		}
+		switch args[1] {
+		case "create":
+			return runRoutesCreate(ctx, g, args[2:])
+		case "list":
+			return runRoutesList(ctx, g, args[2:])
+		case "update":
+			return runRoutesUpdate(ctx, g, args[2:])
+		case "delete":
+			return runRoutesDelete(ctx, g, args[2:])
+		case "grant", "revoke":
+			return runRoutesGrant(ctx, g, args[1], args[2:])
+		}
+	case "quota":
+		if len(args) >= 2 && args[1] == "set" {
+			return runQuotaSet(ctx, g, args[2:])
+		}
+	case "usage":
+		return runUsage(ctx, g, args[1:])
+	case "cache":
+		if len(args) >= 2 && args[1] == "invalidate" {
+			return runCacheInvalidate(ctx, g, args[2:])
+		}
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	}
+
+	fmt.Fprint(out, usage)
+	return errUsage
+}
+
+func runHash(g *globals, args []string) error {
+	fs := newFlagSet("hash", g)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	hash := middleware.HashKey(pos[0])
+	if g.output == "json" {
+		return printJSON(g.out, map[string]string{"hash": hash})
+	}
+	_, err = fmt.Fprintln(g.out, hash)
+	return err
+}
+
+func runKeysCreate(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("keys create", g)
+	name := fs.String("name", "", "key name")
+	upstream := fs.String("upstream", "", "upstream host")
+	quota := fs.Int("quota", 0, "monthly quota")
+	rps := fs.Float64("rps", 0, "rate limit (requests per second)")
+	burst := fs.Int("burst", 0, "rate limit burst")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("keys create: -name is required")
+	}
+
+	k, err := g.client().CreateKey(ctx, map[string]any{
+		"name":             *name,
+		"upstream_host":    *upstream,
+		"monthly_quota":    *quota,
+		"rate_limit_rps":   *rps,
+		"rate_limit_burst": *burst,
+	})
+	if err != nil {
+		return err
+	}
+	return printKey(g.out, g.output, *k)
+}
+
+func runKeysList(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("keys list", g)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	keys, err := g.client().ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return printKeys(g.out, g.output, keys)
+}
+
+func runKeysGet(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("keys get", g)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+
+	k, err := g.client().GetKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printKey(g.out, g.output, *k)
+}
+
+func runKeysUpdate(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("keys update", g)
+	fs.String("name", "", "key name")
+	fs.String("upstream", "", "upstream host")
+	fs.Int("quota", 0, "monthly quota")
+	fs.Float64("rps", 0, "rate limit (requests per second)")
+	fs.Int("burst", 0, "rate limit burst")
+	fs.Bool("active", true, "whether the key is active")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+
+	// Só envia os campos informados explicitamente
+	fields := map[string]string{
+		"name":     "name",
+		"upstream": "upstream_host",
+		"quota":    "monthly_quota",
+		"rps":      "rate_limit_rps",
+		"burst":    "rate_limit_burst",
+		"active":   "is_active",
+	}
+	body := map[string]any{}
+	fs.Visit(func(f *flag.Flag) {
+		if field, ok := fields[f.Name]; ok {
+			body[field] = f.Value.(flag.Getter).Get()
+		}
+	})
+	if len(body) == 0 {
+		return fmt.Errorf("keys update: nothing to update")
+	}
+
+	k, err := g.client().UpdateKey(ctx, id, body)
+	if err != nil {
+		return err
+	}
+	return printKey(g.out, g.output, *k)
+}
+
+func runKeysDelete(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("keys delete", g)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+
+	if err := g.client().DeleteKey(ctx, id); err != nil {
+		return err
+	}
+	return printStatus(g.out, g.output, "deleted", id)
+}
+
+func runRoutesCreate(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("routes create", g)
+	name := fs.String("name", "", "route name")
+	prefix := fs.String("prefix", "", "path prefix")
+	upstream := fs.String("upstream", "", "upstream target")
+	methods := fs.String("methods", "", "comma separated methods (empty accepts all)")
+	host := fs.String("host", "", "host match (empty accepts any)")
+	strip := fs.Bool("strip", false, "strip the prefix before forwarding")
+	rewrite := fs.String("rewrite", "", "replace the prefix before forwarding")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" || *prefix == "" || *upstream == "" {
+		return fmt.Errorf("routes create: -name, -prefix and -upstream are required")
+	}
+
+	var methodList []string
+	for _, m := range strings.Split(*methods, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methodList = append(methodList, m)
+		}
+	}
+
+	rt, err := g.client().CreateRoute(ctx, map[string]any{
+		"name":           *name,
+		"path_prefix":    *prefix,
+		"upstream":       *upstream,
+		"methods":        methodList,
+		"host":           *host,
+		"strip_prefix":   *strip,
+		"rewrite_prefix": *rewrite,
+	})
+	if err != nil {
+		return err
+	}
+	return printRoute(g.out, g.output, *rt)
+}
+
+func runRoutesUpdate(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("routes update", g)
+	fs.String("name", "", "route name")
+	fs.String("prefix", "", "path prefix")
+	fs.String("upstream", "", "upstream target")
+	fs.String("methods", "", "comma separated methods (empty accepts all)")
+	fs.String("host", "", "host match (empty accepts any)")
+	fs.Bool("strip", false, "strip the prefix before forwarding")
+	fs.String("rewrite", "", "replace the prefix before forwarding")
+	fs.Bool("active", true, "whether the route is active")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+
+	// Só envia os campos informados explicitamente; as concessões da rota não mudam
+	fields := map[string]string{
+		"name":     "name",
+		"prefix":   "path_prefix",
+		"upstream": "upstream",
+		"host":     "host",
+		"strip":    "strip_prefix",
+		"rewrite":  "rewrite_prefix",
+		"active":   "is_active",
+	}
+	body := map[string]any{}
+	fs.Visit(func(f *flag.Flag) {
+		if field, ok := fields[f.Name]; ok {
+			body[field] = f.Value.(flag.Getter).Get()
+		}
+		if f.Name == "methods" {
+			methods := []string{}
+			for _, m := range strings.Split(f.Value.String(), ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					methods = append(methods, m)
+				}
+			}
+			body["methods"] = methods
+		}
+	})
+	if len(body) == 0 {
+		return fmt.Errorf("routes update: nothing to update")
+	}
+
+	rt, err := g.client().UpdateRoute(ctx, id, body)
+	if err != nil {
+		return err
+	}
+	return printRoute(g.out, g.output, *rt)
+}
+
+func runRoutesList(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("routes list", g)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	routes, err := g.client().ListRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	return printRoutes(g.out, g.output, routes)
+}
+
+func runRoutesDelete(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("routes delete", g)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+
+	if err := g.client().DeleteRoute(ctx, id); err != nil {
+		return err
+	}
+	return printStatus(g.out, g.output, "deleted", id)
+}
+
+func runRoutesGrant(ctx context.Context, g *globals, action string, args []string) error {
+	fs := newFlagSet("routes "+action, g)
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	routeID, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+	keyID, err := parseID(pos[1])
+	if err != nil {
+		return err
+	}
+
+	if action == "grant" {
+		err = g.client().GrantRoute(ctx, routeID, keyID)
+	} else {
+		err = g.client().RevokeRoute(ctx, routeID, keyID)
+	}
+	if err != nil {
+		return err
+	}
+	return printStatus(g.out, g.output, action+"ed", fmt.Sprintf("route %d key %d", routeID, keyID))
+}
+
+func runQuotaSet(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("quota set", g)
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+	quota, err := strconv.Atoi(pos[1])
+	if err != nil || quota <= 0 {
+		return fmt.Errorf("quota set: invalid quota %q", pos[1])
+	}
+
+	k, err := g.client().UpdateKey(ctx, id, map[string]any{"monthly_quota": quota})
+	if err != nil {
+		return err
+	}
+	return printKey(g.out, g.output, *k)
+}
+
+func runUsage(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("usage", g)
+	month := fs.String("month", time.Now().UTC().Format("2006-01"), "month in YYYY-MM")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(pos[0])
+	if err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01", *month); err != nil {
+		return fmt.Errorf("usage: invalid month %q", *month)
+	}
+
+	rdb := middleware.NewRedisClient(g.redisAddr)
+	defer rdb.Close()
+
+	used, err := rdb.Get(ctx, middleware.QuotaKey(id, *month)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	u := Usage{APIKeyID: id, Month: *month, Used: used}
+
+	// O limite vem da API admin; sem ela (-addr vazio) ou sem a chave mostramos
+	// apenas o contador. Token recusado ou falha de rede são erros
+	if g.addr != "" {
+		k, err := g.client().GetKey(ctx, id)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			limit := int64(k.MonthlyQuota)
+			remaining := max(limit-used, 0)
+			u.Limit, u.Remaining = &limit, &remaining
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		default:
+			return err
+		}
+	}
+
+	return printUsage(g.out, g.output, u)
+}
+
+func runCacheInvalidate(ctx context.Context, g *globals, args []string) error {
+	fs := newFlagSet("cache invalidate", g)
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := g.client().InvalidateCache(ctx, pos[0]); err != nil {
+		return err
+	}
+	return printStatus(g.out, g.output, "invalidated", pos[0])
+}
+
+func newFlagSet(name string, g *globals) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(g.out)
+	g.register(fs)
+	return fs
+}
+
+// parseArgs aceita flags antes e depois dos argumentos posicionais
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(pos) != want {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d", fs.Name(), want, len(pos))
+	}
+	return pos, nil
+}
+
+func parseID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %q", raw)
+	}
+	return id, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
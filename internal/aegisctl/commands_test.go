@@ -0,0 +1,187 @@
+package aegisctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		gotAuth string
+		gotBody map[string]any
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(Key{ID: 7, Name: "billing", Key: "aegis_raw", Active: true})
+	})
+	mux.HandleFunc("PATCH /admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		gotBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		_ = json.NewEncoder(w).Encode(Key{ID: 7, Name: "billing"})
+	})
+	mux.HandleFunc("PATCH /admin/routes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		gotBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		_ = json.NewEncoder(w).Encode(Route{ID: 3, Name: "billing"})
+	})
+	mux.HandleFunc("GET /admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "api key not found", http.StatusNotFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	t.Run("hash prints sha256", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run([]string{"hash", "DEV_KEY_123"}, &out); err != nil {
+			t.Fatal(err)
+		}
+		want := "5930ad78675e1b9b96cddd1470047b98a2660318679c63ca1af17ba32d8aa89e\n"
+		if out.String() != want {
+			t.Fatalf("expected %q, got %q", want, out.String())
+		}
+	})
+
+	t.Run("keys create returns raw key once", func(t *testing.T) {
+		var out bytes.Buffer
+		err := Run([]string{"keys", "create", "-addr", srv.URL, "-token", "op", "-name", "billing", "-quota", "500", "-o", "json"}, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if gotAuth != "Bearer op" {
+			t.Fatalf("expected bearer token, got %q", gotAuth)
+		}
+		if gotBody["name"] != "billing" || gotBody["monthly_quota"] != float64(500) {
+			t.Fatalf("unexpected request body: %v", gotBody)
+		}
+
+		var k Key
+		if err := json.Unmarshal(out.Bytes(), &k); err != nil {
+			t.Fatalf("expected json output: %v\n%s", err, out.String())
+		}
+		if k.Key != "aegis_raw" {
+			t.Fatalf("expected raw key in output, got %q", k.Key)
+		}
+	})
+
+	t.Run("keys update sends only explicit flags", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run([]string{"keys", "update", "7", "-addr", srv.URL, "-active=false"}, &out); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(gotBody) != 1 || gotBody["is_active"] != false {
+			t.Fatalf("expected only is_active=false, got %v", gotBody)
+		}
+		if !strings.HasPrefix(out.String(), "ID") {
+			t.Fatalf("expected table output, got %q", out.String())
+		}
+	})
+
+	t.Run("routes update sends only explicit flags", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := Run([]string{"routes", "update", "3", "-addr", srv.URL, "-active=false", "-methods", "get, post"}, &out); err != nil {
+			t.Fatal(err)
+		}
+
+		methods, _ := gotBody["methods"].([]any)
+		if len(gotBody) != 2 || gotBody["is_active"] != false || len(methods) != 2 || methods[0] != "get" {
+			t.Fatalf("expected only is_active and methods, got %v", gotBody)
+		}
+	})
+
+	t.Run("api errors are surfaced", func(t *testing.T) {
+		var out bytes.Buffer
+		err := Run([]string{"keys", "get", "99", "-addr", srv.URL}, &out)
+		if err == nil || !strings.Contains(err.Error(), "404") {
+			t.Fatalf("expected 404 error, got %v", err)
+		}
+	})
+}
+
+func TestPrintUsage(t *testing.T) {
+	limit, remaining := int64(100), int64(0)
+
+	var out bytes.Buffer
+	if err := printUsage(&out, "json", Usage{APIKeyID: 7, Month: "2026-03", Used: 120, Limit: &limit, Remaining: &remaining}); err != nil {
+		t.Fatal(err)
+	}
+	// Quota esgotada aparece como remaining 0, não some da saída
+	if !strings.Contains(out.String(), `"remaining": 0`) || !strings.Contains(out.String(), `"limit": 100`) {
+		t.Fatalf("expected limit and remaining in the output, got %s", out.String())
+	}
+
+	out.Reset()
+	if err := printUsage(&out, "json", Usage{APIKeyID: 7, Month: "2026-03", Used: 120}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "remaining") || strings.Contains(out.String(), "limit") {
+		t.Fatalf("expected an unknown limit to be omitted, got %s", out.String())
+	}
+
+	out.Reset()
+	if err := printUsage(&out, "table", Usage{APIKeyID: 7, Month: "2026-03", Used: 120}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got := strings.Join(strings.Fields(lines[len(lines)-1]), " "); got != "7 2026-03 120 - -" {
+		t.Fatalf("expected dashes for an unknown limit, got %q", out.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := mr.Set(middleware.QuotaKey(7, "2026-03"), "120"); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/keys/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer op" {
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Key{ID: 7, MonthlyQuota: 100})
+	})
+	mux.HandleFunc("GET /admin/keys/8", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "api key not found", http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	run := func(id, addr, token string) (Usage, error) {
+		var out bytes.Buffer
+		err := Run([]string{"usage", id, "-month", "2026-03", "-redis", mr.Addr(), "-addr", addr, "-token", token, "-o", "json"}, &out)
+		var u Usage
+		if err == nil {
+			err = json.Unmarshal(out.Bytes(), &u)
+		}
+		return u, err
+	}
+
+	if u, err := run("7", srv.URL, "op"); err != nil || u.Limit == nil || *u.Limit != 100 || *u.Remaining != 0 {
+		t.Fatalf("expected the limit from the admin API, got %+v (%v)", u, err)
+	}
+	// Sem API admin ou sem a chave fica só o contador
+	for _, c := range []struct{ id, addr string }{{"7", ""}, {"8", srv.URL}} {
+		if u, err := run(c.id, c.addr, "op"); err != nil || u.Limit != nil {
+			t.Fatalf("usage %s -addr %q: expected the counter only, got %+v (%v)", c.id, c.addr, u, err)
+		}
+	}
+	// Token recusado não pode passar por "sem limite"
+	if _, err := run("7", srv.URL, "wrong"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected the 401 to be reported, got %v", err)
+	}
+}
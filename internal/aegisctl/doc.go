@@ -0,0 +1,2 @@
+// Package aegisctl implements the operator command-line tool that drives the gateway admin API
+package aegisctl
@@ -0,0 +1,115 @@
+package aegisctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage é o consumo mensal de uma API Key; Limit e Remaining ficam nil quando
+// a API admin não responde, para não confundir com uma quota esgotada
+type Usage struct {
+	APIKeyID  int64  `json:"api_key_id"`
+	Month     string `json:"month"`
+	Used      int64  `json:"used"`
+	Limit     *int64 `json:"limit,omitempty"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printKey(w io.Writer, format string, k Key) error {
+	if format == "json" {
+		return printJSON(w, k)
+	}
+	return printKeyTable(w, k.Key != "", []Key{k})
+}
+
+func printKeys(w io.Writer, format string, keys []Key) error {
+	if format == "json" {
+		return printJSON(w, keys)
+	}
+	return printKeyTable(w, false, keys)
+}
+
+func printKeyTable(w io.Writer, withRaw bool, keys []Key) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "ID\tNAME\tUPSTREAM\tACTIVE\tQUOTA\tRPS\tBURST\tCREATED"
+	if withRaw {
+		header += "\tKEY"
+	}
+	fmt.Fprintln(tw, header)
+
+	for _, k := range keys {
+		line := fmt.Sprintf("%d\t%s\t%s\t%t\t%d\t%s\t%d\t%s",
+			k.ID, k.Name, k.UpstreamHost, k.Active, k.MonthlyQuota,
+			strconv.FormatFloat(k.RateLimitRPS, 'f', -1, 64), k.RateLimitBurst,
+			k.CreatedAt.Format(time.RFC3339),
+		)
+		if withRaw {
+			line += "\t" + k.Key
+		}
+		fmt.Fprintln(tw, line)
+	}
+
+	return tw.Flush()
+}
+
+func printRoute(w io.Writer, format string, rt Route) error {
+	if format == "json" {
+		return printJSON(w, rt)
+	}
+	return printRoutes(w, format, []Route{rt})
+}
+
+func printRoutes(w io.Writer, format string, routes []Route) error {
+	if format == "json" {
+		return printJSON(w, routes)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tMETHODS\tHOST\tUPSTREAM\tREWRITE\tACTIVE")
+	for _, rt := range routes {
+		rewrite := rt.RewritePrefix
+		if rewrite == "" && rt.StripPrefix {
+			rewrite = "(strip)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n",
+			rt.ID, rt.Name, rt.PathPrefix, strings.Join(rt.Methods, ","), rt.Host, rt.Upstream, rewrite, rt.Active,
+		)
+	}
+	return tw.Flush()
+}
+
+func printUsage(w io.Writer, format string, u Usage) error {
+	if format == "json" {
+		return printJSON(w, u)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "API_KEY_ID\tMONTH\tUSED\tLIMIT\tREMAINING")
+	optional := func(v *int64) string {
+		if v == nil {
+			return "-"
+		}
+		return strconv.FormatInt(*v, 10)
+	}
+	fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", u.APIKeyID, u.Month, u.Used, optional(u.Limit), optional(u.Remaining))
+	return tw.Flush()
+}
+
+func printStatus(w io.Writer, format, status string, target any) error {
+	if format == "json" {
+		return printJSON(w, map[string]any{"status": status, "target": target})
+	}
+	_, err := fmt.Fprintf(w, "%s %v\n", status, target)
+	return err
+}
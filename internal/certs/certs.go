@@ -0,0 +1,201 @@
+package certs
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pair é um certificado (com a cadeia) e a chave privada correspondente
+type Pair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// ParsePair lê o formato "cert.pem:key.pem" usado na configuração
+func ParsePair(s string) (Pair, error) {
+	certFile, keyFile, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(certFile) == "" || strings.TrimSpace(keyFile) == "" {
+		return Pair{}, fmt.Errorf("certificate must be cert_file:key_file, got %q", s)
+	}
+	return Pair{CertFile: strings.TrimSpace(certFile), KeyFile: strings.TrimSpace(keyFile)}, nil
+}
+
+func ParsePairs(list []string) ([]Pair, error) {
+	pairs := make([]Pair, 0, len(list))
+	for _, s := range list {
+		p, err := ParsePair(s)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
+
+// Options são os arquivos lidos pelo Store. Com ClientCAFiles o listener
+// verifica certificados de cliente (mTLS); CRLFiles revogam certificados
+// emitidos por essas CAs
+type Options struct {
+	Pairs         []Pair
+	ClientCAFiles []string
+	CRLFiles      []string
+}
+
+// Store guarda os certificados carregados; o handshake lê sempre a versão mais
+// recente e as conexões abertas continuam com a que negociaram
+type Store struct {
+	opts    Options
+	mu      sync.Mutex // serializa os Reload
+	current atomic.Pointer[snapshot]
+	now     func() time.Time
+}
+
+type snapshot struct {
+	certs     []*tls.Certificate
+	clientCAs *x509.CertPool
+	revoked   map[string]bool      // emissor + serial
+	crlExpiry map[string]time.Time // NextUpdate da CRL de cada emissor
+}
+
+// Load carrega todos os arquivos; o primeiro par é o padrão para clientes sem SNI
+func Load(opts Options) (*Store, error) {
+	if len(opts.Pairs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+	if len(opts.CRLFiles) > 0 && len(opts.ClientCAFiles) == 0 {
+		return nil, errors.New("crl files require client ca files")
+	}
+
+	s := &Store{opts: opts, now: time.Now}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload relê os arquivos; se algum falhar os certificados atuais continuam valendo
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := &snapshot{}
+	for _, p := range s.opts.Pairs {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate %s: %w", p.CertFile, err)
+		}
+		snap.certs = append(snap.certs, &cert)
+	}
+
+	if len(s.opts.ClientCAFiles) > 0 {
+		cas, err := loadCAs(s.opts.ClientCAFiles)
+		if err != nil {
+			return err
+		}
+		snap.clientCAs = x509.NewCertPool()
+		for _, ca := range cas {
+			snap.clientCAs.AddCert(ca)
+		}
+		if snap.revoked, snap.crlExpiry, err = loadCRLs(s.opts.CRLFiles, cas, s.now()); err != nil {
+			return err
+		}
+	}
+
+	s.current.Store(snap)
+	return nil
+}
+
+// GetCertificate escolhe o primeiro certificado que atende o SNI e os
+// algoritmos do cliente; sem nenhum compatível devolve o padrão
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	loaded := s.current.Load().certs
+	for _, cert := range loaded {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	return loaded[0], nil
+}
+
+// Covers informa se algum certificado carregado vale para host (nome ou IP)
+func (s *Store) Covers(host string) bool {
+	for _, cert := range s.current.Load().certs {
+		leaf := cert.Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				continue
+			}
+		}
+		if leaf.VerifyHostname(host) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch verifica os arquivos a cada interval e recarrega quando o conteúdo
+// muda. Como em config.Watch, compara o hash para funcionar com a troca de
+// symlink dos Secrets montados pelo cert-manager
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	last, _ := s.hash()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			sum, err := s.hash()
+			if err != nil || sum == last {
+				continue
+			}
+			// Certificado e chave podem ser trocados em momentos diferentes: sem
+			// atualizar o hash, a carga é tentada de novo no próximo tick
+			if err := s.Reload(); err != nil {
+				slog.Warn("tls certificate reload failed, keeping current certificates", "err", err)
+				continue
+			}
+			last = sum
+			slog.Info("tls certificates reloaded", "certificates", len(s.opts.Pairs))
+		}
+	}
+}
+
+func (s *Store) hash() ([sha256.Size]byte, error) {
+	var paths []string
+	for _, p := range s.opts.Pairs {
+		paths = append(paths, p.CertFile, p.KeyFile)
+	}
+	paths = append(paths, s.opts.ClientCAFiles...)
+	paths = append(paths, s.opts.CRLFiles...)
+
+	h := sha256.New()
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		h.Write(b)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,137 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair gera um certificado autoassinado para os nomes e grava cert e chave em dir
+func writePair(t *testing.T, dir, name string, dnsNames ...string) Pair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Pair{CertFile: filepath.Join(dir, name+".pem"), KeyFile: filepath.Join(dir, name+"-key.pem")}
+	if err := os.WriteFile(p.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func servedName(t *testing.T, s *Store, sni string) string {
+	t.Helper()
+
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{
+		ServerName:        sni,
+		SupportedVersions: []uint16{tls.VersionTLS13},
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestGetCertificateBySNI(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(Options{Pairs: []Pair{
+		writePair(t, dir, "api", "api.example.com"),
+		writePair(t, dir, "wildcard", "*.apps.example.com"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for sni, want := range map[string]string{
+		"api.example.com":         "api.example.com",
+		"orders.apps.example.com": "*.apps.example.com",
+		"":                        "api.example.com", // sem SNI usa o padrão
+		"unknown.example.org":     "api.example.com",
+	} {
+		if got := servedName(t, s, sni); got != want {
+			t.Fatalf("sni %q: expected %s, got %s", sni, want, got)
+		}
+	}
+
+	for host, want := range map[string]bool{
+		"api.example.com":         true,
+		"orders.apps.example.com": true,
+		"apps.example.com":        false,
+		"evil.example.org":        false,
+	} {
+		if got := s.Covers(host); got != want {
+			t.Fatalf("covers %q: expected %v, got %v", host, want, got)
+		}
+	}
+}
+
+func TestWatchReloadsRotatedCertificates(t *testing.T) {
+	dir := t.TempDir()
+	pair := writePair(t, dir, "api", "api.example.com")
+	s, err := Load(Options{Pairs: []Pair{pair}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, 10*time.Millisecond)
+
+	// Chave que não corresponde ao certificado: mantém o atual
+	other := writePair(t, t.TempDir(), "other", "rotated.example.com")
+	key, _ := os.ReadFile(other.KeyFile)
+	if err := os.WriteFile(pair.KeyFile, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := servedName(t, s, ""); got != "api.example.com" {
+		t.Fatalf("expected the current certificate after a mismatched key, got %s", got)
+	}
+
+	// Com o certificado trocado também, o par novo passa a valer
+	cert, _ := os.ReadFile(other.CertFile)
+	if err := os.WriteFile(pair.CertFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for servedName(t, s, "") != "rotated.example.com" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := servedName(t, s, ""); got != "rotated.example.com" {
+		t.Fatalf("expected the rotated certificate, got %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// VerifiesClients indica se o listener pede certificado de cliente (mTLS)
+func (s *Store) VerifiesClients() bool {
+	return len(s.opts.ClientCAFiles) > 0
+}
+
+// VerifyClient valida o certificado do cliente contra as CAs e as CRLs atuais.
+// Roda no handshake (tls.Config.VerifyConnection), então um certificado
+// presente em r.TLS.PeerCertificates já passou por aqui; sem certificado a
+// conexão segue e a autenticação fica para a API Key
+func (s *Store) VerifyClient(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+
+	snap := s.current.Load()
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         snap.clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("client certificate: %w", err)
+	}
+
+	// Basta uma cadeia válida sem nenhum certificado revogado. CRL vencida
+	// não diz mais nada sobre revogações: a cadeia é recusada (fail closed)
+	now := s.now()
+	expired := false
+	for _, chain := range chains {
+		if snap.crlExpired(chain, now) {
+			expired = true
+			continue
+		}
+		if !snap.isRevoked(chain) {
+			return nil
+		}
+	}
+	if expired {
+		slog.Warn("client certificate rejected, crl expired", "subject", cs.PeerCertificates[0].Subject.String())
+		return errors.New("client certificate: crl expired")
+	}
+	return errors.New("client certificate revoked")
+}
+
+func (snap *snapshot) crlExpired(chain []*x509.Certificate, now time.Time) bool {
+	for _, cert := range chain {
+		if next, ok := snap.crlExpiry[string(cert.RawIssuer)]; ok && now.After(next) {
+			return true
+		}
+	}
+	return false
+}
+
+func (snap *snapshot) isRevoked(chain []*x509.Certificate) bool {
+	for _, cert := range chain {
+		if snap.revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())] {
+			return true
+		}
+	}
+	return false
+}
+
+func revocationKey(issuer []byte, serial string) string {
+	return string(issuer) + "/" + serial
+}
+
+// loadCAs lê bundles PEM com uma ou mais CAs
+func loadCAs(files []string) ([]*x509.Certificate, error) {
+	var cas []*x509.Certificate
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca %s: %w", path, err)
+		}
+
+		found := 0
+		for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			ca, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse client ca %s: %w", path, err)
+			}
+			cas = append(cas, ca)
+			found++
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("client ca %s has no certificates", path)
+		}
+	}
+	return cas, nil
+}
+
+// loadCRLs lê CRLs em PEM ou DER; cada uma precisa estar assinada por uma das
+// CAs configuradas, senão qualquer um poderia revogar (ou deixar de revogar).
+// CRL já vencida (NextUpdate no passado) é recusada
+func loadCRLs(files []string, cas []*x509.Certificate, now time.Time) (map[string]bool, map[string]time.Time, error) {
+	revoked := map[string]bool{}
+	expiry := map[string]time.Time{}
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read crl %s: %w", path, err)
+		}
+		if block, _ := pem.Decode(b); block != nil {
+			b = block.Bytes
+		}
+
+		crl, err := x509.ParseRevocationList(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse crl %s: %w", path, err)
+		}
+
+		signed := false
+		for _, ca := range cas {
+			if crl.CheckSignatureFrom(ca) == nil {
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			return nil, nil, fmt.Errorf("crl %s is not signed by a configured client ca", path)
+		}
+		if !crl.NextUpdate.IsZero() {
+			if now.After(crl.NextUpdate) {
+				return nil, nil, fmt.Errorf("crl %s expired at %s", path, crl.NextUpdate.Format(time.RFC3339))
+			}
+			// Com mais de uma CRL do mesmo emissor vale o menor prazo
+			if prev, ok := expiry[string(crl.RawIssuer)]; !ok || crl.NextUpdate.Before(prev) {
+				expiry[string(crl.RawIssuer)] = crl.NextUpdate
+			}
+		}
+
+		for _, entry := range crl.RevokedCertificateEntries {
+			revoked[revocationKey(crl.RawIssuer, entry.SerialNumber.String())] = true
+		}
+	}
+	return revoked, expiry, nil
+}
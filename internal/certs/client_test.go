@@ -0,0 +1,182 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "partners-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issueClient(t *testing.T, serial int64, cn string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca testCA) writeCRL(t *testing.T, path string, serials ...int64) {
+	t.Helper()
+
+	var entries []x509.RevocationListEntry
+	for _, s := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(s), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientCertificateVerification(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	crlFile := filepath.Join(dir, "ca.crl")
+	ca.writeCRL(t, crlFile, 3)
+
+	store, err := Load(Options{
+		Pairs:         []Pair{writePair(t, dir, "server", "127.0.0.1")},
+		ClientCAFiles: []string{caFile},
+		CRLFiles:      []string{crlFile},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := ServerConfig(store, "1.2", nil, "optional")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			_, _ = w.Write([]byte("anonymous"))
+			return
+		}
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	call := func(cert *tls.Certificate) (string, error) {
+		cfg := &tls.Config{InsecureSkipVerify: true}
+		if cert != nil {
+			cfg.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		b := make([]byte, 64)
+		n, _ := res.Body.Read(b)
+		return string(b[:n]), nil
+	}
+
+	partner := ca.issueClient(t, 2, "partner-a")
+	if got, err := call(&partner); err != nil || got != "partner-a" {
+		t.Fatalf("expected the partner certificate to be accepted, got %q (%v)", got, err)
+	}
+	// Modo optional: sem certificado a conexão segue para a API Key
+	if got, err := call(nil); err != nil || got != "anonymous" {
+		t.Fatalf("expected an anonymous connection, got %q (%v)", got, err)
+	}
+
+	revoked := ca.issueClient(t, 3, "partner-b")
+	if _, err := call(&revoked); err == nil {
+		t.Fatal("expected the revoked certificate to fail the handshake")
+	}
+
+	unknown := newTestCA(t).issueClient(t, 2, "intruder")
+	if _, err := call(&unknown); err == nil {
+		t.Fatal("expected a certificate from an unknown ca to fail the handshake")
+	}
+
+	// CRL nova revoga o parceiro sem reiniciar
+	ca.writeCRL(t, crlFile, 2, 3)
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := call(&partner); err == nil {
+		t.Fatal("expected the certificate revoked after reload to fail the handshake")
+	}
+	// CRL vencida não é mais confiável: recusa o handshake e o reload
+	fresh := ca.issueClient(t, 4, "partner-c")
+	if got, err := call(&fresh); err != nil || got != "partner-c" {
+		t.Fatalf("expected a certificate that is not revoked to be accepted, got %q (%v)", got, err)
+	}
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := call(&fresh); err == nil {
+		t.Fatal("expected the handshake to fail once the crl expired")
+	}
+	if err := store.Reload(); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected reload to reject the expired crl, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// ParseVersion aceita "1.2" e "1.3"; versões anteriores não são suportadas
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("tls version must be 1.2 or 1.3, got %q", v)
+	}
+}
+
+// ParseCipherSuites converte os nomes do IANA (ex.: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+// nos IDs; suites consideradas inseguras pelo crypto/tls são recusadas
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ParseClientAuth aceita "optional" (certificado ou API Key) e "require"
+// (toda conexão TLS precisa de certificado)
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "optional":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	default:
+		return 0, fmt.Errorf("client auth must be optional or require, got %q", mode)
+	}
+}
+
+// ServerConfig monta o tls.Config do listener HTTPS. As cipher suites valem só
+// até o TLS 1.2; as do TLS 1.3 não são configuráveis no crypto/tls
+func ServerConfig(store *Store, minVersion string, cipherSuites []string, clientAuth string) (*tls.Config, error) {
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     version,
+		CipherSuites:   suites,
+		GetCertificate: store.GetCertificate,
+	}
+
+	// A verificação fica no VerifyConnection, e não no ClientCAs, para usar as
+	// CAs e CRLs recarregadas sem recriar o listener
+	if store.VerifiesClients() {
+		if cfg.ClientAuth, err = ParseClientAuth(clientAuth); err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = store.VerifyClient
+	}
+	return cfg, nil
+}
@@ -0,0 +1,2 @@
+// Package certs loads the gateway TLS certificates from disk, selects them by SNI and reloads them when the files change
+package certs
@@ -3,32 +3,340 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config reúne a configuração do gateway. A precedência é: padrões, arquivo
+// (AEGIS_CONFIG_FILE, YAML ou TOML) e por último as variáveis de ambiente
 type Config struct {
-	AEGIS_LISTEN_PORT  string
-	AEGIS_REDIS_ADDR   string
-	AEGIS_DATABASE_URL string
+	AEGIS_CONFIG_FILE string `yaml:"-" toml:"-"`
+
+	AEGIS_LISTEN_PORT  string `yaml:"listen_port" toml:"listen_port"`
+	AEGIS_REDIS_ADDR   string `yaml:"redis_addr" toml:"redis_addr"`
+	AEGIS_DATABASE_URL string `yaml:"database_url" toml:"database_url"`
+	AEGIS_ADMIN_ADDR   string `yaml:"admin_addr" toml:"admin_addr"`
+	AEGIS_ADMIN_TOKEN  string `yaml:"admin_token" toml:"admin_token"`
+	AEGIS_USAGE_SPOOL  string `yaml:"usage_spool" toml:"usage_spool"`
+	AEGIS_USAGE_STREAM string `yaml:"usage_stream" toml:"usage_stream"`
+	AEGIS_METRICS_ADDR string `yaml:"metrics_addr" toml:"metrics_addr"`
+
+	AEGIS_METRICS_MAX_LABEL_VALUES int `yaml:"metrics_max_label_values" toml:"metrics_max_label_values"`
+
+	AEGIS_DEFAULT_RATE_LIMIT_RPS    float64       `yaml:"default_rate_limit_rps" toml:"default_rate_limit_rps"`
+	AEGIS_DEFAULT_RATE_LIMIT_BURST  int           `yaml:"default_rate_limit_burst" toml:"default_rate_limit_burst"`
+	AEGIS_DEFAULT_MONTHLY_QUOTA     int64         `yaml:"default_monthly_quota" toml:"default_monthly_quota"`
+	AEGIS_UPGRADE_MAX_CONNS_PER_KEY int           `yaml:"upgrade_max_conns_per_key" toml:"upgrade_max_conns_per_key"`
+	AEGIS_APIKEY_CACHE_TTL          time.Duration `yaml:"apikey_cache_ttl" toml:"apikey_cache_ttl"`
+	AEGIS_RATELIMIT_MEMORY_TTL      time.Duration `yaml:"ratelimit_memory_ttl" toml:"ratelimit_memory_ttl"`
+	AEGIS_CLEANUP_INTERVAL          time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval"`
+	AEGIS_RELOAD_INTERVAL           time.Duration `yaml:"reload_interval" toml:"reload_interval"`
+	AEGIS_CONFIG_WATCH_INTERVAL     time.Duration `yaml:"config_watch_interval" toml:"config_watch_interval"`
+	AEGIS_SHUTDOWN_TIMEOUT          time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	AEGIS_HTTP2_CLEARTEXT            bool          `yaml:"http2_cleartext" toml:"http2_cleartext"`
+	AEGIS_SERVER_READ_HEADER_TIMEOUT time.Duration `yaml:"server_read_header_timeout" toml:"server_read_header_timeout"`
+	AEGIS_SERVER_READ_TIMEOUT        time.Duration `yaml:"server_read_timeout" toml:"server_read_timeout"`
+	AEGIS_SERVER_WRITE_TIMEOUT       time.Duration `yaml:"server_write_timeout" toml:"server_write_timeout"`
+	AEGIS_SERVER_IDLE_TIMEOUT        time.Duration `yaml:"server_idle_timeout" toml:"server_idle_timeout"`
+
+	AEGIS_TLS_ADDR                string        `yaml:"tls_addr" toml:"tls_addr"`
+	AEGIS_TLS_CERTS               []string      `yaml:"tls_certs" toml:"tls_certs"`
+	AEGIS_TLS_MIN_VERSION         string        `yaml:"tls_min_version" toml:"tls_min_version"`
+	AEGIS_TLS_CIPHER_SUITES       []string      `yaml:"tls_cipher_suites" toml:"tls_cipher_suites"`
+	AEGIS_TLS_CERT_WATCH_INTERVAL time.Duration `yaml:"tls_cert_watch_interval" toml:"tls_cert_watch_interval"`
+	AEGIS_TLS_REDIRECT_HTTP       bool          `yaml:"tls_redirect_http" toml:"tls_redirect_http"`
+	AEGIS_TLS_CLIENT_CA_FILES     []string      `yaml:"tls_client_ca_files" toml:"tls_client_ca_files"`
+	AEGIS_TLS_CLIENT_CRL_FILES    []string      `yaml:"tls_client_crl_files" toml:"tls_client_crl_files"`
+	AEGIS_TLS_CLIENT_AUTH         string        `yaml:"tls_client_auth" toml:"tls_client_auth"`
+
+	AEGIS_AUTH_METHODS              []string      `yaml:"auth_methods" toml:"auth_methods"`
+	AEGIS_JWT_JWKS_URL              string        `yaml:"jwt_jwks_url" toml:"jwt_jwks_url"`
+	AEGIS_JWT_JWKS_FILE             string        `yaml:"jwt_jwks_file" toml:"jwt_jwks_file"`
+	AEGIS_JWT_JWKS_REFRESH_INTERVAL time.Duration `yaml:"jwt_jwks_refresh_interval" toml:"jwt_jwks_refresh_interval"`
+	AEGIS_JWT_ISSUER                string        `yaml:"jwt_issuer" toml:"jwt_issuer"`
+	AEGIS_JWT_AUDIENCES             []string      `yaml:"jwt_audiences" toml:"jwt_audiences"`
+	AEGIS_JWT_CONSUMER_CLAIM        string        `yaml:"jwt_consumer_claim" toml:"jwt_consumer_claim"`
+	AEGIS_JWT_LEEWAY                time.Duration `yaml:"jwt_leeway" toml:"jwt_leeway"`
+
+	AEGIS_PROXY_FLUSH_INTERVAL             time.Duration `yaml:"proxy_flush_interval" toml:"proxy_flush_interval"`
+	AEGIS_UPSTREAM_TIMEOUT                 time.Duration `yaml:"upstream_timeout" toml:"upstream_timeout"`
+	AEGIS_UPSTREAM_CONNECT_TIMEOUT         time.Duration `yaml:"upstream_connect_timeout" toml:"upstream_connect_timeout"`
+	AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT   time.Duration `yaml:"upstream_tls_handshake_timeout" toml:"upstream_tls_handshake_timeout"`
+	AEGIS_UPSTREAM_REQUEST_TIMEOUT         time.Duration `yaml:"upstream_request_timeout" toml:"upstream_request_timeout"`
+	AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST int           `yaml:"upstream_max_idle_conns_per_host" toml:"upstream_max_idle_conns_per_host"`
+	AEGIS_UPSTREAM_MAX_CONNS_PER_HOST      int           `yaml:"upstream_max_conns_per_host" toml:"upstream_max_conns_per_host"`
+	AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT       time.Duration `yaml:"upstream_idle_conn_timeout" toml:"upstream_idle_conn_timeout"`
+	AEGIS_UPSTREAM_KEEP_ALIVE              time.Duration `yaml:"upstream_keep_alive" toml:"upstream_keep_alive"`
+
+	AEGIS_RETRY_MAX_ATTEMPTS          int           `yaml:"retry_max_attempts" toml:"retry_max_attempts"`
+	AEGIS_RETRY_ON_STATUS             []int         `yaml:"retry_on_status" toml:"retry_on_status"`
+	AEGIS_RETRY_BACKOFF_BASE          time.Duration `yaml:"retry_backoff_base" toml:"retry_backoff_base"`
+	AEGIS_RETRY_BACKOFF_MAX           time.Duration `yaml:"retry_backoff_max" toml:"retry_backoff_max"`
+	AEGIS_RETRY_BUDGET_RATIO          float64       `yaml:"retry_budget_ratio" toml:"retry_budget_ratio"`
+	AEGIS_RETRY_BUDGET_MIN_PER_SECOND int           `yaml:"retry_budget_min_per_second" toml:"retry_budget_min_per_second"`
+
+	AEGIS_BREAKER_CONSECUTIVE_FAILURES int           `yaml:"breaker_consecutive_failures" toml:"breaker_consecutive_failures"`
+	AEGIS_BREAKER_ERROR_RATE           float64       `yaml:"breaker_error_rate" toml:"breaker_error_rate"`
+	AEGIS_BREAKER_MIN_REQUESTS         int           `yaml:"breaker_min_requests" toml:"breaker_min_requests"`
+	AEGIS_BREAKER_COOLDOWN             time.Duration `yaml:"breaker_cooldown" toml:"breaker_cooldown"`
+
+	AEGIS_TRACING_EXPORTER      string  `yaml:"tracing_exporter" toml:"tracing_exporter"`
+	AEGIS_TRACING_OTLP_ENDPOINT string  `yaml:"tracing_otlp_endpoint" toml:"tracing_otlp_endpoint"`
+	AEGIS_TRACING_FILE          string  `yaml:"tracing_file" toml:"tracing_file"`
+	AEGIS_TRACING_SAMPLE_RATIO  float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio"`
 }
 
+// Defaults devolve a configuração usada quando nem arquivo nem env definem um valor
+func Defaults() Config {
+	return Config{
+		AEGIS_LISTEN_PORT:  "8000",
+		AEGIS_REDIS_ADDR:   "localhost:6379",
+		AEGIS_ADMIN_ADDR:   "127.0.0.1:8001",
+		AEGIS_USAGE_SPOOL:  "data/usage-spool.jsonl",
+		AEGIS_USAGE_STREAM: "aether.usage.v1",
+		AEGIS_METRICS_ADDR: "127.0.0.1:9100",
+
+		AEGIS_METRICS_MAX_LABEL_VALUES: 200,
+
+		AEGIS_DEFAULT_RATE_LIMIT_RPS:    5,
+		AEGIS_DEFAULT_RATE_LIMIT_BURST:  10,
+		AEGIS_DEFAULT_MONTHLY_QUOTA:     10000,
+		AEGIS_UPGRADE_MAX_CONNS_PER_KEY: 100,
+		AEGIS_APIKEY_CACHE_TTL:          60 * time.Second,
+		AEGIS_RATELIMIT_MEMORY_TTL:      30 * time.Minute,
+		AEGIS_CLEANUP_INTERVAL:          5 * time.Minute,
+		AEGIS_RELOAD_INTERVAL:           30 * time.Second,
+		AEGIS_CONFIG_WATCH_INTERVAL:     5 * time.Second,
+		AEGIS_SHUTDOWN_TIMEOUT:          10 * time.Second,
+
+		AEGIS_SERVER_READ_HEADER_TIMEOUT: 10 * time.Second,
+		AEGIS_SERVER_IDLE_TIMEOUT:        120 * time.Second,
+
+		AEGIS_TLS_ADDR:                ":8443",
+		AEGIS_TLS_MIN_VERSION:         "1.2",
+		AEGIS_TLS_CERT_WATCH_INTERVAL: 10 * time.Second,
+		AEGIS_TLS_CLIENT_AUTH:         "optional",
+
+		AEGIS_AUTH_METHODS:              []string{"api_key", "client_cert"},
+		AEGIS_JWT_JWKS_REFRESH_INTERVAL: 5 * time.Minute,
+		AEGIS_JWT_CONSUMER_CLAIM:        "sub",
+		AEGIS_JWT_LEEWAY:                30 * time.Second,
+
+		AEGIS_PROXY_FLUSH_INTERVAL:             100 * time.Millisecond,
+		AEGIS_UPSTREAM_TIMEOUT:                 30 * time.Second,
+		AEGIS_UPSTREAM_CONNECT_TIMEOUT:         5 * time.Second,
+		AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT:   10 * time.Second,
+		AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST: 32,
+		AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT:       90 * time.Second,
+		AEGIS_UPSTREAM_KEEP_ALIVE:              30 * time.Second,
+
+		AEGIS_RETRY_MAX_ATTEMPTS:          2,
+		AEGIS_RETRY_ON_STATUS:             []int{502, 503},
+		AEGIS_RETRY_BACKOFF_BASE:          25 * time.Millisecond,
+		AEGIS_RETRY_BACKOFF_MAX:           250 * time.Millisecond,
+		AEGIS_RETRY_BUDGET_RATIO:          0.2,
+		AEGIS_RETRY_BUDGET_MIN_PER_SECOND: 3,
+
+		AEGIS_BREAKER_CONSECUTIVE_FAILURES: 5,
+		AEGIS_BREAKER_ERROR_RATE:           0.5,
+		AEGIS_BREAKER_MIN_REQUESTS:         20,
+		AEGIS_BREAKER_COOLDOWN:             30 * time.Second,
+
+		AEGIS_TRACING_EXPORTER:     "none",
+		AEGIS_TRACING_FILE:         "data/traces.jsonl",
+		AEGIS_TRACING_SAMPLE_RATIO: 1,
+	}
+}
+
+// Load monta a configuração a partir dos padrões, do arquivo e do ambiente, e a valida.
+// É chamado de novo a cada reload
 func Load() (Config, error) {
 	_ = godotenv.Load()
 
-	if err := RequireEnvs("AEGIS_LISTEN_PORT", "AEGIS_DATABASE_URL"); err != nil {
+	cfg := Defaults()
+	cfg.AEGIS_CONFIG_FILE = getEnv("AEGIS_CONFIG_FILE", "")
+
+	if cfg.AEGIS_CONFIG_FILE != "" {
+		if err := loadFile(cfg.AEGIS_CONFIG_FILE, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
 		return Config{}, err
 	}
-	cfg := Config{
-		AEGIS_LISTEN_PORT:  getEnv("AEGIS_LISTEN_PORT", "8000"),
-		AEGIS_REDIS_ADDR:   getEnv("AEGIS_REDIS_ADDR", "localhost:6379"),
-		AEGIS_DATABASE_URL: getEnv("AEGIS_DATABASE_URL", ""),
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
 	}
 
 	return cfg, nil
 }
 
+// applyEnv sobrescreve com as variáveis de ambiente definidas; o valor atual é o fallback
+func applyEnv(cfg *Config) error {
+	cfg.AEGIS_LISTEN_PORT = getEnv("AEGIS_LISTEN_PORT", cfg.AEGIS_LISTEN_PORT)
+	cfg.AEGIS_REDIS_ADDR = getEnv("AEGIS_REDIS_ADDR", cfg.AEGIS_REDIS_ADDR)
+	cfg.AEGIS_DATABASE_URL = getEnv("AEGIS_DATABASE_URL", cfg.AEGIS_DATABASE_URL)
+	cfg.AEGIS_ADMIN_ADDR = getEnv("AEGIS_ADMIN_ADDR", cfg.AEGIS_ADMIN_ADDR)
+	cfg.AEGIS_ADMIN_TOKEN = getEnv("AEGIS_ADMIN_TOKEN", cfg.AEGIS_ADMIN_TOKEN)
+	cfg.AEGIS_USAGE_SPOOL = getEnv("AEGIS_USAGE_SPOOL", cfg.AEGIS_USAGE_SPOOL)
+	cfg.AEGIS_USAGE_STREAM = getEnv("AEGIS_USAGE_STREAM", cfg.AEGIS_USAGE_STREAM)
+	cfg.AEGIS_METRICS_ADDR = getEnv("AEGIS_METRICS_ADDR", cfg.AEGIS_METRICS_ADDR)
+	cfg.AEGIS_TRACING_EXPORTER = getEnv("AEGIS_TRACING_EXPORTER", cfg.AEGIS_TRACING_EXPORTER)
+	cfg.AEGIS_TRACING_OTLP_ENDPOINT = getEnv("AEGIS_TRACING_OTLP_ENDPOINT", cfg.AEGIS_TRACING_OTLP_ENDPOINT)
+	cfg.AEGIS_TRACING_FILE = getEnv("AEGIS_TRACING_FILE", cfg.AEGIS_TRACING_FILE)
+	cfg.AEGIS_TLS_ADDR = getEnv("AEGIS_TLS_ADDR", cfg.AEGIS_TLS_ADDR)
+	cfg.AEGIS_TLS_MIN_VERSION = getEnv("AEGIS_TLS_MIN_VERSION", cfg.AEGIS_TLS_MIN_VERSION)
+	if certs := parseList("AEGIS_TLS_CERTS"); certs != nil {
+		cfg.AEGIS_TLS_CERTS = certs
+	}
+	if suites := parseList("AEGIS_TLS_CIPHER_SUITES"); suites != nil {
+		cfg.AEGIS_TLS_CIPHER_SUITES = suites
+	}
+	if cas := parseList("AEGIS_TLS_CLIENT_CA_FILES"); cas != nil {
+		cfg.AEGIS_TLS_CLIENT_CA_FILES = cas
+	}
+	if crls := parseList("AEGIS_TLS_CLIENT_CRL_FILES"); crls != nil {
+		cfg.AEGIS_TLS_CLIENT_CRL_FILES = crls
+	}
+	cfg.AEGIS_TLS_CLIENT_AUTH = getEnv("AEGIS_TLS_CLIENT_AUTH", cfg.AEGIS_TLS_CLIENT_AUTH)
+	if methods := parseList("AEGIS_AUTH_METHODS"); methods != nil {
+		cfg.AEGIS_AUTH_METHODS = methods
+	}
+	cfg.AEGIS_JWT_JWKS_URL = getEnv("AEGIS_JWT_JWKS_URL", cfg.AEGIS_JWT_JWKS_URL)
+	cfg.AEGIS_JWT_JWKS_FILE = getEnv("AEGIS_JWT_JWKS_FILE", cfg.AEGIS_JWT_JWKS_FILE)
+	cfg.AEGIS_JWT_ISSUER = getEnv("AEGIS_JWT_ISSUER", cfg.AEGIS_JWT_ISSUER)
+	if audiences := parseList("AEGIS_JWT_AUDIENCES"); audiences != nil {
+		cfg.AEGIS_JWT_AUDIENCES = audiences
+	}
+	cfg.AEGIS_JWT_CONSUMER_CLAIM = getEnv("AEGIS_JWT_CONSUMER_CLAIM", cfg.AEGIS_JWT_CONSUMER_CLAIM)
+
+	var err error
+	if cfg.AEGIS_METRICS_MAX_LABEL_VALUES, err = getEnvInt("AEGIS_METRICS_MAX_LABEL_VALUES", cfg.AEGIS_METRICS_MAX_LABEL_VALUES); err != nil {
+		return err
+	}
+	if cfg.AEGIS_DEFAULT_RATE_LIMIT_RPS, err = getEnvFloat("AEGIS_DEFAULT_RATE_LIMIT_RPS", cfg.AEGIS_DEFAULT_RATE_LIMIT_RPS); err != nil {
+		return err
+	}
+	if cfg.AEGIS_DEFAULT_RATE_LIMIT_BURST, err = getEnvInt("AEGIS_DEFAULT_RATE_LIMIT_BURST", cfg.AEGIS_DEFAULT_RATE_LIMIT_BURST); err != nil {
+		return err
+	}
+	if cfg.AEGIS_HTTP2_CLEARTEXT, err = getEnvBool("AEGIS_HTTP2_CLEARTEXT", cfg.AEGIS_HTTP2_CLEARTEXT); err != nil {
+		return err
+	}
+	if cfg.AEGIS_TLS_REDIRECT_HTTP, err = getEnvBool("AEGIS_TLS_REDIRECT_HTTP", cfg.AEGIS_TLS_REDIRECT_HTTP); err != nil {
+		return err
+	}
+	if cfg.AEGIS_UPGRADE_MAX_CONNS_PER_KEY, err = getEnvInt("AEGIS_UPGRADE_MAX_CONNS_PER_KEY", cfg.AEGIS_UPGRADE_MAX_CONNS_PER_KEY); err != nil {
+		return err
+	}
+	quota, err := getEnvInt("AEGIS_DEFAULT_MONTHLY_QUOTA", int(cfg.AEGIS_DEFAULT_MONTHLY_QUOTA))
+	if err != nil {
+		return err
+	}
+	cfg.AEGIS_DEFAULT_MONTHLY_QUOTA = int64(quota)
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"AEGIS_APIKEY_CACHE_TTL", &cfg.AEGIS_APIKEY_CACHE_TTL},
+		{"AEGIS_RATELIMIT_MEMORY_TTL", &cfg.AEGIS_RATELIMIT_MEMORY_TTL},
+		{"AEGIS_CLEANUP_INTERVAL", &cfg.AEGIS_CLEANUP_INTERVAL},
+		{"AEGIS_RELOAD_INTERVAL", &cfg.AEGIS_RELOAD_INTERVAL},
+		{"AEGIS_CONFIG_WATCH_INTERVAL", &cfg.AEGIS_CONFIG_WATCH_INTERVAL},
+		{"AEGIS_SHUTDOWN_TIMEOUT", &cfg.AEGIS_SHUTDOWN_TIMEOUT},
+		{"AEGIS_SERVER_READ_HEADER_TIMEOUT", &cfg.AEGIS_SERVER_READ_HEADER_TIMEOUT},
+		{"AEGIS_SERVER_READ_TIMEOUT", &cfg.AEGIS_SERVER_READ_TIMEOUT},
+		{"AEGIS_SERVER_WRITE_TIMEOUT", &cfg.AEGIS_SERVER_WRITE_TIMEOUT},
+		{"AEGIS_SERVER_IDLE_TIMEOUT", &cfg.AEGIS_SERVER_IDLE_TIMEOUT},
+		{"AEGIS_TLS_CERT_WATCH_INTERVAL", &cfg.AEGIS_TLS_CERT_WATCH_INTERVAL},
+		{"AEGIS_JWT_JWKS_REFRESH_INTERVAL", &cfg.AEGIS_JWT_JWKS_REFRESH_INTERVAL},
+		{"AEGIS_JWT_LEEWAY", &cfg.AEGIS_JWT_LEEWAY},
+		{"AEGIS_PROXY_FLUSH_INTERVAL", &cfg.AEGIS_PROXY_FLUSH_INTERVAL},
+		{"AEGIS_UPSTREAM_TIMEOUT", &cfg.AEGIS_UPSTREAM_TIMEOUT},
+		{"AEGIS_UPSTREAM_CONNECT_TIMEOUT", &cfg.AEGIS_UPSTREAM_CONNECT_TIMEOUT},
+		{"AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT", &cfg.AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT},
+		{"AEGIS_UPSTREAM_REQUEST_TIMEOUT", &cfg.AEGIS_UPSTREAM_REQUEST_TIMEOUT},
+		{"AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT", &cfg.AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT},
+		{"AEGIS_UPSTREAM_KEEP_ALIVE", &cfg.AEGIS_UPSTREAM_KEEP_ALIVE},
+		{"AEGIS_RETRY_BACKOFF_BASE", &cfg.AEGIS_RETRY_BACKOFF_BASE},
+		{"AEGIS_RETRY_BACKOFF_MAX", &cfg.AEGIS_RETRY_BACKOFF_MAX},
+		{"AEGIS_BREAKER_COOLDOWN", &cfg.AEGIS_BREAKER_COOLDOWN},
+	}
+	for _, d := range durations {
+		if *d.dst, err = getEnvDuration(d.key, *d.dst); err != nil {
+			return err
+		}
+	}
+
+	if cfg.AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST, err = getEnvInt("AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST", cfg.AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST); err != nil {
+		return err
+	}
+	if cfg.AEGIS_UPSTREAM_MAX_CONNS_PER_HOST, err = getEnvInt("AEGIS_UPSTREAM_MAX_CONNS_PER_HOST", cfg.AEGIS_UPSTREAM_MAX_CONNS_PER_HOST); err != nil {
+		return err
+	}
+	if cfg.AEGIS_RETRY_MAX_ATTEMPTS, err = getEnvInt("AEGIS_RETRY_MAX_ATTEMPTS", cfg.AEGIS_RETRY_MAX_ATTEMPTS); err != nil {
+		return err
+	}
+	if cfg.AEGIS_RETRY_ON_STATUS, err = getEnvInts("AEGIS_RETRY_ON_STATUS", cfg.AEGIS_RETRY_ON_STATUS); err != nil {
+		return err
+	}
+	if cfg.AEGIS_RETRY_BUDGET_RATIO, err = getEnvFloat("AEGIS_RETRY_BUDGET_RATIO", cfg.AEGIS_RETRY_BUDGET_RATIO); err != nil {
+		return err
+	}
+	if cfg.AEGIS_RETRY_BUDGET_MIN_PER_SECOND, err = getEnvInt("AEGIS_RETRY_BUDGET_MIN_PER_SECOND", cfg.AEGIS_RETRY_BUDGET_MIN_PER_SECOND); err != nil {
+		return err
+	}
+	if cfg.AEGIS_BREAKER_CONSECUTIVE_FAILURES, err = getEnvInt("AEGIS_BREAKER_CONSECUTIVE_FAILURES", cfg.AEGIS_BREAKER_CONSECUTIVE_FAILURES); err != nil {
+		return err
+	}
+	if cfg.AEGIS_BREAKER_ERROR_RATE, err = getEnvFloat("AEGIS_BREAKER_ERROR_RATE", cfg.AEGIS_BREAKER_ERROR_RATE); err != nil {
+		return err
+	}
+	if cfg.AEGIS_BREAKER_MIN_REQUESTS, err = getEnvInt("AEGIS_BREAKER_MIN_REQUESTS", cfg.AEGIS_BREAKER_MIN_REQUESTS); err != nil {
+		return err
+	}
+	if cfg.AEGIS_TRACING_SAMPLE_RATIO, err = getEnvFloat("AEGIS_TRACING_SAMPLE_RATIO", cfg.AEGIS_TRACING_SAMPLE_RATIO); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AggregatorConfig configura o cmd/usage-aggregator
+type AggregatorConfig struct {
+	AEGIS_DATABASE_URL   string
+	AEGIS_REDIS_ADDR     string
+	AEGIS_USAGE_STREAM   string
+	AEGIS_USAGE_GROUP    string
+	AEGIS_USAGE_CONSUMER string
+}
+
+func LoadAggregator() (AggregatorConfig, error) {
+	_ = godotenv.Load()
+
+	if err := RequireEnvs("AEGIS_DATABASE_URL"); err != nil {
+		return AggregatorConfig{}, err
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "usage-aggregator"
+	}
+
+	return AggregatorConfig{
+		AEGIS_DATABASE_URL:   getEnv("AEGIS_DATABASE_URL", ""),
+		AEGIS_REDIS_ADDR:     getEnv("AEGIS_REDIS_ADDR", "localhost:6379"),
+		AEGIS_USAGE_STREAM:   getEnv("AEGIS_USAGE_STREAM", "aether.usage.v1"),
+		AEGIS_USAGE_GROUP:    getEnv("AEGIS_USAGE_GROUP", "usage-aggregator"),
+		AEGIS_USAGE_CONSUMER: getEnv("AEGIS_USAGE_CONSUMER", hostname),
+	}, nil
+}
+
 func getEnv(key string, fallback string) string {
 	if v, ok := os.LookupEnv(key); ok && v != "" {
 		return v
@@ -36,6 +344,76 @@ func getEnv(key string, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) (int, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// getEnvInts lê uma lista separada por vírgulas; "none" esvazia a lista
+func getEnvInts(key string, fallback []int) ([]int, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	if raw == "none" {
+		return []int{}, nil
+	}
+
+	var out []int
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return v, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// getEnvDuration aceita o formato do time.ParseDuration (ex.: 30s, 1m)
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return v, nil
+}
+
 func parseList(key string) []string {
 	raw := os.Getenv(key)
 
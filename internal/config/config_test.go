@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "aegis.yaml", `
+database_url: postgres://file/aegis
+listen_port: "9000"
+default_monthly_quota: 500
+apikey_cache_ttl: 2m
+breaker_error_rate: 0.25
+`)
+	t.Setenv("AEGIS_CONFIG_FILE", path)
+	t.Setenv("AEGIS_LISTEN_PORT", "9100")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// O ambiente vence o arquivo, que vence os padrões
+	if cfg.AEGIS_LISTEN_PORT != "9100" {
+		t.Fatalf("expected env override, got %s", cfg.AEGIS_LISTEN_PORT)
+	}
+	if cfg.AEGIS_DATABASE_URL != "postgres://file/aegis" || cfg.AEGIS_DEFAULT_MONTHLY_QUOTA != 500 {
+		t.Fatalf("expected values from file, got %+v", cfg)
+	}
+	if cfg.AEGIS_APIKEY_CACHE_TTL != 2*time.Minute || cfg.AEGIS_BREAKER_ERROR_RATE != 0.25 {
+		t.Fatalf("expected durations and floats from file, got %+v", cfg)
+	}
+	if cfg.AEGIS_USAGE_STREAM != "aether.usage.v1" {
+		t.Fatalf("expected default stream, got %s", cfg.AEGIS_USAGE_STREAM)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfig(t, "aegis.toml", `
+database_url = "postgres://toml/aegis"
+shutdown_timeout = "20s"
+default_rate_limit_rps = 50.0
+`)
+	cfg := Defaults()
+	if err := loadFile(path, &cfg); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.AEGIS_SHUTDOWN_TIMEOUT != 20*time.Second || cfg.AEGIS_DEFAULT_RATE_LIMIT_RPS != 50 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadRejectsUnknownKeysAndInvalidValues(t *testing.T) {
+	cfg := Defaults()
+	if err := loadFile(writeConfig(t, "aegis.yaml", "listen_prot: 8000\n"), &cfg); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+	if err := loadFile(writeConfig(t, "aegis.toml", "listen_prot = \"8000\"\n"), &cfg); err == nil {
+		t.Fatal("expected error for unknown toml key")
+	}
+
+	cfg = Defaults()
+	cfg.AEGIS_LISTEN_PORT = "http"
+	cfg.AEGIS_TRACING_SAMPLE_RATIO = 2
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	// Todos os problemas aparecem juntos
+	for _, want := range []string{"listen_port", "database_url", "tracing_sample_ratio"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s in %q", want, err)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	prev := Defaults()
+	next := prev
+	next.AEGIS_DEFAULT_MONTHLY_QUOTA = 1
+	next.AEGIS_UPSTREAM_TIMEOUT = time.Second
+
+	if changed := RestartRequired(prev, next); len(changed) != 0 {
+		t.Fatalf("expected hot reloadable changes only, got %v", changed)
+	}
+
+	next.AEGIS_REDIS_ADDR = "redis:6379"
+	if changed := RestartRequired(prev, next); len(changed) != 1 || changed[0] != "AEGIS_REDIS_ADDR" {
+		t.Fatalf("expected AEGIS_REDIS_ADDR, got %v", changed)
+	}
+}
+
+func TestTLSConfigFromEnv(t *testing.T) {
+	t.Setenv("AEGIS_DATABASE_URL", "postgres://localhost/aegis")
+	t.Setenv("AEGIS_TLS_CERTS", "a.pem:a-key.pem, b.pem:b-key.pem")
+	t.Setenv("AEGIS_TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	t.Setenv("AEGIS_TLS_REDIRECT_HTTP", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.AEGIS_TLS_CERTS) != 2 || cfg.AEGIS_TLS_CERTS[1] != "b.pem:b-key.pem" || !cfg.AEGIS_TLS_REDIRECT_HTTP {
+		t.Fatalf("unexpected tls config: %+v", cfg)
+	}
+
+	cfg.AEGIS_TLS_CERTS = []string{"only-cert.pem"}
+	cfg.AEGIS_TLS_MIN_VERSION = "1.0"
+	cfg.AEGIS_TLS_CIPHER_SUITES = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"tls_certs", "tls_min_version", "tls_cipher_suites"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s in %q", want, err)
+		}
+	}
+}
+
+func TestJWTConfigValidation(t *testing.T) {
+	t.Setenv("AEGIS_DATABASE_URL", "postgres://localhost/aegis")
+	t.Setenv("AEGIS_AUTH_METHODS", "jwt, api_key")
+	t.Setenv("AEGIS_JWT_JWKS_URL", "https://idp.example.com/.well-known/jwks.json")
+	t.Setenv("AEGIS_JWT_ISSUER", "https://idp.example.com")
+	t.Setenv("AEGIS_JWT_AUDIENCES", "aegis")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.AEGIS_AUTH_METHODS) != 2 || cfg.AEGIS_AUTH_METHODS[0] != "jwt" || cfg.AEGIS_JWT_CONSUMER_CLAIM != "sub" {
+		t.Fatalf("unexpected auth config: %+v", cfg)
+	}
+
+	cfg.AEGIS_AUTH_METHODS = append(cfg.AEGIS_AUTH_METHODS, "basic")
+	cfg.AEGIS_JWT_JWKS_FILE = "jwks.json"
+	cfg.AEGIS_JWT_AUDIENCES = nil
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"auth_methods", "jwt_jwks_url or jwt_jwks_file", "jwt_audiences"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s in %q", want, err)
+		}
+	}
+}
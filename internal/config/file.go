@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/martinsdevv/aegis/internal/certs"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile aplica o arquivo sobre cfg; chaves desconhecidas são erro para não
+// esconder erros de digitação
+func loadFile(path string, cfg *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		// Arquivo vazio (io.EOF) mantém os padrões
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), cfg)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parse %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	return nil
+}
+
+// Validate devolve todos os problemas de uma vez
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.AEGIS_LISTEN_PORT)
+	check(err == nil && port > 0 && port <= 65535, "listen_port must be a port number, got %q", c.AEGIS_LISTEN_PORT)
+	check(c.AEGIS_DATABASE_URL != "", "database_url is required")
+	check(c.AEGIS_USAGE_STREAM != "", "usage_stream is required")
+	check(c.AEGIS_METRICS_MAX_LABEL_VALUES > 0, "metrics_max_label_values must be positive")
+
+	check(c.AEGIS_DEFAULT_RATE_LIMIT_RPS > 0, "default_rate_limit_rps must be positive")
+	check(c.AEGIS_DEFAULT_RATE_LIMIT_BURST > 0, "default_rate_limit_burst must be positive")
+	check(c.AEGIS_DEFAULT_MONTHLY_QUOTA > 0, "default_monthly_quota must be positive")
+	check(c.AEGIS_UPGRADE_MAX_CONNS_PER_KEY >= 0, "upgrade_max_conns_per_key must not be negative")
+
+	for name, d := range map[string]time.Duration{
+		"apikey_cache_ttl":     c.AEGIS_APIKEY_CACHE_TTL,
+		"ratelimit_memory_ttl": c.AEGIS_RATELIMIT_MEMORY_TTL,
+		"cleanup_interval":     c.AEGIS_CLEANUP_INTERVAL,
+		"reload_interval":      c.AEGIS_RELOAD_INTERVAL,
+		"shutdown_timeout":     c.AEGIS_SHUTDOWN_TIMEOUT,
+	} {
+		check(d > 0, "%s must be positive", name)
+	}
+	// Zero desativa estes timeouts
+	for name, d := range map[string]time.Duration{
+		"config_watch_interval":          c.AEGIS_CONFIG_WATCH_INTERVAL,
+		"server_read_header_timeout":     c.AEGIS_SERVER_READ_HEADER_TIMEOUT,
+		"server_read_timeout":            c.AEGIS_SERVER_READ_TIMEOUT,
+		"server_write_timeout":           c.AEGIS_SERVER_WRITE_TIMEOUT,
+		"server_idle_timeout":            c.AEGIS_SERVER_IDLE_TIMEOUT,
+		"tls_cert_watch_interval":        c.AEGIS_TLS_CERT_WATCH_INTERVAL,
+		"jwt_jwks_refresh_interval":      c.AEGIS_JWT_JWKS_REFRESH_INTERVAL,
+		"jwt_leeway":                     c.AEGIS_JWT_LEEWAY,
+		"upstream_timeout":               c.AEGIS_UPSTREAM_TIMEOUT,
+		"upstream_connect_timeout":       c.AEGIS_UPSTREAM_CONNECT_TIMEOUT,
+		"upstream_tls_handshake_timeout": c.AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT,
+		"upstream_request_timeout":       c.AEGIS_UPSTREAM_REQUEST_TIMEOUT,
+		"upstream_idle_conn_timeout":     c.AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT,
+		"upstream_keep_alive":            c.AEGIS_UPSTREAM_KEEP_ALIVE,
+	} {
+		check(d >= 0, "%s must not be negative", name)
+	}
+	if len(c.AEGIS_TLS_CERTS) > 0 {
+		_, _, err := net.SplitHostPort(c.AEGIS_TLS_ADDR)
+		check(err == nil, "tls_addr must be host:port, got %q", c.AEGIS_TLS_ADDR)
+		_, err = certs.ParsePairs(c.AEGIS_TLS_CERTS)
+		check(err == nil, "tls_certs: %v", err)
+		_, err = certs.ParseVersion(c.AEGIS_TLS_MIN_VERSION)
+		check(err == nil, "tls_min_version: %v", err)
+		_, err = certs.ParseCipherSuites(c.AEGIS_TLS_CIPHER_SUITES)
+		check(err == nil, "tls_cipher_suites: %v", err)
+	}
+	check(!c.AEGIS_TLS_REDIRECT_HTTP || len(c.AEGIS_TLS_CERTS) > 0, "tls_redirect_http requires tls_certs")
+	check(len(c.AEGIS_TLS_CLIENT_CA_FILES) == 0 || len(c.AEGIS_TLS_CERTS) > 0, "tls_client_ca_files requires tls_certs")
+	check(len(c.AEGIS_TLS_CLIENT_CRL_FILES) == 0 || len(c.AEGIS_TLS_CLIENT_CA_FILES) > 0, "tls_client_crl_files requires tls_client_ca_files")
+	_, err = certs.ParseClientAuth(c.AEGIS_TLS_CLIENT_AUTH)
+	check(err == nil, "tls_client_auth: %v", err)
+
+	check(len(c.AEGIS_AUTH_METHODS) > 0, "auth_methods must not be empty")
+	for _, m := range c.AEGIS_AUTH_METHODS {
+		switch m {
+		case "api_key", "client_cert", "jwt":
+		default:
+			check(false, "auth_methods must only contain api_key, client_cert or jwt, got %q", m)
+		}
+	}
+	if slices.Contains(c.AEGIS_AUTH_METHODS, "jwt") {
+		check((c.AEGIS_JWT_JWKS_URL == "") != (c.AEGIS_JWT_JWKS_FILE == ""), "jwt requires exactly one of jwt_jwks_url or jwt_jwks_file")
+		check(c.AEGIS_JWT_ISSUER != "", "jwt requires jwt_issuer")
+		check(len(c.AEGIS_JWT_AUDIENCES) > 0, "jwt requires jwt_audiences")
+		check(c.AEGIS_JWT_CONSUMER_CLAIM != "", "jwt_consumer_claim is required")
+	}
+
+	check(c.AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST >= 0, "upstream_max_idle_conns_per_host must not be negative")
+	check(c.AEGIS_UPSTREAM_MAX_CONNS_PER_HOST >= 0, "upstream_max_conns_per_host must not be negative")
+
+	check(c.AEGIS_RETRY_MAX_ATTEMPTS >= 1, "retry_max_attempts must be at least 1")
+	for _, status := range c.AEGIS_RETRY_ON_STATUS {
+		check(status >= 500 && status <= 599, "retry_on_status must only contain 5xx codes, got %d", status)
+	}
+	check(c.AEGIS_RETRY_BACKOFF_BASE > 0, "retry_backoff_base must be positive")
+	check(c.AEGIS_RETRY_BACKOFF_MAX >= c.AEGIS_RETRY_BACKOFF_BASE, "retry_backoff_max must not be less than retry_backoff_base")
+	check(c.AEGIS_RETRY_BUDGET_RATIO >= 0 && c.AEGIS_RETRY_BUDGET_RATIO <= 1, "retry_budget_ratio must be between 0 and 1")
+	check(c.AEGIS_RETRY_BUDGET_MIN_PER_SECOND >= 0, "retry_budget_min_per_second must not be negative")
+
+	check(c.AEGIS_BREAKER_CONSECUTIVE_FAILURES >= 0, "breaker_consecutive_failures must not be negative")
+	check(c.AEGIS_BREAKER_ERROR_RATE >= 0 && c.AEGIS_BREAKER_ERROR_RATE <= 1, "breaker_error_rate must be between 0 and 1")
+	check(c.AEGIS_BREAKER_MIN_REQUESTS >= 0, "breaker_min_requests must not be negative")
+	check(c.AEGIS_BREAKER_COOLDOWN >= 0, "breaker_cooldown must not be negative")
+
+	switch c.AEGIS_TRACING_EXPORTER {
+	case "none", "otlp", "stdout", "file":
+	default:
+		check(false, "tracing_exporter must be none, otlp, stdout or file, got %q", c.AEGIS_TRACING_EXPORTER)
+	}
+	check(c.AEGIS_TRACING_SAMPLE_RATIO >= 0 && c.AEGIS_TRACING_SAMPLE_RATIO <= 1, "tracing_sample_ratio must be between 0 and 1")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// hotReloadable são os campos aplicados sem reiniciar o processo
+var hotReloadable = map[string]bool{
+	"AEGIS_METRICS_MAX_LABEL_VALUES":         true,
+	"AEGIS_DEFAULT_RATE_LIMIT_RPS":           true,
+	"AEGIS_DEFAULT_RATE_LIMIT_BURST":         true,
+	"AEGIS_DEFAULT_MONTHLY_QUOTA":            true,
+	"AEGIS_UPGRADE_MAX_CONNS_PER_KEY":        true,
+	"AEGIS_SHUTDOWN_TIMEOUT":                 true,
+	"AEGIS_PROXY_FLUSH_INTERVAL":             true,
+	"AEGIS_UPSTREAM_TIMEOUT":                 true,
+	"AEGIS_UPSTREAM_CONNECT_TIMEOUT":         true,
+	"AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT":   true,
+	"AEGIS_UPSTREAM_REQUEST_TIMEOUT":         true,
+	"AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST": true,
+	"AEGIS_UPSTREAM_MAX_CONNS_PER_HOST":      true,
+	"AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT":       true,
+	"AEGIS_UPSTREAM_KEEP_ALIVE":              true,
+	"AEGIS_RETRY_MAX_ATTEMPTS":               true,
+	"AEGIS_RETRY_ON_STATUS":                  true,
+	"AEGIS_RETRY_BACKOFF_BASE":               true,
+	"AEGIS_RETRY_BACKOFF_MAX":                true,
+	"AEGIS_RETRY_BUDGET_RATIO":               true,
+	"AEGIS_RETRY_BUDGET_MIN_PER_SECOND":      true,
+	"AEGIS_BREAKER_CONSECUTIVE_FAILURES":     true,
+	"AEGIS_BREAKER_ERROR_RATE":               true,
+	"AEGIS_BREAKER_MIN_REQUESTS":             true,
+	"AEGIS_BREAKER_COOLDOWN":                 true,
+	"AEGIS_JWT_ISSUER":                       true,
+	"AEGIS_JWT_AUDIENCES":                    true,
+	"AEGIS_JWT_CONSUMER_CLAIM":               true,
+	"AEGIS_JWT_LEEWAY":                       true,
+}
+
+// RestartRequired lista os campos alterados que só valem depois de reiniciar
+func RestartRequired(prev, next Config) []string {
+	var changed []string
+
+	pv, nv := reflect.ValueOf(prev), reflect.ValueOf(next)
+	for i := 0; i < pv.NumField(); i++ {
+		name := pv.Type().Field(i).Name
+		if hotReloadable[name] {
+			continue
+		}
+		if !reflect.DeepEqual(pv.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// Watch verifica o arquivo a cada interval e chama onChange quando o conteúdo
+// muda; compara o hash e não o mtime para funcionar com a troca de symlink dos
+// ConfigMaps do Kubernetes
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	if path == "" || interval <= 0 {
+		return
+	}
+
+	last, _ := fileHash(path)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			sum, err := fileHash(path)
+			// Arquivo momentaneamente ausente durante a troca: tenta no próximo tick
+			if err != nil || sum == last {
+				continue
+			}
+			last = sum
+			onChange()
+		}
+	}
+}
+
+func fileHash(path string) ([sha256.Size]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,3 @@
+ALTER TABLE api_keys
+    DROP COLUMN IF EXISTS rate_limit_burst,
+    DROP COLUMN IF EXISTS rate_limit_rps;
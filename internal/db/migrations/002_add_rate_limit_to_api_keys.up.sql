@@ -0,0 +1,3 @@
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS rate_limit_rps DOUBLE PRECISION NOT NULL DEFAULT 5,
+    ADD COLUMN IF NOT EXISTS rate_limit_burst INTEGER NOT NULL DEFAULT 10;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS admin_tokens (
+    id SERIAL PRIMARY KEY,
+    operator TEXT NOT NULL,
+    token TEXT UNIQUE NOT NULL,       -- SHA256 do token
+    role TEXT NOT NULL DEFAULT 'viewer' CHECK (role IN ('admin', 'viewer')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_tokens_token ON admin_tokens(token);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS api_key_routes;
+DROP TABLE IF EXISTS routes;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS routes (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    path_prefix TEXT NOT NULL,
+    methods TEXT NOT NULL DEFAULT '',         -- separados por vírgula; vazio aceita todos
+    host TEXT NOT NULL DEFAULT '',            -- vazio aceita qualquer host; suporta *.dominio
+    upstream TEXT NOT NULL,
+    strip_prefix BOOLEAN NOT NULL DEFAULT FALSE,
+    rewrite_prefix TEXT NOT NULL DEFAULT '',  -- substitui o prefixo ao encaminhar
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS api_key_routes (
+    api_key_id INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    route_id INTEGER NOT NULL REFERENCES routes(id) ON DELETE CASCADE,
+    PRIMARY KEY (api_key_id, route_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS upstream_targets;
+DROP TABLE IF EXISTS upstream_pools;
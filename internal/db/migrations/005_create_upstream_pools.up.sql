@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS upstream_pools (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,                -- referenciado como pool://<name>
+    strategy TEXT NOT NULL DEFAULT 'round_robin'
+        CHECK (strategy IN ('round_robin', 'weighted_round_robin', 'least_connections', 'consistent_hash')),
+    hash_header TEXT NOT NULL DEFAULT '',     -- consistent_hash: header usado; vazio usa o ID da API Key
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS upstream_targets (
+    id SERIAL PRIMARY KEY,
+    pool_id INTEGER NOT NULL REFERENCES upstream_pools(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1 CHECK (weight > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (pool_id, url)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE upstream_pools
+    DROP COLUMN IF EXISTS unhealthy_threshold,
+    DROP COLUMN IF EXISTS healthy_threshold,
+    DROP COLUMN IF EXISTS health_expected_status,
+    DROP COLUMN IF EXISTS health_timeout_seconds,
+    DROP COLUMN IF EXISTS health_interval_seconds,
+    DROP COLUMN IF EXISTS health_path;
@@ -0,0 +1,7 @@
+ALTER TABLE upstream_pools
+    ADD COLUMN IF NOT EXISTS health_path TEXT NOT NULL DEFAULT '',               -- vazio desativa o health check
+    ADD COLUMN IF NOT EXISTS health_interval_seconds INTEGER NOT NULL DEFAULT 10,
+    ADD COLUMN IF NOT EXISTS health_timeout_seconds INTEGER NOT NULL DEFAULT 2,
+    ADD COLUMN IF NOT EXISTS health_expected_status INTEGER NOT NULL DEFAULT 200,
+    ADD COLUMN IF NOT EXISTS healthy_threshold INTEGER NOT NULL DEFAULT 2,
+    ADD COLUMN IF NOT EXISTS unhealthy_threshold INTEGER NOT NULL DEFAULT 3;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS usage_latency_rollups;
+DROP TABLE IF EXISTS usage_rollups;
+DROP TABLE IF EXISTS usage_events_seen;
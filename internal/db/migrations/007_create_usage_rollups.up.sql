@@ -0,0 +1,34 @@
+-- Eventos já agregados, para deduplicar reentregas do stream (limpos após 7 dias)
+CREATE TABLE IF NOT EXISTS usage_events_seen (
+    event_id TEXT PRIMARY KEY,
+    seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_events_seen_at ON usage_events_seen(seen_at);
+
+-- Sem FK para api_keys: o histórico de cobrança sobrevive à remoção da chave
+CREATE TABLE IF NOT EXISTS usage_rollups (
+    granularity TEXT NOT NULL CHECK (granularity IN ('hour', 'day')),
+    bucket TIMESTAMP NOT NULL,                -- início da hora/dia em UTC
+    api_key_id BIGINT NOT NULL,
+    path TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    latency_ms_sum BIGINT NOT NULL DEFAULT 0,
+    latency_ms_max BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (granularity, bucket, api_key_id, path, status_code)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_rollups_key ON usage_rollups(api_key_id, granularity, bucket);
+
+-- Histograma de latência: le_ms é o limite superior do bucket (2147483647 = +Inf)
+CREATE TABLE IF NOT EXISTS usage_latency_rollups (
+    granularity TEXT NOT NULL CHECK (granularity IN ('hour', 'day')),
+    bucket TIMESTAMP NOT NULL,
+    api_key_id BIGINT NOT NULL,
+    path TEXT NOT NULL,
+    le_ms INTEGER NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (granularity, bucket, api_key_id, path, le_ms)
+);
@@ -0,0 +1,19 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS keep_alive_ms,
+    DROP COLUMN IF EXISTS idle_conn_timeout_ms,
+    DROP COLUMN IF EXISTS max_conns_per_host,
+    DROP COLUMN IF EXISTS max_idle_conns_per_host,
+    DROP COLUMN IF EXISTS request_timeout_ms,
+    DROP COLUMN IF EXISTS response_header_timeout_ms,
+    DROP COLUMN IF EXISTS tls_handshake_timeout_ms,
+    DROP COLUMN IF EXISTS connect_timeout_ms;
+
+ALTER TABLE upstream_pools
+    DROP COLUMN IF EXISTS keep_alive_ms,
+    DROP COLUMN IF EXISTS idle_conn_timeout_ms,
+    DROP COLUMN IF EXISTS max_conns_per_host,
+    DROP COLUMN IF EXISTS max_idle_conns_per_host,
+    DROP COLUMN IF EXISTS request_timeout_ms,
+    DROP COLUMN IF EXISTS response_header_timeout_ms,
+    DROP COLUMN IF EXISTS tls_handshake_timeout_ms,
+    DROP COLUMN IF EXISTS connect_timeout_ms;
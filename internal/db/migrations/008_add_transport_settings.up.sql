@@ -0,0 +1,20 @@
+-- Timeouts e limites de conexão com o upstream; 0 herda (rota > pool > padrão do gateway)
+ALTER TABLE upstream_pools
+    ADD COLUMN IF NOT EXISTS connect_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tls_handshake_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS response_header_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS request_timeout_ms INTEGER NOT NULL DEFAULT 0,     -- prazo total, até o fim do corpo
+    ADD COLUMN IF NOT EXISTS max_idle_conns_per_host INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_conns_per_host INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS idle_conn_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS keep_alive_ms INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS connect_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tls_handshake_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS response_header_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS request_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_idle_conns_per_host INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_conns_per_host INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS idle_conn_timeout_ms INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS keep_alive_ms INTEGER NOT NULL DEFAULT 0;
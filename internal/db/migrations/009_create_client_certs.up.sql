@@ -0,0 +1,12 @@
+-- Certificados de cliente (mTLS) que autenticam como uma API Key; cada linha
+-- identifica o certificado pelo fingerprint SHA-256 ou pelo subject DN
+CREATE TABLE IF NOT EXISTS client_certs (
+    id SERIAL PRIMARY KEY,
+    api_key_id INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    fingerprint TEXT UNIQUE,                  -- SHA-256 do DER em hex minúsculo
+    subject TEXT UNIQUE,                      -- DN como em pkix.Name.String(), ex.: CN=parceiro,O=Acme
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((fingerprint IS NULL) <> (subject IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS client_certs_api_key_id_idx ON client_certs (api_key_id);
@@ -0,0 +1,5 @@
+ALTER TABLE routes
+    DROP COLUMN IF EXISTS claim_headers,
+    DROP COLUMN IF EXISTS auth_methods;
+
+DROP TABLE IF EXISTS jwt_subjects;
@@ -0,0 +1,15 @@
+-- Consumidores autenticados por JWT: o valor da claim configurada
+-- (AEGIS_JWT_CONSUMER_CLAIM, sub por padrão) aponta para uma API Key
+CREATE TABLE IF NOT EXISTS jwt_subjects (
+    id SERIAL PRIMARY KEY,
+    api_key_id INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    subject TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS jwt_subjects_api_key_id_idx ON jwt_subjects (api_key_id);
+
+-- Autenticação por rota: métodos aceitos e claims repassadas como headers
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS auth_methods TEXT NOT NULL DEFAULT '',        -- separados por vírgula; vazio usa AEGIS_AUTH_METHODS
+    ADD COLUMN IF NOT EXISTS claim_headers JSONB NOT NULL DEFAULT '{}';    -- header → claim
@@ -0,0 +1,153 @@
+package gtwhttp
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+type clientCertResponse struct {
+	ID          int64     `json:"id"`
+	APIKeyID    int64     `json:"api_key_id"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// linkClientCertRequest aceita exatamente um dos campos; certificate (PEM) é
+// convertido no fingerprint
+type linkClientCertRequest struct {
+	Fingerprint string `json:"fingerprint"`
+	Subject     string `json:"subject"`
+	Certificate string `json:"certificate"`
+}
+
+func newClientCertResponse(c *middleware.ClientCert) clientCertResponse {
+	return clientCertResponse{
+		ID:          c.ID,
+		APIKeyID:    c.APIKeyID,
+		Fingerprint: c.Fingerprint,
+		Subject:     c.Subject,
+		CreatedAt:   c.CreatedAt,
+	}
+}
+
+// POST /admin/keys/{id}/certs
+func (a *AdminHandler) LinkClientCert(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req linkClientCertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	set := 0
+	for _, v := range []string{req.Fingerprint, req.Subject, req.Certificate} {
+		if strings.TrimSpace(v) != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		http.Error(w, "exactly one of fingerprint, subject or certificate is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	fingerprint := ""
+	switch {
+	case req.Fingerprint != "":
+		if fingerprint = middleware.NormalizeFingerprint(req.Fingerprint); fingerprint == "" {
+			http.Error(w, "fingerprint must be a SHA-256 in hex", http.StatusUnprocessableEntity)
+			return
+		}
+	case req.Certificate != "":
+		block, _ := pem.Decode([]byte(req.Certificate))
+		if block == nil || block.Type != "CERTIFICATE" {
+			http.Error(w, "certificate must be PEM encoded", http.StatusUnprocessableEntity)
+			return
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			http.Error(w, "invalid certificate", http.StatusUnprocessableEntity)
+			return
+		}
+		fingerprint = middleware.CertFingerprint(cert)
+	}
+
+	if _, err := a.Store.FindByID(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	c, err := a.Store.LinkClientCert(r.Context(), id, fingerprint, strings.TrimSpace(req.Subject))
+	if err != nil {
+		writeClientCertError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newClientCertResponse(c))
+}
+
+// GET /admin/keys/{id}/certs
+func (a *AdminHandler) ListClientCerts(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := a.Store.FindByID(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	list, err := a.Store.ListClientCerts(r.Context(), id)
+	if err != nil {
+		writeClientCertError(w, err)
+		return
+	}
+
+	resp := make([]clientCertResponse, 0, len(list))
+	for _, c := range list {
+		resp = append(resp, newClientCertResponse(c))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DELETE /admin/keys/{id}/certs/{cert_id}
+func (a *AdminHandler) UnlinkClientCert(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	certID, ok := pathInt(w, r, "cert_id")
+	if !ok {
+		return
+	}
+
+	if err := a.Store.UnlinkClientCert(r.Context(), id, certID); err != nil {
+		writeClientCertError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeClientCertError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, middleware.ErrClientCertNotFound):
+		http.Error(w, "client certificate not found", http.StatusNotFound)
+	case errors.Is(err, middleware.ErrClientCertExists):
+		http.Error(w, "client certificate already linked", http.StatusConflict)
+	default:
+		slog.Error("client cert store failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,99 @@
+package gtwhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestAdminClientCerts(t *testing.T) {
+	admin := newTestAdmin(t)
+
+	var key apiKeyResponse
+	if rec := admin.do(http.MethodPost, "/admin/keys", `{"name":"partner"}`, &key); rec.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %q", rec.Code, rec.Body.String())
+	}
+	certs := fmt.Sprintf("/admin/keys/%d/certs", key.ID)
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "partner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &certKey.PublicKey, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	certPEM, _ := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+
+	var byCert, bySubject clientCertResponse
+	if rec := admin.do(http.MethodPost, certs, `{"certificate":`+string(certPEM)+`}`, &byCert); rec.Code != http.StatusCreated {
+		t.Fatalf("link certificate: %d %q", rec.Code, rec.Body.String())
+	}
+	if byCert.Fingerprint != middleware.CertFingerprint(cert) || byCert.Subject != "" {
+		t.Fatalf("expected the certificate stored by fingerprint, got %+v", byCert)
+	}
+	if rec := admin.do(http.MethodPost, certs, `{"subject":"CN=partner,O=Acme"}`, &bySubject); rec.Code != http.StatusCreated {
+		t.Fatalf("link subject: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// O fingerprint no formato do openssl é o mesmo vínculo
+	colons := ""
+	for i := 0; i < len(byCert.Fingerprint); i += 2 {
+		if i > 0 {
+			colons += ":"
+		}
+		colons += byCert.Fingerprint[i : i+2]
+	}
+	for body, want := range map[string]int{
+		`{"fingerprint":"` + colons + `"}`:                  http.StatusConflict,
+		`{"fingerprint":"abc"}`:                             http.StatusUnprocessableEntity,
+		`{"certificate":"not pem"}`:                         http.StatusUnprocessableEntity,
+		`{"subject":"CN=x","fingerprint":"` + colons + `"}`: http.StatusUnprocessableEntity,
+		`{}`: http.StatusUnprocessableEntity,
+		`{`:  http.StatusBadRequest,
+	} {
+		if rec := admin.do(http.MethodPost, certs, body, nil); rec.Code != want {
+			t.Errorf("link %s: expected %d, got %d %q", body, want, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := admin.do(http.MethodPost, "/admin/keys/999999/certs", `{"subject":"CN=other"}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown key, got %d", rec.Code)
+	}
+
+	var list []clientCertResponse
+	if rec := admin.do(http.MethodGet, certs, "", &list); rec.Code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("expected two linked certificates, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("%s/%d", certs, byCert.ID), "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("unlink: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("%s/%d", certs, byCert.ID), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 unlinking twice, got %d", rec.Code)
+	}
+	// O vínculo é da chave: pelo id de outra chave não remove
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("/admin/keys/999999/certs/%d", bySubject.ID), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 unlinking through another key, got %d", rec.Code)
+	}
+	if rec := admin.do(http.MethodGet, certs, "", &list); rec.Code != http.StatusOK || len(list) != 1 || list[0].ID != bySubject.ID {
+		t.Fatalf("expected only the subject link left, got %+v", list)
+	}
+}
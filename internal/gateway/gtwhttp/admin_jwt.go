@@ -0,0 +1,118 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+type jwtSubjectResponse struct {
+	ID        int64     `json:"id"`
+	APIKeyID  int64     `json:"api_key_id"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type linkJWTSubjectRequest struct {
+	Subject string `json:"subject"`
+}
+
+func newJWTSubjectResponse(js *middleware.JWTSubject) jwtSubjectResponse {
+	return jwtSubjectResponse{
+		ID:        js.ID,
+		APIKeyID:  js.APIKeyID,
+		Subject:   js.Subject,
+		CreatedAt: js.CreatedAt,
+	}
+}
+
+// POST /admin/keys/{id}/jwt_subjects
+func (a *AdminHandler) LinkJWTSubject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req linkJWTSubjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	subject := strings.TrimSpace(req.Subject)
+	if subject == "" {
+		http.Error(w, "subject is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if _, err := a.Store.FindByID(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	js, err := a.Store.LinkJWTSubject(r.Context(), id, subject)
+	if err != nil {
+		writeJWTSubjectError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newJWTSubjectResponse(js))
+}
+
+// GET /admin/keys/{id}/jwt_subjects
+func (a *AdminHandler) ListJWTSubjects(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := a.Store.FindByID(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	list, err := a.Store.ListJWTSubjects(r.Context(), id)
+	if err != nil {
+		writeJWTSubjectError(w, err)
+		return
+	}
+
+	resp := make([]jwtSubjectResponse, 0, len(list))
+	for _, js := range list {
+		resp = append(resp, newJWTSubjectResponse(js))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DELETE /admin/keys/{id}/jwt_subjects/{subject_id}
+func (a *AdminHandler) UnlinkJWTSubject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	subjectID, ok := pathInt(w, r, "subject_id")
+	if !ok {
+		return
+	}
+
+	if err := a.Store.UnlinkJWTSubject(r.Context(), id, subjectID); err != nil {
+		writeJWTSubjectError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJWTSubjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, middleware.ErrJWTSubjectNotFound):
+		http.Error(w, "jwt subject not found", http.StatusNotFound)
+	case errors.Is(err, middleware.ErrJWTSubjectExists):
+		http.Error(w, "jwt subject already linked", http.StatusConflict)
+	default:
+		slog.Error("jwt subject store failed", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,65 @@
+package gtwhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAdminJWTSubjects(t *testing.T) {
+	admin := newTestAdmin(t)
+
+	var key apiKeyResponse
+	if rec := admin.do(http.MethodPost, "/admin/keys", `{"name":"partner"}`, &key); rec.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %q", rec.Code, rec.Body.String())
+	}
+	subjects := fmt.Sprintf("/admin/keys/%d/jwt_subjects", key.ID)
+
+	var linked jwtSubjectResponse
+	if rec := admin.do(http.MethodPost, subjects, `{"subject":" client-42 "}`, &linked); rec.Code != http.StatusCreated {
+		t.Fatalf("link subject: %d %q", rec.Code, rec.Body.String())
+	}
+	if linked.Subject != "client-42" || linked.APIKeyID != key.ID {
+		t.Fatalf("unexpected link %+v", linked)
+	}
+
+	got, err := admin.handler.Store.FindByJWTSubject(context.Background(), "client-42")
+	if err != nil || got.ID != key.ID {
+		t.Fatalf("expected the subject to resolve to key %d, got %+v (%v)", key.ID, got, err)
+	}
+
+	for body, want := range map[string]int{
+		`{"subject":"client-42"}`: http.StatusConflict,
+		`{"subject":"  "}`:        http.StatusUnprocessableEntity,
+		`{`:                       http.StatusBadRequest,
+	} {
+		if rec := admin.do(http.MethodPost, subjects, body, nil); rec.Code != want {
+			t.Errorf("link %s: expected %d, got %d %q", body, want, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := admin.do(http.MethodPost, "/admin/keys/999999/jwt_subjects", `{"subject":"other"}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown key, got %d", rec.Code)
+	}
+	if rec := admin.do(http.MethodGet, "/admin/keys/999999/jwt_subjects", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 listing an unknown key, got %d", rec.Code)
+	}
+
+	var list []jwtSubjectResponse
+	if rec := admin.do(http.MethodGet, subjects, "", &list); rec.Code != http.StatusOK || len(list) != 1 || list[0].ID != linked.ID {
+		t.Fatalf("expected the linked subject, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("/admin/keys/999999/jwt_subjects/%d", linked.ID), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 unlinking through another key, got %d", rec.Code)
+	}
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("%s/%d", subjects, linked.ID), "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("unlink: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := admin.do(http.MethodDelete, fmt.Sprintf("%s/%d", subjects, linked.ID), "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 unlinking twice, got %d", rec.Code)
+	}
+	if rec := admin.do(http.MethodGet, subjects, "", &list); rec.Code != http.StatusOK || len(list) != 0 {
+		t.Fatalf("expected no subjects left, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,210 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+type apiKeyResponse struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	KeyHash        string    `json:"key_hash"`
+	Key            string    `json:"key,omitempty"`
+	UpstreamHost   string    `json:"upstream_host"`
+	Active         bool      `json:"is_active"`
+	MonthlyQuota   int       `json:"monthly_quota"`
+	RateLimitRPS   float64   `json:"rate_limit_rps"`
+	RateLimitBurst int       `json:"rate_limit_burst"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type createAPIKeyRequest struct {
+	Name           string  `json:"name"`
+	UpstreamHost   string  `json:"upstream_host"`
+	MonthlyQuota   int     `json:"monthly_quota"`
+	RateLimitRPS   float64 `json:"rate_limit_rps"`
+	RateLimitBurst int     `json:"rate_limit_burst"`
+}
+
+type updateAPIKeyRequest struct {
+	Name           *string  `json:"name"`
+	UpstreamHost   *string  `json:"upstream_host"`
+	MonthlyQuota   *int     `json:"monthly_quota"`
+	RateLimitRPS   *float64 `json:"rate_limit_rps"`
+	RateLimitBurst *int     `json:"rate_limit_burst"`
+	Active         *bool    `json:"is_active"`
+}
+
+func newAPIKeyResponse(k *middleware.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:             k.ID,
+		Name:           k.Name,
+		KeyHash:        k.KeyHash,
+		UpstreamHost:   k.UpstreamHost,
+		Active:         k.Active,
+		MonthlyQuota:   k.MonthlyQuota,
+		RateLimitRPS:   k.RateLimitRPS,
+		RateLimitBurst: k.RateLimitBurst,
+		CreatedAt:      k.CreatedAt,
+	}
+}
+
+// POST /admin/keys
+func (a *AdminHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusUnprocessableEntity)
+		return
+	}
+	// Limites omitidos ou zerados usam o padrão (10000 req/mês, 5 req/s, burst 10)
+	if req.MonthlyQuota < 0 || req.RateLimitRPS < 0 || req.RateLimitBurst < 0 {
+		http.Error(w, "limits must not be negative", http.StatusUnprocessableEntity)
+		return
+	}
+
+	k, rawKey, err := a.Store.Create(r.Context(), middleware.CreateAPIKeyParams{
+		Name:           req.Name,
+		UpstreamHost:   strings.TrimSpace(req.UpstreamHost),
+		MonthlyQuota:   req.MonthlyQuota,
+		RateLimitRPS:   req.RateLimitRPS,
+		RateLimitBurst: req.RateLimitBurst,
+	})
+	if err != nil {
+		slog.Error("create api key failed", "err", err)
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+
+	// A chave em claro só é devolvida aqui; no banco fica apenas o hash
+	resp := newAPIKeyResponse(k)
+	resp.Key = rawKey
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// GET /admin/keys
+func (a *AdminHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.Store.List(r.Context())
+	if err != nil {
+		slog.Error("list api keys failed", "err", err)
+		http.Error(w, "failed to list api keys", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, newAPIKeyResponse(k))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /admin/keys/{id}
+func (a *AdminHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	k, err := a.Store.FindByID(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIKeyResponse(k))
+}
+
+// PATCH /admin/keys/{id}
+func (a *AdminHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "name must not be empty", http.StatusUnprocessableEntity)
+		return
+	}
+	// Mesma regra da criação: zero volta ao limite padrão, negativo é inválido
+	if (req.MonthlyQuota != nil && *req.MonthlyQuota < 0) ||
+		(req.RateLimitRPS != nil && *req.RateLimitRPS < 0) ||
+		(req.RateLimitBurst != nil && *req.RateLimitBurst < 0) {
+		http.Error(w, "limits must not be negative", http.StatusUnprocessableEntity)
+		return
+	}
+
+	k, err := a.Store.Update(r.Context(), id, middleware.UpdateAPIKeyParams{
+		Name:           req.Name,
+		UpstreamHost:   req.UpstreamHost,
+		MonthlyQuota:   req.MonthlyQuota,
+		RateLimitRPS:   req.RateLimitRPS,
+		RateLimitBurst: req.RateLimitBurst,
+		Active:         req.Active,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIKeyResponse(k))
+}
+
+// DELETE /admin/keys/{id}
+func (a *AdminHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.Store.Delete(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	return pathInt(w, r, "id")
+}
+
+func pathInt(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, middleware.ErrAPIKeyNotFound) {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+	slog.Error("api key store failed", "err", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error writing response", "err", err)
+	}
+}
@@ -0,0 +1,94 @@
+package gtwhttp
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestAdminAPIKeys(t *testing.T) {
+	admin := newTestAdmin(t)
+
+	var created apiKeyResponse
+	rec := admin.do(http.MethodPost, "/admin/keys", `{"name":" orders ","upstream_host":"http://orders","monthly_quota":100}`, &created)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %q", rec.Code, rec.Body.String())
+	}
+	if created.Key == "" || created.KeyHash != middleware.HashKey(created.Key) || created.Name != "orders" || created.MonthlyQuota != 100 {
+		t.Fatalf("unexpected created key %+v", created)
+	}
+	key := fmt.Sprintf("/admin/keys/%d", created.ID)
+
+	for body, want := range map[string]int{
+		`{"name":""}`:                        http.StatusUnprocessableEntity,
+		`{"name":"x","monthly_quota":-1}`:    http.StatusUnprocessableEntity,
+		`{"name":"x","rate_limit_rps":-0.5}`: http.StatusUnprocessableEntity,
+		`{`:                                  http.StatusBadRequest,
+	} {
+		if rec := admin.do(http.MethodPost, "/admin/keys", body, nil); rec.Code != want {
+			t.Errorf("create %s: expected %d, got %d", body, want, rec.Code)
+		}
+	}
+
+	// A chave em claro só aparece na criação
+	var got apiKeyResponse
+	if rec := admin.do(http.MethodGet, key, "", &got); rec.Code != http.StatusOK || got.Key != "" || got.ID != created.ID {
+		t.Fatalf("get: %d %q", rec.Code, rec.Body.String())
+	}
+	var list []apiKeyResponse
+	if rec := admin.do(http.MethodGet, "/admin/keys", "", &list); rec.Code != http.StatusOK || len(list) != 1 {
+		t.Fatalf("list: %d %q", rec.Code, rec.Body.String())
+	}
+
+	var patched apiKeyResponse
+	rec = admin.do(http.MethodPatch, key, `{"monthly_quota":2500,"is_active":false}`, &patched)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: %d %q", rec.Code, rec.Body.String())
+	}
+	if patched.MonthlyQuota != 2500 || patched.Active || patched.Name != "orders" || patched.UpstreamHost != "http://orders" {
+		t.Fatalf("expected only quota and is_active to change, got %+v", patched)
+	}
+	if rec := admin.do(http.MethodPatch, key, `{"name":"orders-v2","rate_limit_rps":20,"rate_limit_burst":40}`, &patched); rec.Code != http.StatusOK {
+		t.Fatalf("patch: %d %q", rec.Code, rec.Body.String())
+	}
+	if patched.Name != "orders-v2" || patched.RateLimitRPS != 20 || patched.RateLimitBurst != 40 || patched.MonthlyQuota != 2500 {
+		t.Fatalf("unexpected patched key %+v", patched)
+	}
+
+	// Zero volta ao padrão, como na criação
+	if rec := admin.do(http.MethodPatch, key, `{"monthly_quota":0,"rate_limit_rps":0,"rate_limit_burst":0}`, &patched); rec.Code != http.StatusOK {
+		t.Fatalf("patch: %d %q", rec.Code, rec.Body.String())
+	}
+	if patched.MonthlyQuota != 10000 || patched.RateLimitRPS != 5 || patched.RateLimitBurst != 10 {
+		t.Fatalf("expected zeroed limits back to the defaults, got %+v", patched)
+	}
+
+	for body, want := range map[string]int{
+		`{"name":" "}`:            http.StatusUnprocessableEntity,
+		`{"monthly_quota":-1}`:    http.StatusUnprocessableEntity,
+		`{"rate_limit_burst":-1}`: http.StatusUnprocessableEntity,
+		`{`:                       http.StatusBadRequest,
+	} {
+		if rec := admin.do(http.MethodPatch, key, body, nil); rec.Code != want {
+			t.Errorf("patch %s: expected %d, got %d", body, want, rec.Code)
+		}
+	}
+
+	if rec := admin.do(http.MethodDelete, key, "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %q", rec.Code, rec.Body.String())
+	}
+	for _, c := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPatch, `{"is_active":true}`},
+		{http.MethodDelete, ""},
+	} {
+		if rec := admin.do(c.method, key, c.body, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s on a deleted key: expected 404, got %d", c.method, rec.Code)
+		}
+	}
+	if rec := admin.do(http.MethodGet, "/admin/keys/abc", "", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,357 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
+)
+
+type poolTargetResponse struct {
+	ID                int64  `json:"id"`
+	URL               string `json:"url"`
+	Weight            int    `json:"weight"`
+	Healthy           bool   `json:"healthy"`
+	ActiveConnections int64  `json:"active_connections"`
+}
+
+type healthCheckJSON struct {
+	Path               string `json:"path"`
+	IntervalSeconds    int    `json:"interval_seconds"`
+	TimeoutSeconds     int    `json:"timeout_seconds"`
+	ExpectedStatus     int    `json:"expected_status"`
+	HealthyThreshold   int    `json:"healthy_threshold"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold"`
+}
+
+type poolResponse struct {
+	ID          int64                `json:"id"`
+	Name        string               `json:"name"`
+	Upstream    string               `json:"upstream"`
+	Strategy    string               `json:"strategy"`
+	HashHeader  string               `json:"hash_header"`
+	HealthCheck *healthCheckJSON     `json:"health_check"`
+	Transport   *transportJSON       `json:"transport"`
+	Targets     []poolTargetResponse `json:"targets"`
+}
+
+type createPoolRequest struct {
+	Name        string           `json:"name"`
+	Strategy    string           `json:"strategy"`
+	HashHeader  string           `json:"hash_header"`
+	HealthCheck *healthCheckJSON `json:"health_check"`
+	Transport   *transportJSON   `json:"transport"`
+}
+
+type targetHealthResponse struct {
+	Pool                 string     `json:"pool"`
+	TargetID             int64      `json:"target_id"`
+	URL                  string     `json:"url"`
+	Checked              bool       `json:"checked"`
+	Healthy              bool       `json:"healthy"`
+	ConsecutiveSuccesses int        `json:"consecutive_successes"`
+	ConsecutiveFailures  int        `json:"consecutive_failures"`
+	LastCheck            *time.Time `json:"last_check,omitempty"`
+	LastError            string     `json:"last_error,omitempty"`
+}
+
+type addPoolTargetRequest struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+func newPoolResponse(p *proxy.Pool) poolResponse {
+	targets := make([]poolTargetResponse, 0, len(p.Targets))
+	for _, t := range p.Targets {
+		targets = append(targets, poolTargetResponse{
+			ID:                t.ID,
+			URL:               t.URL.String(),
+			Weight:            t.Weight,
+			Healthy:           t.Healthy(),
+			ActiveConnections: t.ActiveConns(),
+		})
+	}
+
+	resp := poolResponse{
+		ID:         p.ID,
+		Name:       p.Name,
+		Upstream:   "pool://" + p.Name,
+		Strategy:   p.Strategy,
+		HashHeader: p.HashHeader,
+		Transport:  newTransportJSON(p.Transport),
+		Targets:    targets,
+	}
+	if hc := p.HealthCheck; hc.Enabled() {
+		resp.HealthCheck = &healthCheckJSON{
+			Path:               hc.Path,
+			IntervalSeconds:    int(hc.Interval / time.Second),
+			TimeoutSeconds:     int(hc.Timeout / time.Second),
+			ExpectedStatus:     hc.ExpectedStatus,
+			HealthyThreshold:   hc.HealthyThreshold,
+			UnhealthyThreshold: hc.UnhealthyThreshold,
+		}
+	}
+	return resp
+}
+
+// healthCheckConfig valida o JSON; nil desativa o health check
+func healthCheckConfig(req *healthCheckJSON) (proxy.HealthCheckConfig, bool) {
+	if req == nil {
+		return proxy.HealthCheckConfig{}, true
+	}
+
+	if req.Path != "" && !strings.HasPrefix(req.Path, "/") {
+		return proxy.HealthCheckConfig{}, false
+	}
+	if req.IntervalSeconds < 0 || req.TimeoutSeconds < 0 || req.HealthyThreshold < 0 || req.UnhealthyThreshold < 0 {
+		return proxy.HealthCheckConfig{}, false
+	}
+	if req.ExpectedStatus != 0 && (req.ExpectedStatus < 100 || req.ExpectedStatus > 599) {
+		return proxy.HealthCheckConfig{}, false
+	}
+
+	return proxy.HealthCheckConfig{
+		Path:               req.Path,
+		Interval:           time.Duration(req.IntervalSeconds) * time.Second,
+		Timeout:            time.Duration(req.TimeoutSeconds) * time.Second,
+		ExpectedStatus:     req.ExpectedStatus,
+		HealthyThreshold:   req.HealthyThreshold,
+		UnhealthyThreshold: req.UnhealthyThreshold,
+	}, true
+}
+
+// POST /admin/pools
+func (a *AdminHandler) CreatePool(w http.ResponseWriter, r *http.Request) {
+	var req createPoolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.ContainsAny(req.Name, "/ ") {
+		http.Error(w, "name is required and must not contain spaces or slashes", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if req.Strategy == "" {
+		req.Strategy = proxy.StrategyRoundRobin
+	}
+	switch req.Strategy {
+	case proxy.StrategyRoundRobin, proxy.StrategyWeightedRoundRobin, proxy.StrategyLeastConnections, proxy.StrategyConsistentHash:
+	default:
+		http.Error(w, "invalid strategy", http.StatusUnprocessableEntity)
+		return
+	}
+
+	hc, ok := healthCheckConfig(req.HealthCheck)
+	if !ok {
+		http.Error(w, "invalid health_check", http.StatusUnprocessableEntity)
+		return
+	}
+
+	tc, ok := transportConfig(req.Transport)
+	if !ok {
+		http.Error(w, "invalid transport", http.StatusUnprocessableEntity)
+		return
+	}
+
+	id, err := a.Pools.CreatePool(r.Context(), req.Name, req.Strategy, strings.TrimSpace(req.HashHeader), hc, tc)
+	if err != nil {
+		slog.Error("create pool failed", "err", err)
+		http.Error(w, "failed to create pool", http.StatusInternalServerError)
+		return
+	}
+
+	if p, ok := a.findPool(id); ok {
+		writeJSON(w, http.StatusCreated, newPoolResponse(p))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GET /admin/pools
+func (a *AdminHandler) ListPools(w http.ResponseWriter, r *http.Request) {
+	pools := a.Pools.Pools()
+	sort.Slice(pools, func(i, j int) bool { return pools[i].ID < pools[j].ID })
+
+	resp := make([]poolResponse, 0, len(pools))
+	for _, p := range pools {
+		resp = append(resp, newPoolResponse(p))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DELETE /admin/pools/{id}
+func (a *AdminHandler) DeletePool(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.Pools.DeletePool(r.Context(), id); err != nil {
+		writePoolError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /admin/pools/{id}/health_check
+func (a *AdminHandler) SetPoolHealthCheck(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req healthCheckJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	hc, ok := healthCheckConfig(&req)
+	if !ok {
+		http.Error(w, "invalid health_check", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := a.Pools.SetHealthCheck(r.Context(), id, hc); err != nil {
+		writePoolError(w, err)
+		return
+	}
+
+	if p, ok := a.findPool(id); ok {
+		writeJSON(w, http.StatusOK, newPoolResponse(p))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/pools/health
+func (a *AdminHandler) PoolsHealth(w http.ResponseWriter, r *http.Request) {
+	pools := a.Pools.Pools()
+	sort.Slice(pools, func(i, j int) bool { return pools[i].ID < pools[j].ID })
+
+	resp := []targetHealthResponse{}
+	for _, p := range pools {
+		for _, t := range p.Targets {
+			item := targetHealthResponse{
+				Pool:     p.Name,
+				TargetID: t.ID,
+				URL:      t.URL.String(),
+				Checked:  p.HealthCheck.Enabled(),
+				Healthy:  t.Healthy(),
+			}
+			if t.Health != nil {
+				st := t.Health.Status()
+				item.ConsecutiveSuccesses = st.ConsecutiveSuccesses
+				item.ConsecutiveFailures = st.ConsecutiveFailures
+				item.LastError = st.LastError
+				if !st.LastCheck.IsZero() {
+					item.LastCheck = &st.LastCheck
+				}
+			}
+			resp = append(resp, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /admin/pools/{id}/targets
+func (a *AdminHandler) AddPoolTarget(w http.ResponseWriter, r *http.Request) {
+	poolID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req addPoolTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	if !validUpstream(req.URL) {
+		http.Error(w, "invalid url", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.Weight < 0 {
+		http.Error(w, "weight must be positive", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if _, ok := a.findPool(poolID); !ok {
+		writePoolError(w, proxy.ErrPoolNotFound)
+		return
+	}
+
+	if _, err := a.Pools.AddTarget(r.Context(), poolID, strings.TrimSpace(req.URL), req.Weight); err != nil {
+		writePoolError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/pools/{id}/targets/{target_id}
+func (a *AdminHandler) RemovePoolTarget(w http.ResponseWriter, r *http.Request) {
+	poolID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	targetID, ok := pathInt(w, r, "target_id")
+	if !ok {
+		return
+	}
+
+	if err := a.Pools.RemoveTarget(r.Context(), poolID, targetID); err != nil {
+		writePoolError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type breakerResponse struct {
+	Upstream          string `json:"upstream"`
+	State             string `json:"state"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+// GET /admin/breakers
+func (a *AdminHandler) ListBreakers(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		OpenedTotal int64             `json:"opened_total"`
+		Breakers    []breakerResponse `json:"breakers"`
+	}{Breakers: []breakerResponse{}}
+
+	if a.Breakers != nil {
+		resp.OpenedTotal = a.Breakers.OpenedTotal()
+		for _, b := range a.Breakers.Status() {
+			resp.Breakers = append(resp.Breakers, breakerResponse{
+				Upstream:          b.Upstream,
+				State:             b.State.String(),
+				RetryAfterSeconds: int((b.RetryAfter + time.Second - 1) / time.Second),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *AdminHandler) findPool(id int64) (*proxy.Pool, bool) {
+	for _, p := range a.Pools.Pools() {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func writePoolError(w http.ResponseWriter, err error) {
+	if errors.Is(err, proxy.ErrPoolNotFound) || errors.Is(err, proxy.ErrTargetNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	slog.Error("pool store failed", "err", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
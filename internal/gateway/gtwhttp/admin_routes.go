@@ -0,0 +1,408 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
+)
+
+type routeResponse struct {
+	ID            int64             `json:"id"`
+	Name          string            `json:"name"`
+	PathPrefix    string            `json:"path_prefix"`
+	Methods       []string          `json:"methods"`
+	Host          string            `json:"host"`
+	Upstream      string            `json:"upstream"`
+	StripPrefix   bool              `json:"strip_prefix"`
+	RewritePrefix string            `json:"rewrite_prefix"`
+	Active        bool              `json:"is_active"`
+	Transport     *transportJSON    `json:"transport"`
+	AuthMethods   []string          `json:"auth_methods"`
+	ClaimHeaders  map[string]string `json:"claim_headers"`
+}
+
+type createRouteRequest struct {
+	Name          string            `json:"name"`
+	PathPrefix    string            `json:"path_prefix"`
+	Methods       []string          `json:"methods"`
+	Host          string            `json:"host"`
+	Upstream      string            `json:"upstream"`
+	StripPrefix   bool              `json:"strip_prefix"`
+	RewritePrefix string            `json:"rewrite_prefix"`
+	Transport     *transportJSON    `json:"transport"`
+	AuthMethods   []string          `json:"auth_methods"`
+	ClaimHeaders  map[string]string `json:"claim_headers"`
+}
+
+// updateRouteRequest altera apenas os campos enviados
+type updateRouteRequest struct {
+	Name          *string   `json:"name"`
+	PathPrefix    *string   `json:"path_prefix"`
+	Methods       *[]string `json:"methods"`
+	Host          *string   `json:"host"`
+	Upstream      *string   `json:"upstream"`
+	StripPrefix   *bool     `json:"strip_prefix"`
+	RewritePrefix *string   `json:"rewrite_prefix"`
+	Active        *bool     `json:"is_active"`
+}
+
+// routeAuthRequest é o corpo de PUT /admin/routes/{id}/auth
+type routeAuthRequest struct {
+	AuthMethods  []string          `json:"auth_methods"`
+	ClaimHeaders map[string]string `json:"claim_headers"`
+}
+
+func newRouteResponse(rt *proxy.Route) routeResponse {
+	methods := rt.Methods
+	if methods == nil {
+		methods = []string{}
+	}
+	authMethods := rt.AuthMethods
+	if authMethods == nil {
+		authMethods = []string{}
+	}
+	claimHeaders := rt.ClaimHeaders
+	if claimHeaders == nil {
+		claimHeaders = map[string]string{}
+	}
+	return routeResponse{
+		ID:            rt.ID,
+		Name:          rt.Name,
+		PathPrefix:    rt.PathPrefix,
+		Methods:       methods,
+		Host:          rt.Host,
+		Upstream:      rt.Upstream,
+		StripPrefix:   rt.StripPrefix,
+		RewritePrefix: rt.RewritePrefix,
+		Active:        rt.Active,
+		Transport:     newTransportJSON(rt.Transport),
+		AuthMethods:   authMethods,
+		ClaimHeaders:  claimHeaders,
+	}
+}
+
+// POST /admin/routes
+func (a *AdminHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
+	var req createRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusUnprocessableEntity)
+		return
+	}
+	if !strings.HasPrefix(req.PathPrefix, "/") {
+		http.Error(w, "path_prefix must start with /", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.RewritePrefix != "" && !strings.HasPrefix(req.RewritePrefix, "/") {
+		http.Error(w, "rewrite_prefix must start with /", http.StatusUnprocessableEntity)
+		return
+	}
+	if !validUpstream(req.Upstream) {
+		http.Error(w, "invalid upstream", http.StatusUnprocessableEntity)
+		return
+	}
+
+	tc, ok := transportConfig(req.Transport)
+	if !ok {
+		http.Error(w, "invalid transport", http.StatusUnprocessableEntity)
+		return
+	}
+	authMethods, claimHeaders, msg := a.routeAuth(req.AuthMethods, req.ClaimHeaders)
+	if msg != "" {
+		http.Error(w, msg, http.StatusUnprocessableEntity)
+		return
+	}
+
+	methods := make([]string, 0, len(req.Methods))
+	for _, m := range req.Methods {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+			methods = append(methods, m)
+		}
+	}
+
+	rt, err := a.Routes.Create(r.Context(), proxy.Route{
+		Name:          req.Name,
+		PathPrefix:    req.PathPrefix,
+		Methods:       methods,
+		Host:          strings.TrimSpace(req.Host),
+		Upstream:      strings.TrimSpace(req.Upstream),
+		StripPrefix:   req.StripPrefix,
+		RewritePrefix: req.RewritePrefix,
+		Transport:     tc,
+		AuthMethods:   authMethods,
+		ClaimHeaders:  claimHeaders,
+	})
+	if errors.Is(err, proxy.ErrRouteExists) {
+		http.Error(w, "route name already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("create route failed", "err", err)
+		http.Error(w, "failed to create route", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newRouteResponse(rt))
+}
+
+// GET /admin/routes
+func (a *AdminHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := a.Routes.List(r.Context())
+	if err != nil {
+		slog.Error("list routes failed", "err", err)
+		http.Error(w, "failed to list routes", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]routeResponse, 0, len(routes))
+	for _, rt := range routes {
+		resp = append(resp, newRouteResponse(rt))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /admin/routes/{id}
+func (a *AdminHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	rt, err := a.Routes.FindByID(r.Context(), id)
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRouteResponse(rt))
+}
+
+// PATCH /admin/routes/{id}
+func (a *AdminHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	trim := func(v *string) *string {
+		if v == nil {
+			return nil
+		}
+		t := strings.TrimSpace(*v)
+		return &t
+	}
+	req.Name, req.Host, req.Upstream = trim(req.Name), trim(req.Host), trim(req.Upstream)
+
+	if req.Name != nil && *req.Name == "" {
+		http.Error(w, "name must not be empty", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.PathPrefix != nil && !strings.HasPrefix(*req.PathPrefix, "/") {
+		http.Error(w, "path_prefix must start with /", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.RewritePrefix != nil && *req.RewritePrefix != "" && !strings.HasPrefix(*req.RewritePrefix, "/") {
+		http.Error(w, "rewrite_prefix must start with /", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.Upstream != nil && !validUpstream(*req.Upstream) {
+		http.Error(w, "invalid upstream", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.Methods != nil {
+		methods := make([]string, 0, len(*req.Methods))
+		for _, m := range *req.Methods {
+			if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+				methods = append(methods, m)
+			}
+		}
+		req.Methods = &methods
+	}
+
+	rt, err := a.Routes.Update(r.Context(), id, proxy.UpdateRouteParams{
+		Name:          req.Name,
+		PathPrefix:    req.PathPrefix,
+		Methods:       req.Methods,
+		Host:          req.Host,
+		Upstream:      req.Upstream,
+		StripPrefix:   req.StripPrefix,
+		RewritePrefix: req.RewritePrefix,
+		Active:        req.Active,
+	})
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRouteResponse(rt))
+}
+
+// DELETE /admin/routes/{id}
+func (a *AdminHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.Routes.Delete(r.Context(), id); err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /admin/routes/{id}/keys/{key_id}
+func (a *AdminHandler) GrantRoute(w http.ResponseWriter, r *http.Request) {
+	routeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	keyID, ok := pathInt(w, r, "key_id")
+	if !ok {
+		return
+	}
+
+	if _, err := a.Routes.FindByID(r.Context(), routeID); err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	if _, err := a.Store.FindByID(r.Context(), keyID); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if err := a.Routes.Grant(r.Context(), routeID, keyID); err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /admin/routes/{id}/auth
+func (a *AdminHandler) SetRouteAuth(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req routeAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	authMethods, claimHeaders, msg := a.routeAuth(req.AuthMethods, req.ClaimHeaders)
+	if msg != "" {
+		http.Error(w, msg, http.StatusUnprocessableEntity)
+		return
+	}
+
+	rt, err := a.Routes.SetAuth(r.Context(), id, authMethods, claimHeaders)
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRouteResponse(rt))
+}
+
+// reservedClaimHeaders não podem receber claims: hop-by-hop são descartados
+// pelo proxy e os demais carregam credenciais, roteamento ou rastreio
+var reservedClaimHeaders = []string{
+	"Authorization", "Proxy-Authorization", "X-Api-Key", "Cookie", "Host",
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Content-Length", "Content-Type", "Content-Encoding",
+	"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto",
+	"X-Request-Id", "X-Content-Id", "Traceparent", "Tracestate",
+}
+
+// routeAuth valida os métodos e normaliza os nomes dos headers; devolve a
+// mensagem do erro de validação. Um método fora da cadeia do gateway deixaria
+// a rota sem autenticador, então só os habilitados são aceitos
+func (a *AdminHandler) routeAuth(methods []string, claimHeaders map[string]string) ([]string, map[string]string, string) {
+	var valid []string
+	for _, m := range methods {
+		m = strings.TrimSpace(m)
+		if !slices.Contains(middleware.AuthMethods, m) {
+			return nil, nil, "auth_methods must only contain " + strings.Join(middleware.AuthMethods, ", ")
+		}
+		if !slices.Contains(a.AuthMethods, m) {
+			return nil, nil, "auth method " + m + " is not enabled on the gateway (enabled: " + strings.Join(a.AuthMethods, ", ") + ")"
+		}
+		if !slices.Contains(valid, m) {
+			valid = append(valid, m)
+		}
+	}
+
+	headers := make(map[string]string, len(claimHeaders))
+	for header, claim := range claimHeaders {
+		header = strings.TrimSpace(header)
+		if header == "" || strings.ContainsAny(header, " :\t") || strings.TrimSpace(claim) == "" {
+			return nil, nil, "claim_headers must map header names to claim names"
+		}
+		header = textproto.CanonicalMIMEHeaderKey(header)
+		if slices.Contains(reservedClaimHeaders, header) {
+			return nil, nil, "claim_headers must not set reserved header " + header
+		}
+		headers[header] = strings.TrimSpace(claim)
+	}
+	return valid, headers, ""
+}
+
+// DELETE /admin/routes/{id}/keys/{key_id}
+func (a *AdminHandler) RevokeRoute(w http.ResponseWriter, r *http.Request) {
+	routeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	keyID, ok := pathInt(w, r, "key_id")
+	if !ok {
+		return
+	}
+
+	if err := a.Routes.Revoke(r.Context(), routeID, keyID); err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeRouteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, proxy.ErrRouteNotFound):
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	case errors.Is(err, proxy.ErrRouteExists):
+		http.Error(w, "route name already in use", http.StatusConflict)
+		return
+	}
+	slog.Error("route store failed", "err", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func validUpstream(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	return err == nil && u.Host != ""
+}
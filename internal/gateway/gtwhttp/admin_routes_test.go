@@ -0,0 +1,120 @@
+package gtwhttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteAuth(t *testing.T) {
+	// Gateway sem JWKS: jwt não está na cadeia
+	a := &AdminHandler{AuthMethods: []string{"api_key", "client_cert"}}
+
+	methods, headers, msg := a.routeAuth([]string{" client_cert", "api_key", "client_cert"}, map[string]string{"x-consumer-id": " sub "})
+	if msg != "" {
+		t.Fatalf("expected valid auth, got %q", msg)
+	}
+	if strings.Join(methods, ",") != "client_cert,api_key" {
+		t.Fatalf("expected deduplicated methods, got %v", methods)
+	}
+	if headers["X-Consumer-Id"] != "sub" {
+		t.Fatalf("expected the canonical header name, got %v", headers)
+	}
+
+	for _, c := range []struct {
+		methods []string
+		headers map[string]string
+		want    string
+	}{
+		{[]string{"jwt"}, nil, "auth method jwt is not enabled on the gateway"},
+		{[]string{"basic"}, nil, "auth_methods must only contain"},
+		{nil, map[string]string{"X Bad": "sub"}, "claim_headers must map"},
+		{nil, map[string]string{"X-Consumer-Id": ""}, "claim_headers must map"},
+		{nil, map[string]string{"authorization": "sub"}, "reserved header Authorization"},
+		{nil, map[string]string{"x-api-key": "sub"}, "reserved header X-Api-Key"},
+		{nil, map[string]string{"Connection": "sub"}, "reserved header Connection"},
+		{nil, map[string]string{"Host": "sub"}, "reserved header Host"},
+	} {
+		if _, _, msg := a.routeAuth(c.methods, c.headers); !strings.Contains(msg, c.want) {
+			t.Errorf("%v %v: expected %q, got %q", c.methods, c.headers, c.want, msg)
+		}
+	}
+}
+
+func TestAdminUpdateRoute(t *testing.T) {
+	admin := newTestAdmin(t)
+
+	var key apiKeyResponse
+	if rec := admin.do(http.MethodPost, "/admin/keys", `{"name":"billing"}`, &key); rec.Code != http.StatusCreated {
+		t.Fatalf("create key: %d %q", rec.Code, rec.Body.String())
+	}
+	var rt routeResponse
+	if rec := admin.do(http.MethodPost, "/admin/routes", `{"name":"billing","path_prefix":"/billing","upstream":"http://billing:8080","methods":["GET"]}`, &rt); rec.Code != http.StatusCreated {
+		t.Fatalf("create route: %d %q", rec.Code, rec.Body.String())
+	}
+	route := fmt.Sprintf("/admin/routes/%d", rt.ID)
+	if rec := admin.do(http.MethodPut, fmt.Sprintf("%s/keys/%d", route, key.ID), "", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("grant: %d %q", rec.Code, rec.Body.String())
+	}
+
+	matches := func() bool {
+		_, ok := admin.handler.Routes.Table().Match(httptest.NewRequest(http.MethodPost, "/v2/billing/invoices", nil))
+		return ok
+	}
+
+	var patched routeResponse
+	rec := admin.do(http.MethodPatch, route, `{"path_prefix":"/v2/billing","methods":["get","post"],"strip_prefix":true}`, &patched)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: %d %q", rec.Code, rec.Body.String())
+	}
+	if patched.ID != rt.ID || patched.PathPrefix != "/v2/billing" || strings.Join(patched.Methods, ",") != "GET,POST" ||
+		!patched.StripPrefix || patched.Upstream != "http://billing:8080" || patched.Name != "billing" {
+		t.Fatalf("unexpected patched route %+v", patched)
+	}
+	if !matches() {
+		t.Fatal("expected the route table to be reloaded with the new prefix")
+	}
+
+	// Desativar mantém a rota e as concessões; reativar volta a liberar a chave
+	if rec := admin.do(http.MethodPatch, route, `{"is_active":false}`, &patched); rec.Code != http.StatusOK || patched.Active {
+		t.Fatalf("deactivate: %d %q", rec.Code, rec.Body.String())
+	}
+	if matches() {
+		t.Fatal("expected an inactive route not to match")
+	}
+	if rec := admin.do(http.MethodPatch, route, `{"is_active":true}`, &patched); rec.Code != http.StatusOK || !patched.Active {
+		t.Fatalf("reactivate: %d %q", rec.Code, rec.Body.String())
+	}
+	if !matches() || !admin.handler.Routes.Table().Allowed(rt.ID, key.ID) {
+		t.Fatal("expected the reactivated route to keep its grant")
+	}
+
+	for body, want := range map[string]int{
+		`{"name":" "}`:              http.StatusUnprocessableEntity,
+		`{"path_prefix":"billing"}`: http.StatusUnprocessableEntity,
+		`{"rewrite_prefix":"v1"}`:   http.StatusUnprocessableEntity,
+		`{"upstream":""}`:           http.StatusUnprocessableEntity,
+		`{`:                         http.StatusBadRequest,
+	} {
+		if rec := admin.do(http.MethodPatch, route, body, nil); rec.Code != want {
+			t.Errorf("patch %s: expected %d, got %d", body, want, rec.Code)
+		}
+	}
+	if rec := admin.do(http.MethodPatch, "/admin/routes/999999", `{"is_active":false}`, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown route, got %d", rec.Code)
+	}
+
+	// routes.name é único: criar ou renomear para um nome em uso é conflito
+	if rec := admin.do(http.MethodPost, "/admin/routes", `{"name":"billing","path_prefix":"/other","upstream":"http://other:8080"}`, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 creating a duplicate name, got %d %q", rec.Code, rec.Body.String())
+	}
+	var other routeResponse
+	if rec := admin.do(http.MethodPost, "/admin/routes", `{"name":"other","path_prefix":"/other","upstream":"http://other:8080"}`, &other); rec.Code != http.StatusCreated {
+		t.Fatalf("create other route: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := admin.do(http.MethodPatch, fmt.Sprintf("/admin/routes/%d", other.ID), `{"name":"billing"}`, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 renaming to a name in use, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,51 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
+	"github.com/martinsdevv/aegis/internal/testdb"
+)
+
+// testAdmin serve as rotas /admin sobre um banco de teste, sem o token do operador
+type testAdmin struct {
+	t       *testing.T
+	handler *AdminHandler
+	mux     http.Handler
+}
+
+func newTestAdmin(t *testing.T) *testAdmin {
+	t.Helper()
+
+	db := testdb.Open(t)
+	h := NewAdminHandler(
+		middleware.NewAPIKeyStore(db, nil, time.Minute),
+		proxy.NewRouteStore(db),
+		proxy.NewPoolStore(db),
+		proxy.NewBreakers(proxy.BreakerConfig{}),
+		middleware.AuthMethods,
+	)
+	return &testAdmin{t: t, handler: h, mux: adminRoutes(h)}
+}
+
+// do envia body (JSON) e, quando out não é nil, decodifica a resposta
+func (a *testAdmin) do(method, path, body string, out any) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, req)
+
+	if out != nil && rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec
+}
@@ -0,0 +1,122 @@
+package gtwhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
+)
+
+// transportJSON expõe proxy.TransportConfig em milissegundos; zero herda
+type transportJSON struct {
+	ConnectTimeoutMS        int64 `json:"connect_timeout_ms"`
+	TLSHandshakeTimeoutMS   int64 `json:"tls_handshake_timeout_ms"`
+	ResponseHeaderTimeoutMS int64 `json:"response_header_timeout_ms"`
+	RequestTimeoutMS        int64 `json:"request_timeout_ms"`
+	MaxIdleConnsPerHost     int   `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost         int   `json:"max_conns_per_host"`
+	IdleConnTimeoutMS       int64 `json:"idle_conn_timeout_ms"`
+	KeepAliveMS             int64 `json:"keep_alive_ms"`
+}
+
+// newTransportJSON devolve nil quando nada foi configurado
+func newTransportJSON(tc proxy.TransportConfig) *transportJSON {
+	if tc.IsZero() {
+		return nil
+	}
+	return &transportJSON{
+		ConnectTimeoutMS:        tc.ConnectTimeout.Milliseconds(),
+		TLSHandshakeTimeoutMS:   tc.TLSHandshakeTimeout.Milliseconds(),
+		ResponseHeaderTimeoutMS: tc.ResponseHeaderTimeout.Milliseconds(),
+		RequestTimeoutMS:        tc.RequestTimeout.Milliseconds(),
+		MaxIdleConnsPerHost:     tc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:         tc.MaxConnsPerHost,
+		IdleConnTimeoutMS:       tc.IdleConnTimeout.Milliseconds(),
+		KeepAliveMS:             tc.KeepAlive.Milliseconds(),
+	}
+}
+
+// transportConfig valida o JSON; nil herda tudo
+func transportConfig(req *transportJSON) (proxy.TransportConfig, bool) {
+	if req == nil {
+		return proxy.TransportConfig{}, true
+	}
+
+	for _, v := range []int64{
+		req.ConnectTimeoutMS, req.TLSHandshakeTimeoutMS, req.ResponseHeaderTimeoutMS, req.RequestTimeoutMS,
+		req.IdleConnTimeoutMS, req.KeepAliveMS, int64(req.MaxIdleConnsPerHost), int64(req.MaxConnsPerHost),
+	} {
+		if v < 0 {
+			return proxy.TransportConfig{}, false
+		}
+	}
+
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+	return proxy.TransportConfig{
+		ConnectTimeout:        ms(req.ConnectTimeoutMS),
+		TLSHandshakeTimeout:   ms(req.TLSHandshakeTimeoutMS),
+		ResponseHeaderTimeout: ms(req.ResponseHeaderTimeoutMS),
+		RequestTimeout:        ms(req.RequestTimeoutMS),
+		MaxIdleConnsPerHost:   req.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       req.MaxConnsPerHost,
+		IdleConnTimeout:       ms(req.IdleConnTimeoutMS),
+		KeepAlive:             ms(req.KeepAliveMS),
+	}, true
+}
+
+func decodeTransport(w http.ResponseWriter, r *http.Request) (proxy.TransportConfig, bool) {
+	var req transportJSON
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return proxy.TransportConfig{}, false
+	}
+	tc, ok := transportConfig(&req)
+	if !ok {
+		http.Error(w, "invalid transport", http.StatusUnprocessableEntity)
+		return proxy.TransportConfig{}, false
+	}
+	return tc, true
+}
+
+// PUT /admin/pools/{id}/transport
+func (a *AdminHandler) SetPoolTransport(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	tc, ok := decodeTransport(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.Pools.SetTransport(r.Context(), id, tc); err != nil {
+		writePoolError(w, err)
+		return
+	}
+
+	if p, ok := a.findPool(id); ok {
+		writeJSON(w, http.StatusOK, newPoolResponse(p))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /admin/routes/{id}/transport
+func (a *AdminHandler) SetRouteTransport(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	tc, ok := decodeTransport(w, r)
+	if !ok {
+		return
+	}
+
+	rt, err := a.Routes.SetTransport(r.Context(), id, tc)
+	if err != nil {
+		writeRouteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRouteResponse(rt))
+}
@@ -5,14 +5,20 @@ import (
 	"net/http"
 
 	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/gateway/proxy"
 )
 
 type AdminHandler struct {
-	Store *middleware.APIKeyStore
+	Store    *middleware.APIKeyStore
+	Routes   *proxy.RouteStore
+	Pools    *proxy.PoolStore
+	Breakers *proxy.Breakers
+	// AuthMethods são os métodos habilitados no gateway, aceitos em auth_methods das rotas
+	AuthMethods []string
 }
 
-func NewAdminHandler(store *middleware.APIKeyStore) *AdminHandler {
-	return &AdminHandler{Store: store}
+func NewAdminHandler(store *middleware.APIKeyStore, routes *proxy.RouteStore, pools *proxy.PoolStore, breakers *proxy.Breakers, authMethods []string) *AdminHandler {
+	return &AdminHandler{Store: store, Routes: routes, Pools: pools, Breakers: breakers, AuthMethods: authMethods}
 }
 
 // DELETE /admin/cache/apikey/{hash}
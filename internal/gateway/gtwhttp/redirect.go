@@ -0,0 +1,28 @@
+package gtwhttp
+
+import (
+	"net"
+	"net/http"
+)
+
+// NewHTTPSRedirect responde 308 para a mesma URL no listener HTTPS; o 308
+// preserva o método e o corpo, ao contrário do 301. O Host vem do cliente, então
+// só redireciona para nomes que allowed aceita (os dos certificados carregados)
+func NewHTTPSRedirect(httpsPort string, allowed func(host string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if host == "" || !allowed(host) {
+			http.Error(w, "unknown host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,35 @@
+package gtwhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	allowed := func(host string) bool { return host == "api.example.com" }
+
+	cases := []struct {
+		port, host string
+		status     int
+		location   string
+	}{
+		{"443", "api.example.com", http.StatusPermanentRedirect, "https://api.example.com/v1/orders?page=2"},
+		{"8443", "api.example.com:8000", http.StatusPermanentRedirect, "https://api.example.com:8443/v1/orders?page=2"},
+		{"443", "evil.example.org", http.StatusBadRequest, ""},
+		{"443", "", http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "http://placeholder/v1/orders?page=2", nil)
+		req.Host = c.host
+		rec := httptest.NewRecorder()
+		NewHTTPSRedirect(c.port, allowed).ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Fatalf("host %q: expected %d, got %d", c.host, c.status, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != c.location {
+			t.Fatalf("host %q: expected location %q, got %q", c.host, c.location, got)
+		}
+	}
+}
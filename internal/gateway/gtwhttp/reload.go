@@ -0,0 +1,27 @@
+package gtwhttp
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Reloadable serve sempre o handler mais recente; requisições em andamento
+// terminam no handler com que começaram e as conexões abertas não caem
+type Reloadable struct {
+	current atomic.Pointer[http.Handler]
+}
+
+func NewReloadable(h http.Handler) *Reloadable {
+	r := &Reloadable{}
+	r.Swap(h)
+	return r
+}
+
+// Swap troca o handler de forma atômica para as próximas requisições
+func (r *Reloadable) Swap(h http.Handler) {
+	r.current.Store(&h)
+}
+
+func (r *Reloadable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	(*r.current.Load()).ServeHTTP(w, req)
+}
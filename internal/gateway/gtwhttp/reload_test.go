@@ -0,0 +1,42 @@
+package gtwhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReloadableSwap(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	old := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		_, _ = io.WriteString(w, "old")
+	})
+	router := NewReloadable(old)
+
+	inFlight := make(chan string)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		inFlight <- rec.Body.String()
+	}()
+	<-started
+
+	router.Swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "new")
+	}))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "new" {
+		t.Fatalf("expected requests after the swap to use the new handler, got %q", rec.Body.String())
+	}
+
+	// A requisição em andamento termina no handler com que começou
+	close(finish)
+	if got := <-inFlight; got != "old" {
+		t.Fatalf("expected in-flight request to finish on the old handler, got %q", got)
+	}
+}
@@ -2,31 +2,132 @@ package gtwhttp
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/martinsdevv/aegis/internal/config"
 	"github.com/martinsdevv/aegis/internal/gateway/middleware"
 	"github.com/martinsdevv/aegis/internal/gateway/proxy"
 	"github.com/martinsdevv/aegis/internal/health"
+	"github.com/martinsdevv/aegis/internal/usage"
 	"github.com/redis/go-redis/v9"
 )
 
-func NewRouter(healthCheck *health.Checker, cfg config.Config, store *middleware.RLStore, redisClient *redis.Client, apiKeyStore *middleware.APIKeyStore) http.Handler {
+// NewRouter monta a cadeia do tráfego dos consumidores; é chamado de novo a cada
+// reload da configuração. Estado que precisa sobreviver ao reload (transports,
+// quota, limiters) chega pronto e só tem os parâmetros atualizados por setters
+func NewRouter(healthCheck *health.Checker, cfg config.Config, limiter middleware.RateLimiter, conns *middleware.ConnLimiter, quotaMgr *middleware.QuotaManager, redisClient *redis.Client, usagePublisher *middleware.UsagePublisher, apiKeyStore *middleware.APIKeyStore, jwks *middleware.JWKS, routeStore *proxy.RouteStore, poolStore *proxy.PoolStore, breakers *proxy.Breakers, transports *proxy.Transports, usageStore *usage.Store) http.Handler {
 	mux := http.NewServeMux()
 
-	prx := proxy.NewDynamicProxy()
-	adminHandler := NewAdminHandler(apiKeyStore)
+	prx := proxy.NewProxy(poolStore, breakers, transports)
+	// text/event-stream e corpos sem Content-Length já são enviados a cada escrita
+	prx.FlushInterval = cfg.AEGIS_PROXY_FLUSH_INTERVAL
 
 	mux.HandleFunc("/healthz", health.HealthHandler(healthCheck))
 	mux.HandleFunc("/proxy/", proxy.HandleProxy(prx))
 	mux.HandleFunc("/proxy", proxy.HandleProxy(prx))
+	mux.HandleFunc("/", proxy.HandleRoutes(routeStore, prx))
 	mux.HandleFunc("/panic", HandleNilPointer)
 	mux.HandleFunc("/rltest", HandleRLTest)
-	mux.HandleFunc("/admin/cache/apikey", adminHandler.InvalidateAPIKey)
 
-	quotaMgr := middleware.NewQuotaManager(redisClient)
+	auths := middleware.ConfigAuthenticators(cfg, apiKeyStore, jwks)
 
 	var handler http.Handler = mux
-	handler = middleware.NewMiddleware(handler, cfg, store, quotaMgr, redisClient, apiKeyStore)
+	handler = middleware.NewMiddleware(handler, cfg, limiter, conns, quotaMgr, usagePublisher, middleware.Authenticate(routeAuthPolicy(routeStore), auths...))
+
+	// /v1/usage é consulta do próprio consumo: autenticada e com rate limit,
+	// mas fora da quota e dos eventos de uso
+	usageHandler := NewUsageHandler(usageStore, redisClient, cfg.AEGIS_DEFAULT_MONTHLY_QUOTA)
+	reporting := middleware.Chain(http.HandlerFunc(usageHandler.GetUsage),
+		middleware.RequestID(),
+		middleware.ContentID(),
+		middleware.Tracing,
+		middleware.Metrics,
+		middleware.Recover,
+		middleware.Authenticate(nil, auths...),
+		middleware.RateLimit(limiter, middleware.ConfigLimit(cfg)),
+		middleware.Logger,
+	)
+
+	root := http.NewServeMux()
+	root.Handle("/v1/usage", reporting)
+	root.Handle("/", handler)
+
+	return root
+}
+
+// routeAuthPolicy restringe os métodos de autenticação aos da rota dinâmica;
+// rotas sem auth_methods e o /proxy aceitam toda a cadeia
+func routeAuthPolicy(routeStore *proxy.RouteStore) middleware.AuthPolicy {
+	return func(r *http.Request) []string {
+		if r.URL.Path == "/proxy" || strings.HasPrefix(r.URL.Path, "/proxy/") {
+			return nil
+		}
+		rt, ok := routeStore.Table().Match(r)
+		if !ok || len(rt.AuthMethods) == 0 {
+			return nil
+		}
+		return rt.AuthMethods
+	}
+}
+
+// NewAdminRouter monta as rotas /admin, servidas num listener separado do
+// tráfego dos consumidores; authMethods são os métodos habilitados no gateway
+func NewAdminRouter(apiKeyStore *middleware.APIKeyStore, routeStore *proxy.RouteStore, poolStore *proxy.PoolStore, breakers *proxy.Breakers, adminTokenStore *middleware.AdminTokenStore, authMethods []string) http.Handler {
+	return middleware.Chain(adminRoutes(NewAdminHandler(apiKeyStore, routeStore, poolStore, breakers, authMethods)),
+		middleware.RequestID(),
+		middleware.Recover,
+		middleware.WithAdminToken(adminTokenStore),
+	)
+}
+
+// adminRoutes registra os handlers de /admin, sem a autenticação do operador
+func adminRoutes(adminHandler *AdminHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/admin/cache/apikey", adminHandler.InvalidateAPIKey)
+	mux.HandleFunc("POST /admin/keys", adminHandler.CreateAPIKey)
+	mux.HandleFunc("GET /admin/keys", adminHandler.ListAPIKeys)
+	mux.HandleFunc("GET /admin/keys/{id}", adminHandler.GetAPIKey)
+	mux.HandleFunc("PATCH /admin/keys/{id}", adminHandler.UpdateAPIKey)
+	mux.HandleFunc("DELETE /admin/keys/{id}", adminHandler.DeleteAPIKey)
+	mux.HandleFunc("POST /admin/keys/{id}/certs", adminHandler.LinkClientCert)
+	mux.HandleFunc("GET /admin/keys/{id}/certs", adminHandler.ListClientCerts)
+	mux.HandleFunc("DELETE /admin/keys/{id}/certs/{cert_id}", adminHandler.UnlinkClientCert)
+	mux.HandleFunc("POST /admin/keys/{id}/jwt_subjects", adminHandler.LinkJWTSubject)
+	mux.HandleFunc("GET /admin/keys/{id}/jwt_subjects", adminHandler.ListJWTSubjects)
+	mux.HandleFunc("DELETE /admin/keys/{id}/jwt_subjects/{subject_id}", adminHandler.UnlinkJWTSubject)
+	mux.HandleFunc("POST /admin/routes", adminHandler.CreateRoute)
+	mux.HandleFunc("GET /admin/routes", adminHandler.ListRoutes)
+	mux.HandleFunc("GET /admin/routes/{id}", adminHandler.GetRoute)
+	mux.HandleFunc("PATCH /admin/routes/{id}", adminHandler.UpdateRoute)
+	mux.HandleFunc("DELETE /admin/routes/{id}", adminHandler.DeleteRoute)
+	mux.HandleFunc("PUT /admin/routes/{id}/transport", adminHandler.SetRouteTransport)
+	mux.HandleFunc("PUT /admin/routes/{id}/auth", adminHandler.SetRouteAuth)
+	mux.HandleFunc("PUT /admin/routes/{id}/keys/{key_id}", adminHandler.GrantRoute)
+	mux.HandleFunc("DELETE /admin/routes/{id}/keys/{key_id}", adminHandler.RevokeRoute)
+	mux.HandleFunc("POST /admin/pools", adminHandler.CreatePool)
+	mux.HandleFunc("GET /admin/pools", adminHandler.ListPools)
+	mux.HandleFunc("GET /admin/pools/health", adminHandler.PoolsHealth)
+	mux.HandleFunc("DELETE /admin/pools/{id}", adminHandler.DeletePool)
+	mux.HandleFunc("PUT /admin/pools/{id}/health_check", adminHandler.SetPoolHealthCheck)
+	mux.HandleFunc("PUT /admin/pools/{id}/transport", adminHandler.SetPoolTransport)
+	mux.HandleFunc("POST /admin/pools/{id}/targets", adminHandler.AddPoolTarget)
+	mux.HandleFunc("DELETE /admin/pools/{id}/targets/{target_id}", adminHandler.RemovePoolTarget)
+	mux.HandleFunc("GET /admin/breakers", adminHandler.ListBreakers)
+
+	return mux
+}
 
-	return handler
+// UpstreamTransport converte os padrões de conexão com upstreams da configuração
+func UpstreamTransport(cfg config.Config) proxy.TransportConfig {
+	return proxy.TransportConfig{
+		ConnectTimeout:        cfg.AEGIS_UPSTREAM_CONNECT_TIMEOUT,
+		TLSHandshakeTimeout:   cfg.AEGIS_UPSTREAM_TLS_HANDSHAKE_TIMEOUT,
+		ResponseHeaderTimeout: cfg.AEGIS_UPSTREAM_TIMEOUT,
+		RequestTimeout:        cfg.AEGIS_UPSTREAM_REQUEST_TIMEOUT,
+		MaxIdleConnsPerHost:   cfg.AEGIS_UPSTREAM_MAX_IDLE_CONNS_PER_HOST,
+		MaxConnsPerHost:       cfg.AEGIS_UPSTREAM_MAX_CONNS_PER_HOST,
+		IdleConnTimeout:       cfg.AEGIS_UPSTREAM_IDLE_CONN_TIMEOUT,
+		KeepAlive:             cfg.AEGIS_UPSTREAM_KEEP_ALIVE,
+	}
 }
@@ -0,0 +1,127 @@
+package gtwhttp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/usage"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxUsageRangeDays limita o intervalo consultado em /v1/usage
+const maxUsageRangeDays = 92
+
+type quotaUsageResponse struct {
+	Month     string `json:"month"`
+	Used      int64  `json:"used"`
+	Limit     int64  `json:"limit"`
+	Remaining int64  `json:"remaining"`
+	ResetsAt  string `json:"resets_at"`
+}
+
+type usageResponse struct {
+	APIKeyID int64              `json:"api_key_id"`
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Quota    quotaUsageResponse `json:"quota"`
+	usage.Report
+}
+
+// UsageHandler responde o consumo da própria API Key que faz a chamada
+type UsageHandler struct {
+	Store        *usage.Store
+	Redis        *redis.Client
+	DefaultQuota int64
+}
+
+func NewUsageHandler(store *usage.Store, redisClient *redis.Client, defaultQuota int64) *UsageHandler {
+	return &UsageHandler{Store: store, Redis: redisClient, DefaultQuota: defaultQuota}
+}
+
+// GET /v1/usage?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apiKey, ok := middleware.APIKeyFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now().UTC()
+	from, to, err := usageRange(r, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.Store.Report(r.Context(), apiKey.ID, from, to)
+	if err != nil {
+		slog.Error("usage report failed", "api_key_id", apiKey.ID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usageResponse{
+		APIKeyID: apiKey.ID,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Quota:    h.quota(r, apiKey, now),
+		Report:   report,
+	})
+}
+
+func (h *UsageHandler) quota(r *http.Request, apiKey *middleware.APIKey, now time.Time) quotaUsageResponse {
+	month := now.Format("2006-01")
+	q := quotaUsageResponse{
+		Month:    month,
+		Limit:    middleware.QuotaLimit(apiKey, h.DefaultQuota),
+		ResetsAt: time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+
+	if h.Redis != nil {
+		used, err := h.Redis.Get(r.Context(), middleware.QuotaKey(apiKey.ID, month)).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			slog.Warn("quota read failed", "api_key_id", apiKey.ID, "err", err)
+		}
+		q.Used = used
+	}
+
+	q.Remaining = max(q.Limit-q.Used, 0)
+	return q
+}
+
+// usageRange lê from/to (datas UTC, inclusivas); o padrão são os últimos 7 dias
+func usageRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -6), today
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return from, to, errors.New("invalid from, expected YYYY-MM-DD")
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return from, to, errors.New("invalid to, expected YYYY-MM-DD")
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		return from, to, errors.New("to must not be before from")
+	}
+	if to.Sub(from) > maxUsageRangeDays*24*time.Hour {
+		return from, to, errors.New("date range too large")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,110 @@
+package gtwhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/testdb"
+	"github.com/martinsdevv/aegis/internal/usage"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUsageRange(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		query, from, to, err string
+	}{
+		{"", "2026-03-04", "2026-03-10", ""},
+		{"from=2026-03-01&to=2026-03-07", "2026-03-01", "2026-03-07", ""},
+		{"from=2026-03-05&to=2026-03-05", "2026-03-05", "2026-03-05", ""},
+		{"from=2026-01-01&to=2026-04-03", "2026-01-01", "2026-04-03", ""}, // 92 dias
+		{"from=2026-01-01&to=2026-04-04", "", "", "date range too large"},
+		{"from=2026-03-07&to=2026-03-01", "", "", "to must not be before from"},
+		{"from=03/01/2026", "", "", "invalid from, expected YYYY-MM-DD"},
+		{"to=2026-13-01", "", "", "invalid to, expected YYYY-MM-DD"},
+	}
+	for _, c := range cases {
+		from, to, err := usageRange(httptest.NewRequest(http.MethodGet, "/v1/usage?"+c.query, nil), now)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%q: expected error %q, got %v", c.query, c.err, err)
+			}
+			continue
+		}
+		if err != nil || from.Format(time.DateOnly) != c.from || to.Format(time.DateOnly) != c.to {
+			t.Errorf("%q: expected %s..%s, got %s..%s (%v)", c.query, c.from, c.to, from.Format(time.DateOnly), to.Format(time.DateOnly), err)
+		}
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	store := usage.NewStore(testdb.Open(t))
+	mr := miniredis.RunT(t)
+	h := NewUsageHandler(store, redis.NewClient(&redis.Options{Addr: mr.Addr()}), 1000)
+
+	if _, err := store.Apply(context.Background(), []middleware.UsageEvent{
+		{EventID: "a", APIKeyID: "3", Path: "/v1/items", StatusCode: 200, BytesOut: 100, LatencyMS: 12, Timestamp: "2026-03-02T10:00:00Z"},
+		{EventID: "b", APIKeyID: "3", Path: "/v1/items", StatusCode: 503, BytesOut: 10, LatencyMS: 40, Timestamp: "2026-03-03T10:00:00Z"},
+		{EventID: "c", APIKeyID: "4", Path: "/v1/other", StatusCode: 200, BytesOut: 999, LatencyMS: 5, Timestamp: "2026-03-02T11:00:00Z"},
+		{EventID: "d", APIKeyID: "3", Path: "/v1/items", StatusCode: 200, BytesOut: 1, LatencyMS: 1, Timestamp: "2026-02-20T10:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	month := time.Now().UTC().Format("2006-01")
+	mr.Set(middleware.QuotaKey(3, month), "40")
+
+	call := func(key *middleware.APIKey, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/usage?"+query, nil)
+		if key != nil {
+			req = req.WithContext(middleware.SetAPIKey(req.Context(), key))
+		}
+		rec := httptest.NewRecorder()
+		h.GetUsage(rec, req)
+		return rec
+	}
+
+	key := &middleware.APIKey{ID: 3, MonthlyQuota: 100}
+	rec := call(key, "from=2026-03-01&to=2026-03-07")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %q", rec.Code, rec.Body.String())
+	}
+	var resp usageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// Só o consumo da própria chave e dentro do intervalo
+	if resp.APIKeyID != 3 || resp.From != "2026-03-01" || resp.To != "2026-03-07" {
+		t.Fatalf("unexpected range %+v", resp)
+	}
+	if resp.Totals.Requests != 2 || resp.Totals.BytesOut != 110 {
+		t.Fatalf("expected the two events of key 3 in march, got %+v", resp.Totals)
+	}
+	for _, p := range resp.Paths {
+		if p.Path != "/v1/items" {
+			t.Fatalf("expected only paths of key 3, got %s", p.Path)
+		}
+	}
+	if resp.Quota.Month != month || resp.Quota.Used != 40 || resp.Quota.Limit != 100 || resp.Quota.Remaining != 60 {
+		t.Fatalf("unexpected quota %+v", resp.Quota)
+	}
+
+	for query, want := range map[string]int{
+		"from=2026-03-07&to=2026-03-01": http.StatusBadRequest,
+		"from=2025-01-01&to=2026-03-01": http.StatusBadRequest,
+		"from=yesterday":                http.StatusBadRequest,
+	} {
+		if rec := call(key, query); rec.Code != want {
+			t.Errorf("%q: expected %d, got %d", query, want, rec.Code)
+		}
+	}
+	if rec := call(nil, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an api key, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	AdminRoleAdmin  = "admin"
+	AdminRoleViewer = "viewer"
+)
+
+var ErrAdminTokenNotFound = errors.New("admin token not found")
+
+type ctxKeyAdminToken struct{}
+
+// AdminToken identifica o operador responsável por uma chamada admin
+type AdminToken struct {
+	ID       int64
+	Operator string
+	Role     string
+	Active   bool
+}
+
+func AdminTokenFromContext(ctx context.Context) (*AdminToken, bool) {
+	v, ok := ctx.Value(ctxKeyAdminToken{}).(*AdminToken)
+	return v, ok && v != nil
+}
+
+type AdminTokenStore struct {
+	db *sql.DB
+}
+
+func NewAdminTokenStore(db *sql.DB) *AdminTokenStore {
+	return &AdminTokenStore{db: db}
+}
+
+func (s *AdminTokenStore) FindByHash(ctx context.Context, hash string) (*AdminToken, error) {
+	const query = `
+		SELECT id, operator, role, is_active
+		FROM admin_tokens
+		WHERE token = $1
+		LIMIT 1
+	`
+
+	var t AdminToken
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.ID, &t.Operator, &t.Role, &t.Active)
+	if err == sql.ErrNoRows {
+		return nil, ErrAdminTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// WithAdminToken autentica operadores via "Authorization: Bearer <token>".
+// Viewers só podem fazer leituras; toda ação é registrada com o operador.
+func WithAdminToken(store *AdminTokenStore) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			raw = strings.TrimSpace(raw)
+			if !ok || raw == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "admin token is absent", http.StatusUnauthorized)
+				return
+			}
+
+			token, err := store.FindByHash(r.Context(), HashKey(raw))
+			if err != nil {
+				if errors.Is(err, ErrAdminTokenNotFound) {
+					http.Error(w, "invalid admin token", http.StatusForbidden)
+					return
+				}
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+
+			if !token.Active {
+				http.Error(w, "admin token disabled", http.StatusForbidden)
+				return
+			}
+
+			if token.Role != AdminRoleAdmin && !isReadOnly(r.Method) {
+				http.Error(w, "insufficient role", http.StatusForbidden)
+				return
+			}
+
+			wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			ctx := context.WithValue(r.Context(), ctxKeyAdminToken{}, token)
+
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			slog.Info("admin action",
+				"operator", token.Operator,
+				"role", token.Role,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", wrapped.status,
+			)
+		})
+	}
+}
+
+func isReadOnly(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/testdb"
+)
+
+func TestWithAdminToken(t *testing.T) {
+	db := testdb.Open(t)
+	for _, tok := range []struct {
+		operator, raw, role string
+		active              bool
+	}{
+		{"alice", "admin-token", AdminRoleAdmin, true},
+		{"bob", "viewer-token", AdminRoleViewer, true},
+		{"carol", "inactive-token", AdminRoleAdmin, false},
+	} {
+		if _, err := db.Exec(`INSERT INTO admin_tokens (operator, token, role, is_active) VALUES ($1, $2, $3, $4)`,
+			tok.operator, HashKey(tok.raw), tok.role, tok.active); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var operator string
+	h := WithAdminToken(NewAdminTokenStore(db))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := AdminTokenFromContext(r.Context())
+		operator = token.Operator
+	}))
+
+	cases := []struct {
+		name, method, authz string
+		status              int
+		body, operator      string
+	}{
+		{"missing token", http.MethodGet, "", http.StatusUnauthorized, "admin token is absent\n", ""},
+		{"not bearer", http.MethodGet, "Basic admin-token", http.StatusUnauthorized, "admin token is absent\n", ""},
+		{"unknown token", http.MethodGet, "Bearer nope", http.StatusForbidden, "invalid admin token\n", ""},
+		{"inactive token", http.MethodGet, "Bearer inactive-token", http.StatusForbidden, "admin token disabled\n", ""},
+		{"viewer read", http.MethodGet, "Bearer viewer-token", http.StatusOK, "", "bob"},
+		{"viewer post", http.MethodPost, "Bearer viewer-token", http.StatusForbidden, "insufficient role\n", ""},
+		{"viewer patch", http.MethodPatch, "Bearer viewer-token", http.StatusForbidden, "insufficient role\n", ""},
+		{"viewer delete", http.MethodDelete, "Bearer viewer-token", http.StatusForbidden, "insufficient role\n", ""},
+		{"admin write", http.MethodDelete, "Bearer admin-token", http.StatusOK, "", "alice"},
+	}
+	for _, c := range cases {
+		operator = ""
+		req := httptest.NewRequest(c.method, "/admin/keys/1", nil)
+		if c.authz != "" {
+			req.Header.Set("Authorization", c.authz)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != c.status || rec.Body.String() != c.body {
+			t.Errorf("%s: expected %d %q, got %d %q", c.name, c.status, c.body, rec.Code, rec.Body.String())
+		}
+		if operator != c.operator {
+			t.Errorf("%s: expected operator %q in context, got %q", c.name, c.operator, operator)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"database/sql"
 	"encoding/hex"
 	"net/http"
@@ -14,13 +16,19 @@ type ctxKeyAPIKey struct{}
 
 // APIKey representa uma API Key persistida no banco
 type APIKey struct {
-	ID           int64
-	KeyHash      string
-	Name         string
-	UpstreamHost string
-	Active       bool
-	MonthlyQuota int
-	CreatedAt    time.Time
+	ID             int64
+	KeyHash        string
+	Name           string
+	UpstreamHost   string
+	Active         bool
+	MonthlyQuota   int
+	RateLimitRPS   float64
+	RateLimitBurst int
+	CreatedAt      time.Time
+}
+
+func SetAPIKey(ctx context.Context, apiKey *APIKey) context.Context {
+	return context.WithValue(ctx, ctxKeyAPIKey{}, apiKey)
 }
 
 func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
@@ -28,39 +36,77 @@ func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
 	return v, ok && v != nil
 }
 
+// WithAPIKey autentica pelo header X-API-Key ou, sem ele, pelo certificado de
+// cliente vinculado a uma chave (mTLS)
 func WithAPIKey(store *APIKeyStore) Middleware {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-			rawKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
-			if rawKey == "" {
-				http.Error(w, "X-API-Key header is absent", http.StatusUnauthorized)
-				return
-			}
-
-			hashed := hashKey(rawKey)
-
-			apiKey, err := store.FindByHash(r.Context(), hashed)
-			if err != nil {
-				if err == ErrAPIKeyNotFound {
-					http.Error(w, "invalid api key", http.StatusForbidden)
-					return
-				}
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
-
-			if !apiKey.Active {
-				http.Error(w, "api key disabled", http.StatusForbidden)
-				return
-			}
-
-			r.Header.Del("X-API-Key")
-
-			ctx := context.WithValue(r.Context(), ctxKeyAPIKey{}, apiKey)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+	return Authenticate(nil, APIKeyAuth(store), ClientCertAuth(store))
+}
+
+type apiKeyAuth struct {
+	store *APIKeyStore
+}
+
+// APIKeyAuth autentica pelo header X-API-Key
+func APIKeyAuth(store *APIKeyStore) Authenticator {
+	return apiKeyAuth{store: store}
+}
+
+func (apiKeyAuth) Name() string       { return "api_key" }
+func (apiKeyAuth) Credential() string { return "X-API-Key header" }
+
+func (a apiKeyAuth) Authenticate(ctx context.Context, r *http.Request) (*Identity, error) {
+	rawKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if rawKey == "" {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, err := a.store.FindByHash(ctx, HashKey(rawKey))
+	if err == ErrAPIKeyNotFound {
+		return nil, &AuthError{Status: http.StatusForbidden, Message: "invalid api key", Result: "invalid"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Del("X-API-Key")
+	return &Identity{APIKey: apiKey}, nil
+}
+
+type clientCertAuth struct {
+	store *APIKeyStore
+}
+
+// ClientCertAuth autentica pelo certificado de cliente vinculado a uma chave
+func ClientCertAuth(store *APIKeyStore) Authenticator {
+	return clientCertAuth{store: store}
+}
+
+func (clientCertAuth) Name() string       { return "client_cert" }
+func (clientCertAuth) Credential() string { return "client certificate" }
+
+func (a clientCertAuth) Authenticate(ctx context.Context, r *http.Request) (*Identity, error) {
+	cert := clientCert(r)
+	if cert == nil {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, err := a.store.FindByClientCert(ctx, cert)
+	if err == ErrAPIKeyNotFound {
+		return nil, &AuthError{Status: http.StatusForbidden, Message: "client certificate not linked to an api key", Result: "invalid"}
 	}
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{APIKey: apiKey}, nil
+}
+
+// clientCert devolve o certificado apresentado no handshake; o listener HTTPS
+// só aceita certificados que passaram pelas CAs e CRLs configuradas
+func clientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
 }
 
 func findAPIKey(ctx context.Context, db *sql.DB, hash string) (*APIKey, error) {
@@ -93,7 +139,17 @@ func findAPIKey(ctx context.Context, db *sql.DB, hash string) (*APIKey, error) {
 	return &k, nil
 }
 
-func hashKey(raw string) string {
+// GenerateKey cria uma API Key aleatória no formato aegis_<hex>
+func GenerateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "aegis_" + hex.EncodeToString(b), nil
+}
+
+// HashKey devolve o SHA256 em hex usado para persistir chaves e tokens
+func HashKey(raw string) string {
 	sum := sha256.Sum256([]byte(raw))
 	return hex.EncodeToString(sum[:])
 }
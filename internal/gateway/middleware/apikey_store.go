@@ -5,9 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"time"
 
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/martinsdevv/aegis/internal/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 var ErrAPIKeyNotFound = errors.New("api key not found")
@@ -30,16 +35,19 @@ func (s *APIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, err
 
 	// Redis
 	if s.redis != nil {
-		if val, err := s.redis.Get(ctx, s.redisKey(hash)).Result(); err == nil {
-			var k APIKey
-			if err := json.Unmarshal([]byte(val), &k); err == nil {
-				return &k, nil
-			}
+		if k, ok := s.findInCache(ctx, hash); ok {
+			return k, nil
 		}
 	}
 
 	// Postgres
-	k, err := s.findInDB(ctx, hash)
+	dbCtx, span := tracing.Start(ctx, "apikey.db")
+	k, err := s.findInDB(dbCtx, hash)
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "api key query failed")
+	}
+	span.End()
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +61,23 @@ func (s *APIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, err
 	return k, nil
 }
 
+func (s *APIKeyStore) findInCache(ctx context.Context, hash string) (*APIKey, bool) {
+	ctx, span := tracing.Start(ctx, "apikey.cache")
+	defer span.End()
+
+	if val, err := s.redis.Get(ctx, s.redisKey(hash)).Result(); err == nil {
+		var k APIKey
+		if err := json.Unmarshal([]byte(val), &k); err == nil {
+			metrics.APIKeyCache.WithLabelValues("hit").Inc()
+			span.SetAttributes(attribute.String("aegis.cache.result", "hit"))
+			return &k, true
+		}
+	}
+	metrics.APIKeyCache.WithLabelValues("miss").Inc()
+	span.SetAttributes(attribute.String("aegis.cache.result", "miss"))
+	return nil, false
+}
+
 func (s *APIKeyStore) DeleteFromCache(ctx context.Context, hash string) error {
 	if s.redis == nil {
 		return nil
@@ -60,16 +85,165 @@ func (s *APIKeyStore) DeleteFromCache(ctx context.Context, hash string) error {
 	return s.redis.Del(ctx, s.redisKey(hash)).Err()
 }
 
+// Limites gravados quando a criação ou a alteração manda zero
+const (
+	defaultMonthlyQuota   = 10000
+	defaultRateLimitRPS   = 5
+	defaultRateLimitBurst = 10
+)
+
+// CreateAPIKeyParams são os dados de uma nova API Key; zeros usam os defaults
+type CreateAPIKeyParams struct {
+	Name           string
+	UpstreamHost   string
+	MonthlyQuota   int
+	RateLimitRPS   float64
+	RateLimitBurst int
+}
+
+// UpdateAPIKeyParams altera apenas os campos não nulos; limites zerados voltam
+// aos defaults, como na criação
+type UpdateAPIKeyParams struct {
+	Name           *string
+	UpstreamHost   *string
+	MonthlyQuota   *int
+	RateLimitRPS   *float64
+	RateLimitBurst *int
+	Active         *bool
+}
+
+const apiKeyColumns = `id, key, name, COALESCE(upstream_host, ''), is_active, monthly_quota,
+		       rate_limit_rps, rate_limit_burst, created_at`
+
+// Create gera uma nova chave, persiste apenas o hash e devolve a chave em claro uma única vez
+func (s *APIKeyStore) Create(ctx context.Context, p CreateAPIKeyParams) (*APIKey, string, error) {
+	rawKey, err := GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if p.MonthlyQuota <= 0 {
+		p.MonthlyQuota = defaultMonthlyQuota
+	}
+	if p.RateLimitRPS <= 0 {
+		p.RateLimitRPS = defaultRateLimitRPS
+	}
+	if p.RateLimitBurst <= 0 {
+		p.RateLimitBurst = defaultRateLimitBurst
+	}
+
+	query := `
+		INSERT INTO api_keys (name, key, upstream_host, monthly_quota, rate_limit_rps, rate_limit_burst, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, TRUE)
+		RETURNING ` + apiKeyColumns
+
+	row := s.db.QueryRowContext(ctx, query,
+		p.Name, HashKey(rawKey), p.UpstreamHost, p.MonthlyQuota, p.RateLimitRPS, p.RateLimitBurst,
+	)
+
+	k, err := scanAPIKey(row)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return k, rawKey, nil
+}
+
+func (s *APIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *APIKeyStore) FindByID(ctx context.Context, id int64) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	return scanAPIKey(s.db.QueryRowContext(ctx, query, id))
+}
+
+// Update aplica as alterações e invalida o cache para que passem a valer na próxima requisição
+func (s *APIKeyStore) Update(ctx context.Context, id int64, p UpdateAPIKeyParams) (*APIKey, error) {
+	quota, rps, burst := defaultMonthlyQuota, float64(defaultRateLimitRPS), defaultRateLimitBurst
+	if p.MonthlyQuota != nil && *p.MonthlyQuota <= 0 {
+		p.MonthlyQuota = &quota
+	}
+	if p.RateLimitRPS != nil && *p.RateLimitRPS <= 0 {
+		p.RateLimitRPS = &rps
+	}
+	if p.RateLimitBurst != nil && *p.RateLimitBurst <= 0 {
+		p.RateLimitBurst = &burst
+	}
+
+	query := `
+		UPDATE api_keys SET
+			name = COALESCE($2, name),
+			upstream_host = COALESCE($3, upstream_host),
+			monthly_quota = COALESCE($4, monthly_quota),
+			rate_limit_rps = COALESCE($5, rate_limit_rps),
+			rate_limit_burst = COALESCE($6, rate_limit_burst),
+			is_active = COALESCE($7, is_active)
+		WHERE id = $1
+		RETURNING ` + apiKeyColumns
+
+	row := s.db.QueryRowContext(ctx, query,
+		id, p.Name, p.UpstreamHost, p.MonthlyQuota, p.RateLimitRPS, p.RateLimitBurst, p.Active,
+	)
+
+	k, err := scanAPIKey(row)
+	if err != nil {
+		return nil, err
+	}
+
+	// A alteração já foi gravada: sem o Redis a versão em cache vale até o TTL
+	if err := s.DeleteFromCache(ctx, k.KeyHash); err != nil {
+		slog.Warn("api key cache invalidation failed", "api_key_id", k.ID, "ttl", s.ttl, "err", err)
+	}
+
+	return k, nil
+}
+
+func (s *APIKeyStore) Delete(ctx context.Context, id int64) error {
+	const query = `DELETE FROM api_keys WHERE id = $1 RETURNING key`
+
+	var hash string
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.DeleteFromCache(ctx, hash); err != nil {
+		slog.Warn("api key cache invalidation failed", "api_key_id", id, "ttl", s.ttl, "err", err)
+	}
+	return nil
+}
+
 func (s *APIKeyStore) findInDB(ctx context.Context, hash string) (*APIKey, error) {
-	const query = `
-		SELECT id, key, name, upstream_host, is_active, monthly_quota, created_at
-		FROM api_keys
-		WHERE key = $1
-		LIMIT 1
-	`
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key = $1 LIMIT 1`
+	return scanAPIKey(s.db.QueryRowContext(ctx, query, hash))
+}
 
-	row := s.db.QueryRowContext(ctx, query, hash)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanAPIKey(row rowScanner) (*APIKey, error) {
 	var k APIKey
 	err := row.Scan(
 		&k.ID,
@@ -78,6 +252,8 @@ func (s *APIKeyStore) findInDB(ctx context.Context, hash string) (*APIKey, error
 		&k.UpstreamHost,
 		&k.Active,
 		&k.MonthlyQuota,
+		&k.RateLimitRPS,
+		&k.RateLimitBurst,
 		&k.CreatedAt,
 	)
 
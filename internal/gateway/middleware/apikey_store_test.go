@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsdevv/aegis/internal/testdb"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAPIKeyStoreLifecycle(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewAPIKeyStore(testdb.Open(t), redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute)
+
+	k, raw, err := store.Create(ctx, CreateAPIKeyParams{Name: "orders", UpstreamHost: "http://orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.KeyHash != HashKey(raw) || !k.Active {
+		t.Fatalf("expected an active key stored by hash, got %+v", k)
+	}
+	if k.MonthlyQuota != 10000 || k.RateLimitRPS != 5 || k.RateLimitBurst != 10 {
+		t.Fatalf("expected the default limits, got %+v", k)
+	}
+
+	// FindByHash guarda a chave no cache
+	if _, err := store.FindByHash(ctx, k.KeyHash); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists(store.redisKey(k.KeyHash)) {
+		t.Fatal("expected the key to be cached")
+	}
+
+	quota, active := 500, false
+	updated, err := store.Update(ctx, k.ID, UpdateAPIKeyParams{MonthlyQuota: &quota, Active: &active})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.MonthlyQuota != 500 || updated.Active || updated.Name != "orders" {
+		t.Fatalf("expected only quota and active to change, got %+v", updated)
+	}
+	if mr.Exists(store.redisKey(k.KeyHash)) {
+		t.Fatal("expected the update to invalidate the cache")
+	}
+
+	if _, err := store.Update(ctx, k.ID+1000, UpdateAPIKeyParams{Active: &active}); err != ErrAPIKeyNotFound {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	// Com o Redis fora a alteração já gravada não vira erro
+	mr.Close()
+	active = true
+	if updated, err = store.Update(ctx, k.ID, UpdateAPIKeyParams{Active: &active}); err != nil || !updated.Active {
+		t.Fatalf("expected the update to succeed without redis, got %+v (%v)", updated, err)
+	}
+
+	if err := store.Delete(ctx, k.ID); err != nil {
+		t.Fatalf("expected the delete to succeed without redis, got %v", err)
+	}
+	if err := store.Delete(ctx, k.ID); err != ErrAPIKeyNotFound {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+	if _, err := store.FindByID(ctx, k.ID); err != ErrAPIKeyNotFound {
+		t.Fatalf("expected the key to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/martinsdevv/aegis/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// AuthMethods são os nomes dos autenticadores, usados em AEGIS_AUTH_METHODS e nas rotas
+var AuthMethods = []string{"api_key", "client_cert", "jwt"}
+
+// ErrNoCredentials indica que a requisição não traz a credencial do
+// autenticador; a cadeia tenta o próximo
+var ErrNoCredentials = errors.New("no credentials")
+
+// AuthError é uma credencial presente mas recusada, com o status e a mensagem
+// enviados ao cliente
+type AuthError struct {
+	Status  int
+	Message string
+	Result  string // aegis.auth.result do span
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// Identity é o consumidor autenticado; Claims só existe na autenticação por JWT
+type Identity struct {
+	APIKey *APIKey
+	Claims Claims
+}
+
+// Authenticator identifica o consumidor por um tipo de credencial. Em caso de
+// sucesso remove a credencial da requisição, que não segue para o upstream
+type Authenticator interface {
+	// Name identifica o método na configuração e nas rotas (api_key, client_cert, jwt)
+	Name() string
+	// Credential descreve a credencial na mensagem de 401
+	Credential() string
+	Authenticate(ctx context.Context, r *http.Request) (*Identity, error)
+}
+
+// AuthPolicy devolve os métodos aceitos na requisição (em geral os da rota);
+// nil aceita todos os da cadeia
+type AuthPolicy func(r *http.Request) []string
+
+// Authenticate tenta os autenticadores em ordem e usa o primeiro cuja
+// credencial está na requisição. A chave vai para o contexto, então rate
+// limit, quota e uso funcionam igual para qualquer método
+func Authenticate(policy AuthPolicy, auths ...Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accepted := auths
+			if policy != nil {
+				if names := policy(r); names != nil {
+					accepted = slices.DeleteFunc(slices.Clone(auths), func(a Authenticator) bool {
+						return !slices.Contains(names, a.Name())
+					})
+					// Rota restrita a métodos fora da cadeia: erro de configuração, não do cliente
+					if len(accepted) == 0 {
+						slog.Error("no authentication method enabled for route", "path", r.URL.Path, "auth_methods", names)
+						http.Error(w, "no authentication method enabled for route", http.StatusInternalServerError)
+						return
+					}
+				}
+			}
+
+			ctx, span := tracing.Start(r.Context(), "auth")
+			for _, a := range accepted {
+				id, err := a.Authenticate(ctx, r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				span.SetAttributes(attribute.String("aegis.auth.method", a.Name()))
+
+				var authErr *AuthError
+				if errors.As(err, &authErr) {
+					span.SetAttributes(attribute.String("aegis.auth.result", authErr.Result))
+					span.End()
+					if authErr.Status == http.StatusUnauthorized && a.Name() == "jwt" {
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					}
+					http.Error(w, authErr.Message, authErr.Status)
+					return
+				}
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "credential lookup failed")
+					span.End()
+					http.Error(w, "internal error", http.StatusInternalServerError)
+					return
+				}
+
+				if !id.APIKey.Active {
+					span.SetAttributes(attribute.String("aegis.auth.result", "disabled"))
+					span.End()
+					http.Error(w, "api key disabled", http.StatusForbidden)
+					return
+				}
+				span.SetAttributes(attribute.String("aegis.auth.result", "ok"), attribute.Int64("aegis.api_key_id", id.APIKey.ID))
+				span.End()
+
+				if info := RequestInfoFromContext(r.Context()); info != nil {
+					info.APIKeyName = id.APIKey.Name
+				}
+
+				reqCtx := SetAPIKey(r.Context(), id.APIKey)
+				if id.Claims != nil {
+					reqCtx = SetClaims(reqCtx, id.Claims)
+				}
+				next.ServeHTTP(w, r.WithContext(reqCtx))
+				return
+			}
+
+			span.SetAttributes(attribute.String("aegis.auth.result", "missing"))
+			span.End()
+
+			credentials := make([]string, 0, len(accepted))
+			for _, a := range accepted {
+				credentials = append(credentials, a.Credential())
+				if a.Name() == "jwt" {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+			}
+			http.Error(w, "missing credentials: "+strings.Join(credentials, " or "), http.StatusUnauthorized)
+		})
+	}
+}
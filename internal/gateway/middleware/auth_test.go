@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubAuth reconhece a credencial pelo header de mesmo nome
+type stubAuth struct {
+	name string
+	key  *APIKey
+	err  error
+}
+
+func (a stubAuth) Name() string       { return a.name }
+func (a stubAuth) Credential() string { return a.name + " header" }
+
+func (a stubAuth) Authenticate(ctx context.Context, r *http.Request) (*Identity, error) {
+	if r.Header.Get(a.name) == "" {
+		return nil, ErrNoCredentials
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &Identity{APIKey: a.key, Claims: Claims{"sub": a.name}}, nil
+}
+
+func TestAuthenticateChain(t *testing.T) {
+	first := stubAuth{name: "api_key", key: &APIKey{ID: 1, Name: "first", Active: true}}
+	second := stubAuth{name: "jwt", key: &APIKey{ID: 2, Name: "second", Active: true}}
+	rejected := stubAuth{name: "jwt", err: &AuthError{Status: http.StatusUnauthorized, Message: "invalid token: expired", Result: "invalid"}}
+	disabled := stubAuth{name: "jwt", key: &APIKey{ID: 3, Active: false}}
+
+	var only []string
+	policy := func(r *http.Request) []string { return only }
+
+	cases := []struct {
+		name     string
+		auths    []Authenticator
+		policy   []string
+		headers  []string
+		status   int
+		keyID    int64
+		body     string
+		wwwAuthn string
+	}{
+		{"first credential wins", []Authenticator{first, second}, nil, []string{"api_key", "jwt"}, http.StatusOK, 1, "", ""},
+		{"falls through to present credential", []Authenticator{first, second}, nil, []string{"jwt"}, http.StatusOK, 2, "", ""},
+		{"policy skips authenticator", []Authenticator{first, second}, []string{"jwt"}, []string{"api_key", "jwt"}, http.StatusOK, 2, "", ""},
+		{"policy rejects other credentials", []Authenticator{first, second}, []string{"jwt"}, []string{"api_key"}, http.StatusUnauthorized, 0, "missing credentials: jwt header\n", "Bearer"},
+		{"missing credentials", []Authenticator{first, second}, nil, nil, http.StatusUnauthorized, 0, "missing credentials: api_key header or jwt header\n", "Bearer"},
+		{"missing without jwt", []Authenticator{first}, nil, nil, http.StatusUnauthorized, 0, "missing credentials: api_key header\n", ""},
+		{"invalid token", []Authenticator{rejected}, nil, []string{"jwt"}, http.StatusUnauthorized, 0, "invalid token: expired\n", `Bearer error="invalid_token"`},
+		{"disabled key", []Authenticator{disabled}, nil, []string{"jwt"}, http.StatusForbidden, 0, "api key disabled\n", ""},
+		{"route method not enabled", []Authenticator{first}, []string{"jwt"}, []string{"api_key"}, http.StatusInternalServerError, 0, "no authentication method enabled for route\n", ""},
+	}
+
+	for _, c := range cases {
+		only = c.policy
+		var gotKey *APIKey
+		var gotClaims Claims
+		h := Authenticate(policy, c.auths...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotKey, _ = APIKeyFromContext(r.Context())
+			gotClaims, _ = ClaimsFromContext(r.Context())
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		for _, name := range c.headers {
+			req.Header.Set(name, "x")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Fatalf("%s: expected %d, got %d %q", c.name, c.status, rec.Code, rec.Body.String())
+		}
+		if c.body != "" && rec.Body.String() != c.body {
+			t.Errorf("%s: expected body %q, got %q", c.name, c.body, rec.Body.String())
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != c.wwwAuthn {
+			t.Errorf("%s: expected WWW-Authenticate %q, got %q", c.name, c.wwwAuthn, got)
+		}
+		if c.keyID != 0 {
+			if gotKey == nil || gotKey.ID != c.keyID {
+				t.Fatalf("%s: expected api key %d in context, got %+v", c.name, c.keyID, gotKey)
+			}
+			if gotClaims == nil {
+				t.Errorf("%s: expected claims in context", c.name)
+			}
+		}
+	}
+}
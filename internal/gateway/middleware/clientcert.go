@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrClientCertNotFound = errors.New("client certificate not found")
+	ErrClientCertExists   = errors.New("client certificate already linked")
+)
+
+// ClientCert vincula um certificado de cliente (mTLS) a uma API Key; apenas um
+// de Fingerprint e Subject é preenchido
+type ClientCert struct {
+	ID          int64
+	APIKeyID    int64
+	Fingerprint string
+	Subject     string
+	CreatedAt   time.Time
+}
+
+// CertFingerprint devolve o SHA-256 do certificado em hex minúsculo
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint aceita também o formato do openssl (AB:CD:...); devolve
+// "" quando não é um SHA-256
+func NormalizeFingerprint(raw string) string {
+	fp := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(raw), ":", ""))
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != sha256.Size {
+		return ""
+	}
+	return fp
+}
+
+const clientCertColumns = `id, api_key_id, COALESCE(fingerprint, ''), COALESCE(subject, ''), created_at`
+
+// FindByClientCert resolve a API Key do certificado; o vínculo pelo fingerprint
+// vale mais que o pelo subject. Vai sempre ao banco para que a remoção do
+// vínculo tenha efeito imediato
+func (s *APIKeyStore) FindByClientCert(ctx context.Context, cert *x509.Certificate) (*APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE id = (
+			SELECT api_key_id FROM client_certs
+			WHERE fingerprint = $1 OR subject = $2
+			ORDER BY fingerprint IS NULL
+			LIMIT 1
+		)`
+	return scanAPIKey(s.db.QueryRowContext(ctx, query, CertFingerprint(cert), cert.Subject.String()))
+}
+
+// LinkClientCert vincula um fingerprint (já normalizado) ou um subject à chave
+func (s *APIKeyStore) LinkClientCert(ctx context.Context, apiKeyID int64, fingerprint, subject string) (*ClientCert, error) {
+	query := `
+		INSERT INTO client_certs (api_key_id, fingerprint, subject)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''))
+		ON CONFLICT DO NOTHING
+		RETURNING ` + clientCertColumns
+
+	c, err := scanClientCert(s.db.QueryRowContext(ctx, query, apiKeyID, fingerprint, subject))
+	if errors.Is(err, ErrClientCertNotFound) {
+		return nil, ErrClientCertExists
+	}
+	return c, err
+}
+
+func (s *APIKeyStore) ListClientCerts(ctx context.Context, apiKeyID int64) ([]*ClientCert, error) {
+	query := `SELECT ` + clientCertColumns + ` FROM client_certs WHERE api_key_id = $1 ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*ClientCert{}
+	for rows.Next() {
+		c, err := scanClientCert(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+
+	return list, rows.Err()
+}
+
+func (s *APIKeyStore) UnlinkClientCert(ctx context.Context, apiKeyID, certID int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM client_certs WHERE id = $1 AND api_key_id = $2`, certID, apiKeyID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrClientCertNotFound
+	}
+	return nil
+}
+
+func scanClientCert(row rowScanner) (*ClientCert, error) {
+	var c ClientCert
+	err := row.Scan(&c.ID, &c.APIKeyID, &c.Fingerprint, &c.Subject, &c.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrClientCertNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
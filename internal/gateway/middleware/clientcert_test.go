@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/testdb"
+)
+
+func newClientCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Acme"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestFindByClientCert(t *testing.T) {
+	ctx := context.Background()
+	store := NewAPIKeyStore(testdb.Open(t), nil, time.Minute)
+
+	bySubject, _, err := store.Create(ctx, CreateAPIKeyParams{Name: "by-subject"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byFingerprint, _, err := store.Create(ctx, CreateAPIKeyParams{Name: "by-fingerprint"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := newClientCert(t, "partner")
+	if _, err := store.LinkClientCert(ctx, bySubject.ID, "", cert.Subject.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.FindByClientCert(ctx, cert)
+	if err != nil || got.ID != bySubject.ID {
+		t.Fatalf("expected the key linked by subject, got %+v (%v)", got, err)
+	}
+
+	// O fingerprint identifica este certificado e vale mais que o subject
+	if _, err := store.LinkClientCert(ctx, byFingerprint.ID, CertFingerprint(cert), ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err = store.FindByClientCert(ctx, cert)
+	if err != nil || got.ID != byFingerprint.ID {
+		t.Fatalf("expected the key linked by fingerprint, got %+v (%v)", got, err)
+	}
+
+	// Outro certificado com o mesmo subject continua caindo no vínculo pelo subject
+	reissued := newClientCert(t, "partner")
+	got, err = store.FindByClientCert(ctx, reissued)
+	if err != nil || got.ID != bySubject.ID {
+		t.Fatalf("expected the reissued certificate to match by subject, got %+v (%v)", got, err)
+	}
+
+	if _, err := store.LinkClientCert(ctx, bySubject.ID, CertFingerprint(cert), ""); err != ErrClientCertExists {
+		t.Fatalf("expected ErrClientCertExists, got %v", err)
+	}
+	if _, err := store.FindByClientCert(ctx, newClientCert(t, "stranger")); err != ErrAPIKeyNotFound {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+}
+
+func TestWithAPIKeyClientCert(t *testing.T) {
+	ctx := context.Background()
+	store := NewAPIKeyStore(testdb.Open(t), nil, time.Minute)
+
+	key, _, err := store.Create(ctx, CreateAPIKeyParams{Name: "partner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := newClientCert(t, "partner")
+	if _, err := store.LinkClientCert(ctx, key.ID, CertFingerprint(linked), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotKey *APIKey
+	h := WithAPIKey(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey, _ = APIKeyFromContext(r.Context())
+	}))
+	call := func(cert *x509.Certificate) *httptest.ResponseRecorder {
+		gotKey = nil
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := call(linked); rec.Code != http.StatusOK || gotKey == nil || gotKey.ID != key.ID {
+		t.Fatalf("expected the linked certificate to authenticate as key %d, got %d %q", key.ID, rec.Code, rec.Body.String())
+	}
+
+	rec := call(newClientCert(t, "stranger"))
+	if rec.Code != http.StatusForbidden || rec.Body.String() != "client certificate not linked to an api key\n" {
+		t.Fatalf("expected 403 for an unlinked certificate, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	active := false
+	if _, err := store.Update(ctx, key.ID, UpdateAPIKeyParams{Active: &active}); err != nil {
+		t.Fatal(err)
+	}
+	rec = call(linked)
+	if rec.Code != http.StatusForbidden || rec.Body.String() != "api key disabled\n" || gotKey != nil {
+		t.Fatalf("expected 403 for a disabled key, got %d %q", rec.Code, rec.Body.String())
+	}
+}
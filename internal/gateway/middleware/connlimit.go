@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IsUpgrade indica um pedido de troca de protocolo (WebSocket e outros Upgrade)
+func IsUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConnLimiter conta as conexões de Upgrade abertas por API Key nesta instância;
+// é compartilhado entre as recargas da configuração para não perder a contagem
+type ConnLimiter struct {
+	mu   sync.Mutex
+	open map[int64]int
+}
+
+func NewConnLimiter() *ConnLimiter {
+	return &ConnLimiter{open: map[int64]int{}}
+}
+
+// Limit rejeita com 429 um Upgrade acima de max conexões simultâneas da chave;
+// max zero desativa o limite. Requisições comuns passam direto
+func (cl *ConnLimiter) Limit(max int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cl == nil || max <= 0 || !IsUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				http.Error(w, "missing API key", http.StatusUnauthorized)
+				return
+			}
+
+			if !cl.acquire(apiKey.ID, max) {
+				w.Header().Set("X-Connection-Limit", strconv.Itoa(max))
+				http.Error(w, "too many concurrent connections for api key", http.StatusTooManyRequests)
+				return
+			}
+			// Num Upgrade o handler só retorna quando a conexão fecha
+			defer cl.release(apiKey.ID)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cl *ConnLimiter) acquire(apiKeyID int64, max int) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.open[apiKeyID] >= max {
+		return false
+	}
+	cl.open[apiKeyID]++
+	return true
+}
+
+func (cl *ConnLimiter) release(apiKeyID int64) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.open[apiKeyID]--; cl.open[apiKeyID] <= 0 {
+		delete(cl.open, apiKeyID)
+	}
+}
+
+// Open devolve quantas conexões de Upgrade a chave tem abertas
+func (cl *ConnLimiter) Open(apiKeyID int64) int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.open[apiKeyID]
+}
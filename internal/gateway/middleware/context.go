@@ -12,3 +12,24 @@ func UpstreamHostFromContext(ctx context.Context) (string, bool) {
 	v, ok := ctx.Value(ctxKeyUpstreamHost{}).(string)
 	return v, ok
 }
+
+type ctxKeyRequestInfo struct{}
+
+// RequestInfo é preenchido pelas camadas internas (API Key, proxy) e lido pelos
+// middlewares externos depois que a resposta termina; valores de contexto
+// definidos lá dentro não voltam para quem está por fora
+type RequestInfo struct {
+	APIKeyName string
+	Route      string
+	Upstream   string
+}
+
+func SetRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestInfo{}, info)
+}
+
+// RequestInfoFromContext devolve nil fora de uma requisição instrumentada
+func RequestInfoFromContext(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(ctxKeyRequestInfo{}).(*RequestInfo)
+	return info
+}
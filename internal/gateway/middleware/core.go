@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
+	"net"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,10 +17,15 @@ type (
 	Middleware      func(http.Handler) http.Handler
 )
 
+// responseWriter repassa a resposta sem bufferizar, registrando status e bytes
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
+	bytes       int64
 	wroteHeader bool
+	headerAt    time.Time     // quando os headers foram enviados ou a conexão foi assumida
+	streaming   bool          // resposta text/event-stream
+	upgraded    *countingConn // conexão assumida via Hijack (WebSocket e outros Upgrade)
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -25,6 +34,16 @@ func (rw *responseWriter) WriteHeader(code int) {
 	}
 	rw.status = code
 	rw.wroteHeader = true
+	rw.headerAt = time.Now()
+
+	// Um stream fica aberto além do WriteTimeout do servidor, que vale para respostas comuns;
+	// chamadas gRPC também podem ser streams
+	if IsEventStream(rw.Header()) {
+		rw.streaming = true
+		_ = http.NewResponseController(rw.ResponseWriter).SetWriteDeadline(time.Time{})
+	} else if isGRPCContentType(rw.Header().Get("Content-Type")) {
+		_ = http.NewResponseController(rw.ResponseWriter).SetWriteDeadline(time.Time{})
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
@@ -32,7 +51,55 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	if !rw.wroteHeader {
 		rw.WriteHeader(http.StatusOK)
 	}
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush mantém streaming (SSE, downloads chunked) funcionando através do wrapper
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack entrega a conexão ao proxy num Upgrade, contando os bytes trafegados
+// até o fechamento
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Os timeouts do http.Server valem para uma requisição, não para a conexão inteira
+	_ = conn.SetDeadline(time.Time{})
+
+	rw.status = http.StatusSwitchingProtocols
+	rw.wroteHeader = true
+	rw.headerAt = time.Now()
+	rw.upgraded = &countingConn{Conn: conn}
+	return rw.upgraded, brw, nil
+}
+
+// bytesOut soma o corpo escrito e o que foi enviado pela conexão depois do Upgrade
+func (rw *responseWriter) bytesOut() int64 {
+	if rw.upgraded == nil {
+		return rw.bytes
+	}
+	return rw.bytes + rw.upgraded.written.Load()
+}
+
+func (rw *responseWriter) bytesIn() int64 {
+	if rw.upgraded == nil {
+		return 0
+	}
+	return rw.upgraded.read.Load()
+}
+
+// Unwrap expõe o writer original para o http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 // NewMiddleware aplica todos os middlewares na ordem correta
@@ -86,3 +153,22 @@ func RequestIDFromContext(ctx context.Context) (string, bool) {
 	v, ok := ctx.Value(ctxKeyRequestID{}).(string)
 	return v, ok && v != ""
 }
+
+// countingConn conta os bytes de uma conexão assumida; leitura e escrita
+// acontecem em goroutines diferentes
+type countingConn struct {
+	net.Conn
+	read, written atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Códigos gRPC usados nas respostas geradas pelo próprio gateway
+const (
+	grpcUnknown           = 2
+	grpcDeadlineExceeded  = 4
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// IsGRPC indica uma chamada gRPC (application/grpc, +proto, +json); gRPC-Web fica de fora
+func IsGRPC(r *http.Request) bool {
+	return isGRPCContentType(r.Header.Get("Content-Type"))
+}
+
+func isGRPCContentType(ct string) bool {
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+") || strings.HasPrefix(ct, "application/grpc;")
+}
+
+// GRPCStatus devolve o grpc-status da resposta, dos headers (Trailers-Only) ou
+// dos trailers; "" quando a resposta não terminou com um status gRPC
+func GRPCStatus(h http.Header) string {
+	if v := h.Get("Grpc-Status"); v != "" {
+		return v
+	}
+	return h.Get(http.TrailerPrefix + "Grpc-Status")
+}
+
+// GRPCMethod extrai /pacote.Serviço/Método do path, ignorando prefixos de rota
+func GRPCMethod(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return path
+	}
+	if j := strings.LastIndex(path[:i], "/"); j >= 0 {
+		return path[j:]
+	}
+	return path
+}
+
+// GRPC traduz as rejeições do gateway (auth, rate limit, quota, erros do proxy)
+// para chamadas gRPC: o cliente recebe 200 com grpc-status e grpc-message, como
+// o protocolo exige. Fica por fora do Tracing e do Metrics, que continuam vendo
+// o status HTTP original
+func GRPC(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGRPC(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &grpcWriter{ResponseWriter: w}
+		next.ServeHTTP(gw, r)
+		gw.finish()
+	})
+}
+
+// grpcWriter repassa as respostas 200 do upstream e segura as demais para
+// reescrevê-las no fim
+type grpcWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int // status HTTP de uma rejeição; zero repassa a resposta
+	message     bytes.Buffer
+}
+
+func (g *grpcWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	if code == http.StatusOK {
+		g.ResponseWriter.WriteHeader(code)
+		return
+	}
+	g.status = code
+}
+
+func (g *grpcWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.status == 0 {
+		return g.ResponseWriter.Write(b)
+	}
+	// O corpo da rejeição vira o grpc-message
+	if room := 1024 - g.message.Len(); room > 0 {
+		g.message.Write(b[:min(len(b), room)])
+	}
+	return len(b), nil
+}
+
+func (g *grpcWriter) Flush() {
+	if g.status != 0 {
+		return
+	}
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+func (g *grpcWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// finish envia a rejeição como resposta Trailers-Only
+func (g *grpcWriter) finish() {
+	if g.status == 0 {
+		return
+	}
+
+	h := g.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Status", strconv.Itoa(grpcCode(g.status, h)))
+	h.Set("Grpc-Message", encodeGRPCMessage(rejectionMessage(g.message.Bytes())))
+	g.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+// grpcCode segue o mapeamento HTTP → gRPC da especificação, com os casos do gateway:
+// rate limit e quota viram RESOURCE_EXHAUSTED e o timeout do upstream DEADLINE_EXCEEDED
+func grpcCode(status int, h http.Header) int {
+	switch status {
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		// Quota esgotada responde 403 com Retry-After até a virada do mês
+		if h.Get("Retry-After") != "" {
+			return grpcResourceExhausted
+		}
+		return grpcPermissionDenied
+	case http.StatusTooManyRequests:
+		return grpcResourceExhausted
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusBadRequest, http.StatusInternalServerError:
+		return grpcInternal
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcUnavailable
+	default:
+		return grpcUnknown
+	}
+}
+
+// rejectionMessage usa o campo message dos erros em JSON (circuit_open,
+// upstream_timeout) e o texto puro nos demais
+func rejectionMessage(body []byte) string {
+	var structured struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &structured) == nil && structured.Message != "" {
+		return structured.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// encodeGRPCMessage aplica o percent-encoding do grpc-message
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
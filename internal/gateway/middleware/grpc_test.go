@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGRPCMapsGatewayRejections(t *testing.T) {
+	cases := []struct {
+		name   string
+		reject func(w http.ResponseWriter)
+		code   string
+		msg    string
+	}{
+		{"rate limit", func(w http.ResponseWriter) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		}, "8", "rate limit exceeded"},
+		{"quota", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "quota exceeded", http.StatusForbidden)
+		}, "8", "quota exceeded"},
+		{"route grant", func(w http.ResponseWriter) {
+			http.Error(w, "route not allowed for api key", http.StatusForbidden)
+		}, "7", "route not allowed for api key"},
+		{"upstream timeout", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			_, _ = w.Write([]byte(`{"error":"upstream_timeout","message":"upstream did not respond in time"}`))
+		}, "4", "upstream did not respond in time"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/orders.v1.Orders/Get", nil)
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		rec := httptest.NewRecorder()
+
+		GRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { c.reject(w) })).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Fatalf("%s: expected an empty 200, got %d %q", c.name, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Grpc-Status"); got != c.code {
+			t.Errorf("%s: expected grpc-status %s, got %s", c.name, c.code, got)
+		}
+		if got := rec.Header().Get("Grpc-Message"); got != c.msg {
+			t.Errorf("%s: expected grpc-message %q, got %q", c.name, c.msg, got)
+		}
+	}
+
+	if got := GRPCMethod("/grpc/orders.v1.Orders/Get"); got != "/orders.v1.Orders/Get" {
+		t.Errorf("expected the route prefix to be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,290 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// jwksRefreshOnMiss é o intervalo mínimo entre buscas causadas por um kid
+// desconhecido, para que tokens forjados não virem uma enxurrada de requisições ao IdP
+const jwksRefreshOnMiss = 30 * time.Second
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// JWKS mantém as chaves públicas dos emissores de JWT, lidas de uma URL (o
+// jwks_uri do provedor OIDC) ou de um arquivo. É compartilhado entre as
+// recargas da configuração
+type JWKS struct {
+	url    string
+	file   string
+	client *http.Client
+
+	mu       sync.Mutex // serializa as cargas
+	lastLoad time.Time
+	keys     atomic.Pointer[[]jwk]
+}
+
+type jwk struct {
+	kid string
+	alg string // vazio aceita qualquer algoritmo compatível com a chave
+	key crypto.PublicKey
+}
+
+// NewJWKS recebe a URL ou o arquivo; as chaves só existem depois do Load
+func NewJWKS(url, file string) *JWKS {
+	k := &JWKS{url: url, file: file, client: &http.Client{Timeout: 10 * time.Second}}
+	k.keys.Store(&[]jwk{})
+	return k
+}
+
+// Load busca as chaves; se falhar as atuais continuam valendo
+func (k *JWKS) Load(ctx context.Context) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.load(ctx)
+}
+
+func (k *JWKS) load(ctx context.Context) error {
+	k.lastLoad = time.Now()
+
+	raw, err := k.read(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return err
+	}
+
+	k.keys.Store(&keys)
+	return nil
+}
+
+func (k *JWKS) read(ctx context.Context) ([]byte, error) {
+	if k.file != "" {
+		b, err := os.ReadFile(k.file)
+		if err != nil {
+			return nil, fmt.Errorf("read jwks: %w", err)
+		}
+		return b, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// Run recarrega as chaves a cada interval, acompanhando a rotação do provedor
+func (k *JWKS) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := k.Load(ctx); err != nil {
+				slog.Warn("jwks refresh failed, keeping current keys", "err", err)
+			}
+		}
+	}
+}
+
+// lookup acha a chave do kid; um kid desconhecido força uma nova busca, no
+// máximo uma a cada jwksRefreshOnMiss. Sem kid vale a única chave compatível
+func (k *JWKS) lookup(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	if key, ok := findJWK(*k.keys.Load(), kid, alg); ok {
+		return key, nil
+	}
+
+	k.mu.Lock()
+	if time.Since(k.lastLoad) >= jwksRefreshOnMiss {
+		if err := k.load(ctx); err != nil {
+			slog.Warn("jwks refresh failed, keeping current keys", "err", err)
+		}
+	}
+	k.mu.Unlock()
+
+	if key, ok := findJWK(*k.keys.Load(), kid, alg); ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+func findJWK(keys []jwk, kid, alg string) (crypto.PublicKey, bool) {
+	var found []jwk
+	for _, key := range keys {
+		if (kid == "" || key.kid == kid) && (key.alg == "" || key.alg == alg) && keyFitsAlg(key.key, alg) {
+			found = append(found, key)
+		}
+	}
+	// Sem kid, mais de uma candidata seria um palpite
+	if len(found) == 0 || (kid == "" && len(found) > 1) {
+		return nil, false
+	}
+	return found[0].key, true
+}
+
+func keyFitsAlg(key crypto.PublicKey, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// parseJWKS lê o JSON Web Key Set (RFC 7517); chaves de tipos não suportados
+// ou que não são de assinatura são ignoradas
+func parseJWKS(raw []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaJWK(k.N, k.E)
+		case "EC":
+			key, err = ecJWK(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = ed25519JWK(k.Crv, k.X)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse jwks key %q: %w", k.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("parse jwks: no usable signing keys")
+	}
+	return keys, nil
+}
+
+func rsaJWK(n, e string) (crypto.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ecJWK(crv, x, y string) (crypto.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, nil
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ponto não comprimido: 0x04 || X || Y, cada coordenada com o tamanho da curva
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xb) > size || len(yb) > size {
+		return nil, errors.New("invalid ec coordinates")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(xb):1+size], xb)
+	copy(point[1+2*size-len(yb):], yb)
+	return ecdsa.ParseUncompressedPublicKey(curve, point)
+}
+
+func ed25519JWK(crv, x string) (crypto.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, nil
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	if len(xb) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 key")
+	}
+	return ed25519.PublicKey(xb), nil
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ctxKeyClaims struct{}
+
+// Claims são as claims de um JWT validado, com números como json.Number
+type Claims map[string]any
+
+func SetClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ctxKeyClaims{}, claims)
+}
+
+// ClaimsFromContext devolve as claims do JWT que autenticou a requisição;
+// nil quando a autenticação foi por outro método
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	v, ok := ctx.Value(ctxKeyClaims{}).(Claims)
+	return v, ok && v != nil
+}
+
+// String devolve a claim como texto (strings, números e booleanos); "" para
+// claims ausentes ou compostas
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+// JWTConfig são as verificações das claims registradas; Issuer e Audiences
+// vazios não são verificados
+type JWTConfig struct {
+	Issuer    string
+	Audiences []string
+	Leeway    time.Duration // tolerância de relógio para exp e nbf
+}
+
+// JWTValidator valida tokens assinados por uma das chaves do JWKS. Só aceita
+// algoritmos assimétricos: none e HMAC são recusados
+type JWTValidator struct {
+	keys *JWKS
+	cfg  JWTConfig
+	now  func() time.Time
+}
+
+func NewJWTValidator(keys *JWKS, cfg JWTConfig) *JWTValidator {
+	return &JWTValidator{keys: keys, cfg: cfg, now: time.Now}
+}
+
+// Validate confere assinatura, exp, nbf, iss e aud e devolve as claims
+func (v *JWTValidator) Validate(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	key, err := v.keys.lookup(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyJWTSignature(header.Alg, hash, key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTValidator) checkClaims(claims Claims) error {
+	now := v.now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.After(exp.Add(v.cfg.Leeway)) {
+		return errors.New("token expired")
+	}
+	if _, present := claims["nbf"]; present {
+		nbf, ok := numericDate(claims["nbf"])
+		if !ok {
+			return errors.New("invalid nbf claim")
+		}
+		if now.Add(v.cfg.Leeway).Before(nbf) {
+			return errors.New("token not valid yet")
+		}
+	}
+
+	if v.cfg.Issuer != "" && claims.String("iss") != v.cfg.Issuer {
+		return errors.New("unexpected issuer")
+	}
+
+	if len(v.cfg.Audiences) > 0 {
+		// aud pode ser uma string ou uma lista
+		var aud []string
+		switch a := claims["aud"].(type) {
+		case string:
+			aud = []string{a}
+		case []any:
+			for _, item := range a {
+				if s, ok := item.(string); ok {
+					aud = append(aud, s)
+				}
+			}
+		}
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(v.cfg.Audiences, a) }) {
+			return errors.New("unexpected audience")
+		}
+	}
+	return nil
+}
+
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// esCurves amarra cada algoritmo ES à curva da RFC 7518
+var esCurves = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func verifyJWTSignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed, sig []byte) bool {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, sig)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// Assinatura JWS de ECDSA é R || S com o tamanho fixo da curva
+		bits := pub.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if esCurves[alg] != bits || len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+	return false
+}
+
+type jwtAuth struct {
+	store     *APIKeyStore
+	validator *JWTValidator
+	claim     string
+}
+
+// JWTAuth autentica por Authorization: Bearer; o valor da claim configurada
+// (sub por padrão) aponta para a API Key pela tabela jwt_subjects
+func JWTAuth(store *APIKeyStore, validator *JWTValidator, claim string) Authenticator {
+	return jwtAuth{store: store, validator: validator, claim: claim}
+}
+
+func (jwtAuth) Name() string       { return "jwt" }
+func (jwtAuth) Credential() string { return "bearer token" }
+
+func (a jwtAuth) Authenticate(ctx context.Context, r *http.Request) (*Identity, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.validator.Validate(ctx, token)
+	if err != nil {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "invalid token: " + err.Error(), Result: "invalid"}
+	}
+
+	subject := claims.String(a.claim)
+	if subject == "" {
+		return nil, &AuthError{Status: http.StatusUnauthorized, Message: "invalid token: missing " + a.claim + " claim", Result: "invalid"}
+	}
+
+	apiKey, err := a.store.FindByJWTSubject(ctx, subject)
+	if err == ErrAPIKeyNotFound {
+		return nil, &AuthError{Status: http.StatusForbidden, Message: "token subject not linked to an api key", Result: "invalid"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Del("Authorization")
+	return &Identity{APIKey: apiKey, Claims: claims}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
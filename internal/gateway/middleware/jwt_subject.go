@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrJWTSubjectNotFound = errors.New("jwt subject not found")
+	ErrJWTSubjectExists   = errors.New("jwt subject already linked")
+)
+
+// JWTSubject vincula o valor da claim de consumidor de um JWT a uma API Key
+type JWTSubject struct {
+	ID        int64
+	APIKeyID  int64
+	Subject   string
+	CreatedAt time.Time
+}
+
+const jwtSubjectColumns = `id, api_key_id, subject, created_at`
+
+// FindByJWTSubject resolve a API Key do consumidor do token; como no
+// certificado, vai sempre ao banco para que a remoção tenha efeito imediato
+func (s *APIKeyStore) FindByJWTSubject(ctx context.Context, subject string) (*APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE id = (SELECT api_key_id FROM jwt_subjects WHERE subject = $1)`
+	return scanAPIKey(s.db.QueryRowContext(ctx, query, subject))
+}
+
+func (s *APIKeyStore) LinkJWTSubject(ctx context.Context, apiKeyID int64, subject string) (*JWTSubject, error) {
+	query := `
+		INSERT INTO jwt_subjects (api_key_id, subject)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		RETURNING ` + jwtSubjectColumns
+
+	js, err := scanJWTSubject(s.db.QueryRowContext(ctx, query, apiKeyID, subject))
+	if errors.Is(err, ErrJWTSubjectNotFound) {
+		return nil, ErrJWTSubjectExists
+	}
+	return js, err
+}
+
+func (s *APIKeyStore) ListJWTSubjects(ctx context.Context, apiKeyID int64) ([]*JWTSubject, error) {
+	query := `SELECT ` + jwtSubjectColumns + ` FROM jwt_subjects WHERE api_key_id = $1 ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*JWTSubject{}
+	for rows.Next() {
+		js, err := scanJWTSubject(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, js)
+	}
+
+	return list, rows.Err()
+}
+
+func (s *APIKeyStore) UnlinkJWTSubject(ctx context.Context, apiKeyID, subjectID int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM jwt_subjects WHERE id = $1 AND api_key_id = $2`, subjectID, apiKeyID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJWTSubjectNotFound
+	}
+	return nil
+}
+
+func scanJWTSubject(row rowScanner) (*JWTSubject, error) {
+	var js JWTSubject
+	err := row.Scan(&js.ID, &js.APIKeyID, &js.Subject, &js.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrJWTSubjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &js, nil
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s testSigner) jwk() map[string]any {
+	k := map[string]any{"kid": s.kid, "use": "sig"}
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		k["kty"], k["n"], k["e"] = "RSA", b64(pub.N.Bytes()), b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		k["kty"], k["crv"] = "EC", pub.Curve.Params().Name
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k["x"], k["y"] = b64(pub.X.FillBytes(make([]byte, size))), b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		k["kty"], k["crv"], k["x"] = "OKP", "Ed25519", b64(pub)
+	}
+	return k
+}
+
+func (s testSigner) sign(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	h := map[string]any{"alg": s.alg, "kid": s.kid}
+	for k, v := range header {
+		h[k] = v
+	}
+	hb, _ := json.Marshal(h)
+	cb, _ := json.Marshal(claims)
+	signed := b64(hb) + "." + b64(cb)
+
+	var sig []byte
+	var err error
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(signed))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, ss, e := ecdsa.Sign(rand.Reader, key, digest[:])
+		sig, err = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...), e
+	default:
+		digest := sha256.Sum256([]byte(signed))
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func newTestSigners(t *testing.T) []testSigner {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []testSigner{
+		{kid: "rsa", alg: "RS256", key: rsaKey},
+		{kid: "ec", alg: "ES256", key: ecKey},
+		{kid: "ed", alg: "EdDSA", key: edKey},
+	}
+}
+
+// jwksServer serve o JWKS das chaves e conta as buscas
+func jwksServer(t *testing.T, signers *atomic.Pointer[[]testSigner], fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]any{}
+		for _, s := range *signers.Load() {
+			keys = append(keys, s.jwk())
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func validClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss": "https://idp.example.com",
+		"aud": []string{"aegis"},
+		"sub": "consumer-1",
+		"exp": now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestJWTValidate(t *testing.T) {
+	signers := newTestSigners(t)
+	var current atomic.Pointer[[]testSigner]
+	current.Store(&signers)
+	var fetches atomic.Int32
+	srv := jwksServer(t, &current, &fetches)
+
+	jwks := NewJWKS(srv.URL, "")
+	if err := jwks.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	v := NewJWTValidator(jwks, JWTConfig{Issuer: "https://idp.example.com", Audiences: []string{"aegis"}, Leeway: 5 * time.Second})
+	now := time.Now()
+
+	for _, s := range signers {
+		claims, err := v.Validate(context.Background(), s.sign(t, nil, validClaims(now)))
+		if err != nil {
+			t.Fatalf("%s: %v", s.alg, err)
+		}
+		if claims.String("sub") != "consumer-1" {
+			t.Fatalf("%s: unexpected claims %v", s.alg, claims)
+		}
+	}
+
+	with := func(key string, value any) map[string]any {
+		c := validClaims(now)
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+	rs := signers[0]
+	tampered := rs.sign(t, nil, validClaims(now))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	cases := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"expired", rs.sign(t, nil, with("exp", now.Add(-time.Minute).Unix())), "expired"},
+		{"missing exp", rs.sign(t, nil, with("exp", nil)), "exp"},
+		{"not yet valid", rs.sign(t, nil, with("nbf", now.Add(time.Minute).Unix())), "not valid yet"},
+		{"wrong audience", rs.sign(t, nil, with("aud", "other")), "audience"},
+		{"wrong issuer", rs.sign(t, nil, with("iss", "https://evil.example.com")), "issuer"},
+		{"bad signature", tampered, "signature"},
+		{"alg none", rs.sign(t, map[string]any{"alg": "none"}, validClaims(now)), "algorithm"},
+		{"hmac", rs.sign(t, map[string]any{"alg": "HS256"}, validClaims(now)), "algorithm"},
+		{"alg swapped to key of other type", rs.sign(t, map[string]any{"alg": "ES256"}, validClaims(now)), "key"},
+		{"malformed", "not-a-jwt", "malformed"},
+	}
+	for _, c := range cases {
+		_, err := v.Validate(context.Background(), c.token)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.want, err)
+		}
+	}
+
+	// Dentro da tolerância de relógio o token expirado ainda vale
+	if _, err := v.Validate(context.Background(), rs.sign(t, nil, with("exp", now.Add(-2*time.Second).Unix()))); err != nil {
+		t.Fatalf("expected token within leeway to be accepted, got %v", err)
+	}
+}
+
+func TestJWKSRefreshesOnUnknownKid(t *testing.T) {
+	signers := newTestSigners(t)
+	old := signers[:1]
+	var current atomic.Pointer[[]testSigner]
+	current.Store(&old)
+	var fetches atomic.Int32
+	srv := jwksServer(t, &current, &fetches)
+
+	jwks := NewJWKS(srv.URL, "")
+	if err := jwks.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	v := NewJWTValidator(jwks, JWTConfig{})
+
+	// Rotação no provedor: a chave nova só aparece na próxima busca
+	current.Store(&signers)
+	jwks.lastLoad = time.Time{}
+
+	token := signers[2].sign(t, nil, validClaims(time.Now()))
+	if _, err := v.Validate(context.Background(), token); err != nil {
+		t.Fatalf("expected refresh on unknown kid, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("expected 2 fetches, got %d", got)
+	}
+
+	// Um kid desconhecido logo depois não gera nova busca
+	unknown := testSigner{kid: "unknown", alg: "EdDSA", key: signers[2].key}
+	if _, err := v.Validate(context.Background(), unknown.sign(t, nil, validClaims(time.Now()))); err == nil {
+		t.Fatal("expected unknown kid to fail")
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("expected refresh to be throttled, got %d fetches", got)
+	}
+}
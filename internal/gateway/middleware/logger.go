@@ -39,7 +39,25 @@ func Logger(next http.Handler) http.Handler {
 		}
 
 		// log estruturado
-		slog.Info("request processed",
+		msg := "request processed"
+		var extra []any
+		if wrapped.streaming {
+			extra = []any{"stream", true, "ttfb_ms", wrapped.headerAt.Sub(start).Milliseconds()}
+		}
+		if IsGRPC(r) {
+			extra = append(extra, "grpc_status", GRPCStatus(w.Header()))
+		}
+		if wrapped.upgraded != nil {
+			// Num Upgrade o handler retorna quando a conexão fecha
+			msg = "upgraded connection closed"
+			extra = []any{
+				"upgrade", r.Header.Get("Upgrade"),
+				"bytes_in", wrapped.bytesIn(),
+				"bytes_out", wrapped.bytesOut(),
+			}
+		}
+
+		slog.Info(msg, append([]any{
 			"method", r.Method,
 			"path", r.URL.Path,
 			"host", host,
@@ -49,6 +67,6 @@ func Logger(next http.Handler) http.Handler {
 			"api_key_name", apiKeyName,
 			"quota_count", quotaCount,
 			"quota_limit", quotaLimit,
-		)
+		}, extra...)...)
 	})
 }
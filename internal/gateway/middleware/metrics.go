@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/metrics"
+)
+
+// Metrics conta e mede cada requisição por rota, upstream, classe de status e
+// nome da API Key; fica por fora do Recover para ver os 500 de panics e por
+// fora do rate limit e da quota para ver as rejeições
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Reaproveita o RequestInfo criado pelo Tracing, quando houver
+		ctx := r.Context()
+		info := RequestInfoFromContext(ctx)
+		if info == nil {
+			info = &RequestInfo{}
+			ctx = SetRequestInfo(ctx, info)
+		}
+		wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		labels := []string{
+			metrics.RouteLabels.Value(info.Route),
+			metrics.UpstreamLabels.Value(info.Upstream),
+			metrics.StatusClass(wrapped.status),
+			metrics.APIKeyLabels.Value(info.APIKeyName),
+		}
+		metrics.RequestsTotal.WithLabelValues(labels...).Inc()
+
+		switch {
+		case wrapped.upgraded != nil:
+			// Conexão com Upgrade dura até o cliente fechar; não é latência de requisição
+		case wrapped.streaming:
+			// Num stream a latência vai até os headers; o tempo aberto tem histograma próprio
+			metrics.RequestDuration.WithLabelValues(labels...).Observe(wrapped.headerAt.Sub(start).Seconds())
+			metrics.StreamDuration.WithLabelValues(labels[0], labels[1]).Observe(metrics.Since(wrapped.headerAt))
+		default:
+			metrics.RequestDuration.WithLabelValues(labels...).Observe(metrics.Since(start))
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsUsesRequestInfoFromInnerLayers(t *testing.T) {
+	// Simula o proxy preenchendo rota e upstream lá dentro da cadeia
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := RequestInfoFromContext(r.Context())
+		info.APIKeyName = "metrics-test-key"
+		info.Route = "billing"
+		info.Upstream = "pool://billing"
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	counter := metrics.RequestsTotal.WithLabelValues("billing", "pool://billing", "5xx", "metrics-test-key")
+	before := testutil.ToFloat64(counter)
+
+	rec := httptest.NewRecorder()
+	Metrics(inner).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/billing/invoices", nil))
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Fatalf("expected one request counted with inner labels, got %v", got)
+	}
+}
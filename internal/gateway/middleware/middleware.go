@@ -4,7 +4,7 @@ import (
 	"net/http"
 
 	"github.com/martinsdevv/aegis/internal/config"
-	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
 )
 
 func Chain(h http.Handler, mws ...Middleware) http.Handler {
@@ -14,15 +14,62 @@ func Chain(h http.Handler, mws ...Middleware) http.Handler {
 	return h
 }
 
-func NewMiddleware(handler http.Handler, cfg config.Config, rlStore *RLStore, quotaMgr *QuotaManager, redisClient *redis.Client, apiKeyStore *APIKeyStore) http.Handler {
+func NewMiddleware(handler http.Handler, cfg config.Config, limiter RateLimiter, conns *ConnLimiter, quotaMgr *QuotaManager, usage *UsagePublisher, auth Middleware) http.Handler {
 	return Chain(handler,
 		RequestID(),
 		ContentID(),
+		GRPC,
+		Tracing,
+		Metrics,
 		Recover,
-		WithAPIKey(apiKeyStore),
-		RateLimit(rlStore),
+		auth,
+		RateLimit(limiter, ConfigLimit(cfg)),
+		conns.Limit(cfg.AEGIS_UPGRADE_MAX_CONNS_PER_KEY),
 		quotaMgr.Enforce,
 		Logger,
-		PublishUsage(redisClient, "aether.usage.v1"),
+		PublishUsage(usage),
 	)
 }
+
+// ConfigLimit é o limite padrão das chaves sem rate limit próprio
+func ConfigLimit(cfg config.Config) Limit {
+	return Limit{Rate: rate.Limit(cfg.AEGIS_DEFAULT_RATE_LIMIT_RPS), Burst: cfg.AEGIS_DEFAULT_RATE_LIMIT_BURST}
+}
+
+// EnabledAuthMethods são os métodos da cadeia de ConfigAuthenticators; as rotas
+// só podem restringir a estes
+func EnabledAuthMethods(cfg config.Config, jwks *JWKS) []string {
+	var enabled []string
+	for _, m := range cfg.AEGIS_AUTH_METHODS {
+		if m == "jwt" && jwks == nil {
+			continue
+		}
+		enabled = append(enabled, m)
+	}
+	return enabled
+}
+
+// ConfigAuthenticators monta a cadeia de AEGIS_AUTH_METHODS na ordem
+// configurada; jwt fica de fora sem JWKS
+func ConfigAuthenticators(cfg config.Config, store *APIKeyStore, jwks *JWKS) []Authenticator {
+	var auths []Authenticator
+	for _, m := range cfg.AEGIS_AUTH_METHODS {
+		switch m {
+		case "api_key":
+			auths = append(auths, APIKeyAuth(store))
+		case "client_cert":
+			auths = append(auths, ClientCertAuth(store))
+		case "jwt":
+			if jwks == nil {
+				continue
+			}
+			validator := NewJWTValidator(jwks, JWTConfig{
+				Issuer:    cfg.AEGIS_JWT_ISSUER,
+				Audiences: cfg.AEGIS_JWT_AUDIENCES,
+				Leeway:    cfg.AEGIS_JWT_LEEWAY,
+			})
+			auths = append(auths, JWTAuth(store, validator, cfg.AEGIS_JWT_CONSUMER_CLAIM))
+		}
+	}
+	return auths
+}
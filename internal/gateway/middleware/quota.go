@@ -5,23 +5,36 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/martinsdevv/aegis/internal/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type QuotaManager struct {
 	client       *redis.Client
+	defaultLimit atomic.Int64
 	fallbackMap  sync.Map
 	currentMonth string
 	mu           sync.Mutex
 }
 
-func NewQuotaManager(client *redis.Client) *QuotaManager {
-	return &QuotaManager{
+// NewQuotaManager usa defaultLimit para chaves sem quota própria
+func NewQuotaManager(client *redis.Client, defaultLimit int64) *QuotaManager {
+	qm := &QuotaManager{
 		client:       client,
 		currentMonth: time.Now().UTC().Format("2006-01"),
 	}
+	qm.defaultLimit.Store(defaultLimit)
+	return qm
+}
+
+// SetDefaultLimit troca a quota padrão no reload sem perder o fallback em memória
+func (qm *QuotaManager) SetDefaultLimit(limit int64) {
+	qm.defaultLimit.Store(limit)
 }
 
 func (qm *QuotaManager) Enforce(next http.Handler) http.Handler {
@@ -32,10 +45,7 @@ func (qm *QuotaManager) Enforce(next http.Handler) http.Handler {
 			return
 		}
 
-		limit := int64(apiKey.MonthlyQuota)
-		if limit <= 0 {
-			limit = 10000
-		}
+		limit := QuotaLimit(apiKey, qm.defaultLimit.Load())
 
 		now := time.Now().UTC()
 		month := now.Format("2006-01")
@@ -48,32 +58,56 @@ func (qm *QuotaManager) Enforce(next http.Handler) http.Handler {
 		}
 		qm.mu.Unlock()
 
-		apiKeyID := strconv.FormatInt(apiKey.ID, 10)
-		key := "quota:" + apiKeyID + ":" + month
+		key := QuotaKey(apiKey.ID, month)
+		resetAt := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+
+		var count int64
+		counted := false
 
-		allowed := false
+		// O contador não pode falhar porque o cliente desistiu; o contexto só leva o span
+		spanCtx, span := tracing.Start(r.Context(), "quota")
+		spanCtx = context.WithoutCancel(spanCtx)
 
 		// Redis
 		if qm.client != nil {
-			incr := qm.client.Incr(context.Background(), key)
-			qm.client.ExpireAt(context.Background(), key, time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC))
+			incr := qm.client.Incr(spanCtx, key)
+			qm.client.ExpireAt(spanCtx, key, resetAt)
 
-			if val, err := incr.Result(); err == nil && val <= limit {
-				allowed = true
+			if val, err := incr.Result(); err == nil {
+				count = val
+				counted = true
 			}
 		}
 
-		// fallback in-memory
-		if !allowed {
+		// fallback in-memory apenas se o Redis falhou
+		if !counted {
 			v, _ := qm.fallbackMap.LoadOrStore(key, int64(0))
-			cnt := v.(int64) + 1
-			qm.fallbackMap.Store(key, cnt)
-			if cnt <= limit {
-				allowed = true
-			}
+			count = v.(int64) + 1
+			qm.fallbackMap.Store(key, count)
+		}
+
+		span.SetAttributes(
+			attribute.Int64("aegis.quota.count", count),
+			attribute.Int64("aegis.quota.limit", limit),
+			attribute.Bool("aegis.quota.fallback", !counted),
+		)
+		span.End()
+
+		resetAfter := resetAt.Sub(now)
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
 		}
 
-		if !allowed {
+		h := w.Header()
+		h.Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+		h.Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		h.Set("X-Quota-Reset", formatSeconds(resetAfter))
+
+		if count > limit {
+			// A quota só volta na virada do mês
+			h.Set("Retry-After", formatSeconds(resetAfter))
+			metrics.QuotaRejections.WithLabelValues(metrics.APIKeyLabels.Value(apiKey.Name)).Inc()
 			http.Error(w, "quota exceeded", http.StatusForbidden)
 			return
 		}
@@ -84,3 +118,20 @@ func (qm *QuotaManager) Enforce(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// QuotaLimit devolve a quota mensal da chave; fallback vale para chaves sem
+// quota própria e, se zerado, é 10000
+func QuotaLimit(apiKey *APIKey, fallback int64) int64 {
+	if apiKey.MonthlyQuota <= 0 {
+		if fallback <= 0 {
+			return 10000
+		}
+		return fallback
+	}
+	return int64(apiKey.MonthlyQuota)
+}
+
+// QuotaKey devolve a chave do contador mensal no formato quota:<api_key_id>:<YYYY-MM>
+func QuotaKey(apiKeyID int64, month string) string {
+	return "quota:" + strconv.FormatInt(apiKeyID, 10) + ":" + month
+}
@@ -1,51 +1,108 @@
 package middleware
 
 import (
+	"context"
+	"math"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/martinsdevv/aegis/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"golang.org/x/time/rate"
 )
 
+// Limit descreve a taxa sustentada e o burst permitidos para um consumidor
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// Result é a decisão do rate limiter para uma requisição
+type Result struct {
+	Allowed    bool
+	Limit      int           // burst configurado
+	Remaining  int           // requisições disponíveis sem espera
+	ResetAfter time.Duration // tempo até o bucket voltar a ficar cheio
+	RetryAfter time.Duration // espera até a próxima requisição ser aceita (quando negada)
+}
+
+// RateLimiter decide se uma requisição identificada por key pode seguir
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
 type rlEntry struct {
 	lim      *rate.Limiter
+	limit    Limit
 	lastSeen time.Time
 }
 
 type RLStore struct {
-	mu    sync.Mutex
-	m     map[string]*rlEntry
-	r     rate.Limit
-	burst int
-	ttl   time.Duration
+	mu  sync.Mutex
+	m   map[string]*rlEntry
+	ttl time.Duration
 }
 
-func NewRLStore(r rate.Limit, burst int, ttl time.Duration) *RLStore {
+func NewRLStore(ttl time.Duration) *RLStore {
 	return &RLStore{
-		m:     make(map[string]*rlEntry),
-		r:     r,
-		burst: burst,
-		ttl:   ttl,
+		m:   make(map[string]*rlEntry),
+		ttl: ttl,
 	}
 }
 
-func (s *RLStore) get(key string) *rate.Limiter {
+func (s *RLStore) get(key string, limit Limit) *rate.Limiter {
 	now := time.Now()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if e, ok := s.m[key]; ok {
+	// Recria o limiter se os limites da key mudaram
+	if e, ok := s.m[key]; ok && e.limit == limit {
 		e.lastSeen = now
 		return e.lim
 	}
 
-	lim := rate.NewLimiter(s.r, s.burst)
-	s.m[key] = &rlEntry{lim: lim, lastSeen: now}
+	lim := rate.NewLimiter(limit.Rate, limit.Burst)
+	s.m[key] = &rlEntry{lim: lim, limit: limit, lastSeen: now}
 	return lim
 }
 
+func (s *RLStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	lim := s.get(key, limit)
+	now := time.Now()
+	res := Result{Limit: limit.Burst}
+
+	rsv := lim.ReserveN(now, 1)
+	if !rsv.OK() {
+		return res, nil
+	}
+
+	// Reserva com atraso significa que não há token agora: devolve e informa a espera
+	if delay := rsv.DelayFrom(now); delay > 0 {
+		rsv.CancelAt(now)
+		res.RetryAfter = delay
+		res.ResetAfter = refillAfter(lim.TokensAt(now), limit)
+		return res, nil
+	}
+
+	tokens := lim.TokensAt(now)
+	res.Allowed = true
+	res.Remaining = int(math.Max(0, math.Floor(tokens)))
+	res.ResetAfter = refillAfter(tokens, limit)
+	return res, nil
+}
+
+func refillAfter(tokens float64, limit Limit) time.Duration {
+	missing := float64(limit.Burst) - tokens
+	if missing <= 0 || limit.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(missing / float64(limit.Rate) * float64(time.Second))
+}
+
 func (s *RLStore) Cleanup() {
 	now := time.Now()
 	s.mu.Lock()
@@ -58,7 +115,16 @@ func (s *RLStore) Cleanup() {
 	}
 }
 
-func RateLimit(store *RLStore) Middleware {
+// RateLimit aplica o limite da API Key; fallback vale para chaves sem limite
+// próprio e, se zerado, usa 5 req/s com burst 10
+func RateLimit(limiter RateLimiter, fallback Limit) Middleware {
+	if fallback.Rate <= 0 {
+		fallback.Rate = 5
+	}
+	if fallback.Burst <= 0 {
+		fallback.Burst = 10
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -71,10 +137,40 @@ func RateLimit(store *RLStore) Middleware {
 			// Usa ID como chave estável
 			key := strconv.FormatInt(apiKey.ID, 10)
 
-			lim := store.get(key)
+			limit := Limit{
+				Rate:  rate.Limit(apiKey.RateLimitRPS),
+				Burst: apiKey.RateLimitBurst,
+			}
+			if limit.Rate <= 0 {
+				limit.Rate = fallback.Rate
+			}
+			if limit.Burst <= 0 {
+				limit.Burst = fallback.Burst
+			}
 
-			if !lim.Allow() {
-				w.Header().Set("Retry-After", "1")
+			ctx, span := tracing.Start(r.Context(), "ratelimit")
+			res, err := limiter.Allow(ctx, key, limit)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "rate limiter failed")
+				span.End()
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			span.SetAttributes(
+				attribute.Bool("aegis.ratelimit.allowed", res.Allowed),
+				attribute.Int("aegis.ratelimit.remaining", res.Remaining),
+			)
+			span.End()
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", formatSeconds(res.ResetAfter))
+
+			if !res.Allowed {
+				h.Set("Retry-After", formatSeconds(res.RetryAfter))
+				metrics.RateLimitRejections.WithLabelValues(metrics.APIKeyLabels.Value(apiKey.Name)).Inc()
 				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
@@ -83,3 +179,11 @@ func RateLimit(store *RLStore) Middleware {
 		})
 	}
 }
+
+// formatSeconds arredonda para cima, com mínimo de 1s quando há espera
+func formatSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript implementa GCRA (Generic Cell Rate Algorithm) de forma atômica.
+// Guarda apenas o TAT (theoretical arrival time) em segundos por chave.
+//
+// KEYS[1] = chave do limiter
+// ARGV[1] = taxa (requisições por segundo)
+// ARGV[2] = burst
+//
+// Retorna {permitido, restantes, retry_after, reset_after}, com os tempos em
+// segundos como string para não perder a fração na conversão do Redis.
+var gcraScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local emission = 1 / rate
+local tolerance = emission * burst
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local tat = tonumber(redis.call("GET", key))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+local allow_at = new_tat - tolerance
+if allow_at > now then
+	return {0, 0, tostring(allow_at - now), tostring(tat - now)}
+end
+
+redis.call("SET", key, tostring(new_tat), "EX", math.ceil(new_tat - now) + 1)
+
+local remaining = math.floor((now - allow_at) / emission + 1e-9)
+return {1, remaining, "0", tostring(new_tat - now)}
+`)
+
+// RedisRateLimiter compartilha o estado do rate limit entre réplicas do gateway.
+// Quando o Redis está inacessível, delega para o fallback (em geral um RLStore).
+type RedisRateLimiter struct {
+	client   *redis.Client
+	fallback RateLimiter
+}
+
+func NewRedisRateLimiter(client *redis.Client, fallback RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:   client,
+		fallback: fallback,
+	}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if l.client == nil {
+		return l.allowFallback(ctx, key, limit, nil)
+	}
+
+	vals, err := gcraScript.Run(ctx, l.client, []string{l.redisKey(key)}, float64(limit.Rate), limit.Burst).Slice()
+	if err != nil {
+		return l.allowFallback(ctx, key, limit, err)
+	}
+
+	res, err := parseGCRAResult(vals)
+	if err != nil {
+		return l.allowFallback(ctx, key, limit, err)
+	}
+	res.Limit = limit.Burst
+
+	return res, nil
+}
+
+func (l *RedisRateLimiter) allowFallback(ctx context.Context, key string, limit Limit, cause error) (Result, error) {
+	if l.fallback == nil {
+		return Result{Limit: limit.Burst}, cause
+	}
+	if cause != nil {
+		slog.Warn("redis rate limit unavailable, using in-memory fallback", "err", cause)
+	}
+	return l.fallback.Allow(ctx, key, limit)
+}
+
+func (l *RedisRateLimiter) redisKey(key string) string {
+	return "aegis:ratelimit:" + key
+}
+
+func parseGCRAResult(vals []interface{}) (Result, error) {
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("unexpected gcra result: %v", vals)
+	}
+
+	allowed, ok1 := vals[0].(int64)
+	remaining, ok2 := vals[1].(int64)
+	retryRaw, ok3 := vals[2].(string)
+	resetRaw, ok4 := vals[3].(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return Result{}, fmt.Errorf("unexpected gcra result: %v", vals)
+	}
+
+	retry, err := strconv.ParseFloat(retryRaw, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	reset, err := strconv.ParseFloat(resetRaw, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retry * float64(time.Second)),
+		ResetAfter: time.Duration(reset * float64(time.Second)),
+	}, nil
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisRateLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+
+	t.Run("allows burst then rejects", func(t *testing.T) {
+		lim := NewRedisRateLimiter(client, nil)
+		limit := Limit{Rate: 1, Burst: 3}
+
+		for i := 0; i < 3; i++ {
+			res, err := lim.Allow(ctx, "burst", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Allowed {
+				t.Fatalf("request %d: expected allowed", i+1)
+			}
+			if res.Remaining != 2-i {
+				t.Fatalf("request %d: expected remaining %d, got %d", i+1, 2-i, res.Remaining)
+			}
+		}
+
+		res, err := lim.Allow(ctx, "burst", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed {
+			t.Fatal("expected request over burst to be rejected")
+		}
+		if res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+			t.Fatalf("expected retry after within 1s, got %s", res.RetryAfter)
+		}
+	})
+
+	t.Run("state is shared between limiters", func(t *testing.T) {
+		a := NewRedisRateLimiter(client, nil)
+		b := NewRedisRateLimiter(client, nil)
+		limit := Limit{Rate: 1, Burst: 1}
+
+		if res, _ := a.Allow(ctx, "shared", limit); !res.Allowed {
+			t.Fatal("expected first request allowed")
+		}
+		if res, _ := b.Allow(ctx, "shared", limit); res.Allowed {
+			t.Fatal("expected second replica to see consumed token")
+		}
+	})
+
+	t.Run("falls back when redis is down", func(t *testing.T) {
+		down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+		defer down.Close()
+
+		lim := NewRedisRateLimiter(down, NewRLStore(time.Minute))
+		limit := Limit{Rate: 1, Burst: 1}
+
+		res, err := lim.Allow(ctx, "fallback", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed {
+			t.Fatal("expected fallback to allow first request")
+		}
+		if res, _ := lim.Allow(ctx, "fallback", limit); res.Allowed {
+			t.Fatal("expected fallback to reject second request")
+		}
+	})
+}
+
+func TestRLStoreRebuildsOnLimitChange(t *testing.T) {
+	store := NewRLStore(time.Minute)
+	ctx := context.Background()
+
+	if res, _ := store.Allow(ctx, "k", Limit{Rate: 1, Burst: 1}); !res.Allowed {
+		t.Fatal("expected first request allowed")
+	}
+	if res, _ := store.Allow(ctx, "k", Limit{Rate: 1, Burst: 1}); res.Allowed {
+		t.Fatal("expected second request rejected")
+	}
+
+	// Limite maior vindo do banco deve valer imediatamente
+	if res, _ := store.Allow(ctx, "k", Limit{Rate: 1, Burst: 5}); !res.Allowed {
+		t.Fatal("expected request allowed after limit change")
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	apiKey := &APIKey{ID: 1, RateLimitRPS: 0.5, RateLimitBurst: 2}
+
+	h := RateLimit(NewRLStore(time.Minute), Limit{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/get", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxKeyAPIKey{}, apiKey))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("expected RateLimit-Limit 2, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "1" {
+		t.Fatalf("expected RateLimit-Remaining 1, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Reset"); got != "2" {
+		t.Fatalf("expected RateLimit-Reset 2, got %q", got)
+	}
+
+	do()
+	rec = do()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("expected RateLimit-Remaining 0, got %q", got)
+	}
+	// 0.5 rps: o próximo token chega em ~2s
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", got)
+	}
+}
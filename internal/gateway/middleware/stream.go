@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// IsEventStream indica uma resposta Server-Sent Events
+func IsEventStream(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// AcceptsEventStream indica um cliente SSE (EventSource envia Accept: text/event-stream)
+func AcceptsEventStream(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			if mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(part)); mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
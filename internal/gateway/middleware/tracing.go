@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/martinsdevv/aegis/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing abre o span de servidor da requisição, continuando o trace do
+// traceparent recebido; os spans do restante da cadeia e do proxy são filhos dele
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracing.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if reqID, ok := RequestIDFromContext(ctx); ok {
+			span.SetAttributes(attribute.String("aegis.request_id", reqID))
+		}
+
+		// Compartilha o RequestInfo com o Metrics para ler rota e upstream no fim
+		info := RequestInfoFromContext(ctx)
+		if info == nil {
+			info = &RequestInfo{}
+			ctx = SetRequestInfo(ctx, info)
+		}
+		wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		if info.Route != "" {
+			span.SetName(r.Method + " " + info.Route)
+			span.SetAttributes(semconv.HTTPRoute(info.Route))
+		}
+		if info.Upstream != "" {
+			span.SetAttributes(attribute.String("aegis.upstream", info.Upstream))
+		}
+		if info.APIKeyName != "" {
+			span.SetAttributes(attribute.String("aegis.api_key", info.APIKeyName))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.status))
+		if wrapped.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.status))
+		}
+	})
+}
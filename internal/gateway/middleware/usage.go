@@ -1,14 +1,13 @@
 package middleware
 
 import (
-	"context"
-	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/martinsdevv/aegis/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -21,106 +20,84 @@ type UsageEvent struct {
 	Path       string `json:"path"`
 	Method     string `json:"method"`
 	StatusCode int    `json:"status_code"`
-	LatencyMS  int64  `json:"latency_ms"`
+	BytesOut   int64  `json:"bytes_out"`
+	BytesIn    int64  `json:"bytes_in,omitempty"` // só em conexões com Upgrade
+	Upgrade    string `json:"upgrade,omitempty"`
+	GRPCMethod string `json:"grpc_method,omitempty"` // /pacote.Serviço/Método
+	GRPCStatus string `json:"grpc_status,omitempty"` // código numérico do grpc-status
+	LatencyMS  int64  `json:"latency_ms"`            // em streams e Upgrade, até os headers
 	Timestamp  string `json:"timestamp"`
 }
 
 func NewRedisClient(addr string) *redis.Client {
-	return redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr: addr,
 	})
+	client.AddHook(metrics.RedisHook{})
+	return client
 }
 
-func PublishUsage(redisClient *redis.Client, streamName string) Middleware {
+// PublishUsage monta o evento de uso depois que a resposta termina e o entrega
+// ao publisher assíncrono; o corpo é repassado ao cliente enquanto é escrito
+func PublishUsage(publisher *UsagePublisher) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 			start := time.Now()
 
-			// buffer de resposta
-			buf := newResponseBuffer()
+			wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 
-			next.ServeHTTP(buf, r)
+			next.ServeHTTP(wrapped, r)
 
 			apiKey, ok := APIKeyFromContext(r.Context())
-			if !ok {
-				buf.FlushTo(w)
+			if !ok || publisher == nil {
 				return
 			}
 
 			reqID, _ := RequestIDFromContext(r.Context())
 
-			event := UsageEvent{
-				EventID:    uuid.NewString(),
-				EventVer:   1,
-				RequestID:  reqID,
-				APIKeyID:   strconv.FormatInt(apiKey.ID, 10),
-				Upstream:   apiKey.UpstreamHost,
-				Path:       r.URL.Path,
-				Method:     r.Method,
-				StatusCode: buf.status,
-				LatencyMS:  time.Since(start).Milliseconds(),
-				Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			// Um Upgrade gera um único evento, no fechamento da conexão
+			upgrade := ""
+			if wrapped.upgraded != nil {
+				upgrade = strings.ToLower(r.Header.Get("Upgrade"))
 			}
 
-			payload, err := json.Marshal(event)
-			if err != nil {
-				log.Println("marshal error:", err)
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
+			// O upstream resolvido pelo proxy (rota ou pool), como nas métricas; o da
+			// chave só vale quando nenhuma rota atendeu
+			upstream := apiKey.UpstreamHost
+			if info := RequestInfoFromContext(r.Context()); info != nil && info.Upstream != "" {
+				upstream = info.Upstream
 			}
 
-			if redisClient != nil {
-				err = redisClient.XAdd(context.Background(), &redis.XAddArgs{
-					Stream: streamName,
-					Values: map[string]interface{}{"payload": payload},
-				}).Err()
+			var grpcMethod, grpcStatus string
+			if IsGRPC(r) {
+				grpcMethod, grpcStatus = GRPCMethod(r.URL.Path), GRPCStatus(w.Header())
+			}
 
-				if err != nil {
-					log.Println("redis error:", err)
-					http.Error(w, "service unavailable", http.StatusServiceUnavailable)
-					return
-				}
+			// Como nas métricas, um stream ou Upgrade conta a latência até os headers;
+			// o tempo aberto distorceria os percentis de /v1/usage
+			latency := time.Since(start)
+			if wrapped.streaming || wrapped.upgraded != nil {
+				latency = wrapped.headerAt.Sub(start)
 			}
 
-			// só escreve depois do sucesso
-			buf.FlushTo(w)
+			publisher.Publish(UsageEvent{
+				EventID:    uuid.NewString(),
+				EventVer:   1,
+				RequestID:  reqID,
+				APIKeyID:   strconv.FormatInt(apiKey.ID, 10),
+				Upstream:   upstream,
+				Path:       r.URL.Path,
+				Method:     r.Method,
+				StatusCode: wrapped.status,
+				BytesOut:   wrapped.bytesOut(),
+				BytesIn:    wrapped.bytesIn(),
+				Upgrade:    upgrade,
+				GRPCMethod: grpcMethod,
+				GRPCStatus: grpcStatus,
+				LatencyMS:  latency.Milliseconds(),
+				Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			})
 		})
 	}
 }
-
-type responseBuffer struct {
-	header http.Header
-	body   []byte
-	status int
-}
-
-func newResponseBuffer() *responseBuffer {
-	return &responseBuffer{
-		header: make(http.Header),
-		status: http.StatusOK,
-	}
-}
-
-func (r *responseBuffer) Header() http.Header {
-	return r.header
-}
-
-func (r *responseBuffer) Write(b []byte) (int, error) {
-	r.body = append(r.body, b...)
-	return len(b), nil
-}
-
-func (r *responseBuffer) WriteHeader(statusCode int) {
-	r.status = statusCode
-}
-
-func (r *responseBuffer) FlushTo(w http.ResponseWriter) {
-	for k, v := range r.header {
-		for _, vv := range v {
-			w.Header().Add(k, vv)
-		}
-	}
-	w.WriteHeader(r.status)
-	w.Write(r.body)
-}
@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	usageQueueSize      = 10000
+	usageOverflowSize   = 10000
+	usageBatchSize      = 100
+	usageFlushInterval  = time.Second
+	usageReplayEvery    = 10 * time.Second
+	usagePublishTimeout = 5 * time.Second
+)
+
+// UsagePublisher entrega eventos de uso ao Redis fora do caminho da requisição.
+// Eventos vão para uma fila em memória e são enviados em lotes; se o Redis
+// falhar ou a fila encher, vão para um spool em disco reenviado depois. O
+// disco só é tocado pelos workers, nunca pela requisição.
+type UsagePublisher struct {
+	client    *redis.Client
+	stream    string
+	spoolPath string
+
+	queue    chan []byte
+	overflow chan []byte // eventos da fila cheia, gravados no spool pelo spoolWorker
+	done     chan struct{}
+	spooled  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	spoolMu sync.Mutex
+}
+
+func NewUsagePublisher(client *redis.Client, stream, spoolPath string) (*UsagePublisher, error) {
+	if spoolPath != "" {
+		if err := os.MkdirAll(filepath.Dir(spoolPath), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &UsagePublisher{
+		client:    client,
+		stream:    stream,
+		spoolPath: spoolPath,
+		queue:     make(chan []byte, usageQueueSize),
+		overflow:  make(chan []byte, usageOverflowSize),
+		done:      make(chan struct{}),
+		spooled:   make(chan struct{}),
+	}, nil
+}
+
+// Start reenvia o spool deixado pela execução anterior e inicia os workers
+func (p *UsagePublisher) Start() {
+	go p.run()
+	go p.spoolWorker()
+}
+
+// Publish nunca bloqueia a requisição: com a fila cheia o evento vai para a
+// fila do spool e, se ela também estiver cheia, é descartado e contado
+func (p *UsagePublisher) Publish(event UsageEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("usage marshal failed", "err", err)
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.closed {
+		select {
+		case p.queue <- payload:
+			return
+		default:
+		}
+		select {
+		case p.overflow <- payload:
+			return
+		default:
+		}
+	}
+	metrics.UsageEventsDropped.Inc()
+}
+
+// Close para de aceitar eventos na fila e espera o worker entregar ou
+// gravar no spool o que restou
+func (p *UsagePublisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+		close(p.overflow)
+	}
+	p.mu.Unlock()
+
+	for _, done := range []chan struct{}{p.done, p.spooled} {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// spoolWorker grava no spool, em lotes, os eventos que não couberam na fila
+func (p *UsagePublisher) spoolWorker() {
+	defer close(p.spooled)
+
+	batch := make([][]byte, 0, usageBatchSize)
+	for payload := range p.overflow {
+		batch = append(batch, payload)
+		// Junta o que já está na fila sem esperar por mais
+	drain:
+		for len(batch) < usageBatchSize {
+			select {
+			case more, ok := <-p.overflow:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, more)
+			default:
+				break drain
+			}
+		}
+		p.spool(batch)
+		batch = batch[:0]
+	}
+}
+
+func (p *UsagePublisher) run() {
+	defer close(p.done)
+
+	p.replay()
+
+	flush := time.NewTicker(usageFlushInterval)
+	defer flush.Stop()
+	replay := time.NewTicker(usageReplayEvery)
+	defer replay.Stop()
+
+	batch := make([][]byte, 0, usageBatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.send(batch); err != nil {
+			slog.Warn("usage publish failed, spooling", "events", len(batch), "err", err)
+			p.spool(batch)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case payload, ok := <-p.queue:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, payload)
+			if len(batch) >= usageBatchSize {
+				send()
+			}
+		case <-flush.C:
+			send()
+		case <-replay.C:
+			p.replay()
+		}
+	}
+}
+
+// send publica o lote num único pipeline
+func (p *UsagePublisher) send(batch [][]byte) error {
+	if p.client == nil {
+		return errors.New("redis client not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usagePublishTimeout)
+	defer cancel()
+
+	pipe := p.client.Pipeline()
+	for _, payload := range batch {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.stream,
+			Values: map[string]interface{}{"payload": payload},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// spool anexa os eventos ao arquivo, um JSON por linha
+func (p *UsagePublisher) spool(batch [][]byte) {
+	if p.spoolPath == "" {
+		slog.Error("usage events dropped, spool disabled", "events", len(batch))
+		return
+	}
+
+	p.spoolMu.Lock()
+	defer p.spoolMu.Unlock()
+
+	f, err := os.OpenFile(p.spoolPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		slog.Error("usage spool open failed", "path", p.spoolPath, "events", len(batch), "err", err)
+		return
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, payload := range batch {
+		_, _ = w.Write(payload)
+		_ = w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		slog.Error("usage spool write failed", "path", p.spoolPath, "err", err)
+		return
+	}
+	_ = f.Sync()
+}
+
+// replay move o spool para um arquivo de trabalho e reenvia em lotes; o que
+// falhar volta para o spool. Um arquivo de trabalho deixado por um crash é
+// reenviado antes, então eventos podem duplicar, mas não se perdem
+func (p *UsagePublisher) replay() {
+	if p.spoolPath == "" {
+		return
+	}
+
+	work := p.spoolPath + ".replay"
+
+	// Com o Redis fora, não vale a pena reescrever o spool inteiro
+	if p.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), usagePublishTimeout)
+	err := p.client.Ping(ctx).Err()
+	cancel()
+	if err != nil {
+		return
+	}
+
+	p.spoolMu.Lock()
+	if _, err := os.Stat(work); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(p.spoolPath, work); err != nil {
+			p.spoolMu.Unlock()
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Error("usage spool rotate failed", "err", err)
+			}
+			return
+		}
+	}
+	p.spoolMu.Unlock()
+
+	f, err := os.Open(work)
+	if err != nil {
+		slog.Error("usage spool open failed", "path", work, "err", err)
+		return
+	}
+
+	var (
+		batch  [][]byte
+		sent   int
+		failed error
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if failed == nil {
+			failed = p.send(batch)
+		}
+		if failed != nil {
+			p.spool(batch)
+		} else {
+			sent += len(batch)
+		}
+		batch = nil
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		batch = append(batch, append([]byte(nil), sc.Bytes()...))
+		if len(batch) >= usageBatchSize {
+			flush()
+		}
+	}
+	flush()
+	scanErr := sc.Err()
+	f.Close()
+
+	if scanErr != nil {
+		// Separa o arquivo para inspeção em vez de reenviar em loop
+		bad := work + ".corrupt-" + time.Now().UTC().Format("20060102T150405")
+		_ = os.Rename(work, bad)
+		slog.Error("usage spool read failed", "path", bad, "err", scanErr)
+		return
+	}
+	_ = os.Remove(work)
+
+	if sent > 0 {
+		slog.Info("usage spool replayed", "events", sent)
+	}
+	if failed != nil {
+		slog.Warn("usage spool replay incomplete, redis unavailable", "err", failed)
+	}
+}
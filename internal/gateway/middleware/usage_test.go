@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPublishUsageStreamsResponse(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	publisher, err := NewUsagePublisher(client, "usage-test", filepath.Join(t.TempDir(), "spool.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.Start()
+
+	release := make(chan struct{})
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+
+		// Só termina depois que o cliente recebeu o primeiro evento
+		<-release
+		_, _ = w.Write([]byte("data: second\n\n"))
+	})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := SetAPIKey(r.Context(), &APIKey{ID: 7, UpstreamHost: "events:8080"})
+		PublishUsage(publisher)(upstream).ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("expected first event before the handler finished, got %q (%v)", line, err)
+	}
+	close(release)
+
+	// Consome o restante para garantir que o handler terminou
+	for {
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+	}
+	srv.Close()
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := client.XRange(context.Background(), "usage-test", "-", "+").Result()
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected one usage event, got %d (%v)", len(msgs), err)
+	}
+
+	var event UsageEvent
+	if err := json.Unmarshal([]byte(msgs[0].Values["payload"].(string)), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.StatusCode != http.StatusOK || event.BytesOut != int64(len("data: first\n\ndata: second\n\n")) {
+		t.Fatalf("unexpected usage event: %+v", event)
+	}
+}
+
+func TestPublishUsageSpoolsWhileRedisIsDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	spoolPath := filepath.Join(t.TempDir(), "spool.jsonl")
+	ctx := context.Background()
+
+	mr.Close()
+
+	publisher, err := NewUsagePublisher(client, "usage-test", spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.Start()
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req = req.WithContext(SetAPIKey(req.Context(), &APIKey{ID: 1}))
+	rr := httptest.NewRecorder()
+
+	PublishUsage(publisher)(upstream).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated || rr.Body.String() != "ok" {
+		t.Fatalf("expected upstream response untouched, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if err := publisher.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(spoolPath); err != nil || strings.Count(string(data), "\n") != 1 {
+		t.Fatalf("expected one spooled event, got %q (%v)", data, err)
+	}
+
+	// Na próxima inicialização o spool é reenviado
+	if err := mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	publisher, err = NewUsagePublisher(client, "usage-test", spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.Start()
+	if err := publisher.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := client.XRange(ctx, "usage-test", "-", "+").Result()
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected spooled event to be replayed, got %d (%v)", len(msgs), err)
+	}
+	if _, err := os.Stat(spoolPath); !os.IsNotExist(err) {
+		t.Fatalf("expected spool to be consumed, got %v", err)
+	}
+}
+
+func TestPublishOverflowNeverBlocks(t *testing.T) {
+	spoolPath := filepath.Join(t.TempDir(), "spool.jsonl")
+	publisher, err := NewUsagePublisher(nil, "usage-test", spoolPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.queue = make(chan []byte, 1)
+	publisher.overflow = make(chan []byte, 1)
+	dropped := testutil.ToFloat64(metrics.UsageEventsDropped)
+
+	// Sem os workers rodando: fila, fila do spool e descarte, sem tocar no disco
+	for i := 0; i < 3; i++ {
+		publisher.Publish(UsageEvent{APIKeyID: strconv.Itoa(i)})
+	}
+	if _, err := os.Stat(spoolPath); !os.IsNotExist(err) {
+		t.Fatalf("expected Publish not to write the spool, got %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.UsageEventsDropped) - dropped; got != 1 {
+		t.Fatalf("expected 1 dropped event, got %v", got)
+	}
+
+	// Sem Redis os workers gravam no spool o que ficou nas duas filas
+	publisher.Start()
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(spoolPath); err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("expected two spooled events, got %q (%v)", data, err)
+	}
+}
+
+func TestPublishUsageUpstreamFromProxy(t *testing.T) {
+	publisher, err := NewUsagePublisher(nil, "usage-test", filepath.Join(t.TempDir(), "spool.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish := func(routed string) UsageEvent {
+		t.Helper()
+		info := &RequestInfo{}
+		upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info.Upstream = routed
+		})
+		req := httptest.NewRequest(http.MethodGet, "/billing/x", nil)
+		ctx := SetRequestInfo(req.Context(), info)
+		ctx = SetAPIKey(ctx, &APIKey{ID: 1, UpstreamHost: "legacy:8080"})
+		PublishUsage(publisher)(upstream).ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+		var event UsageEvent
+		if err := json.Unmarshal(<-publisher.queue, &event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	if got := publish("pool://billing").Upstream; got != "pool://billing" {
+		t.Fatalf("expected the upstream resolved by the proxy, got %q", got)
+	}
+	if got := publish("").Upstream; got != "legacy:8080" {
+		t.Fatalf("expected the key upstream without a resolved route, got %q", got)
+	}
+}
+
+// Stream e Upgrade ficam abertos o quanto o cliente quiser; a latência do
+// evento vai só até os headers
+func TestPublishUsageLatencyExcludesOpenConnection(t *testing.T) {
+	publisher, err := NewUsagePublisher(nil, "usage-test", filepath.Join(t.TempDir(), "spool.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const open = 300 * time.Millisecond
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			conn, brw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			_ = brw.Flush()
+			time.Sleep(open)
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		time.Sleep(open)
+		_, _ = w.Write([]byte("data: done\n\n"))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := SetAPIKey(r.Context(), &APIKey{ID: 1})
+		PublishUsage(publisher)(upstream).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer srv.Close()
+
+	for _, upgrade := range []string{"", "test"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+		if upgrade != "" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", upgrade)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+
+		var event UsageEvent
+		if err := json.Unmarshal(<-publisher.queue, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Upgrade != upgrade || event.LatencyMS >= open.Milliseconds() {
+			t.Fatalf("upgrade %q: expected latency up to the headers, got %+v", upgrade, event)
+		}
+	}
+}
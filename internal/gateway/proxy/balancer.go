@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+const (
+	StrategyRoundRobin         = "round_robin"
+	StrategyWeightedRoundRobin = "weighted_round_robin"
+	StrategyLeastConnections   = "least_connections"
+	StrategyConsistentHash     = "consistent_hash"
+)
+
+// Balancer escolhe um target do pool para a requisição, ignorando os fora do
+// ar e aqueles para os quais skip (quando não nil) devolve true
+type Balancer interface {
+	Pick(r *http.Request, skip func(*Target) bool) *Target
+}
+
+// usable informa se o balancer pode escolher o target
+func usable(t *Target, skip func(*Target) bool) bool {
+	return t.Healthy() && (skip == nil || !skip(t))
+}
+
+func newBalancer(strategy, hashHeader string, targets []*Target) Balancer {
+	switch strategy {
+	case StrategyWeightedRoundRobin:
+		return &weightedRoundRobin{targets: targets}
+	case StrategyLeastConnections:
+		return &leastConnections{targets: targets}
+	case StrategyConsistentHash:
+		return newConsistentHash(targets, hashHeader)
+	default:
+		return &roundRobin{targets: targets}
+	}
+}
+
+type roundRobin struct {
+	targets []*Target
+	next    atomic.Uint64
+}
+
+func (b *roundRobin) Pick(_ *http.Request, skip func(*Target) bool) *Target {
+	if len(b.targets) == 0 {
+		return nil
+	}
+	n := b.next.Add(1) - 1
+
+	// Pula os targets fora do ar, dando no máximo uma volta no pool
+	for i := range b.targets {
+		t := b.targets[(n+uint64(i))%uint64(len(b.targets))]
+		if usable(t, skip) {
+			return t
+		}
+	}
+	return nil
+}
+
+// weightedRoundRobin usa o algoritmo "smooth" do nginx, que intercala os targets
+// em vez de mandar rajadas seguidas para o de maior peso
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	targets []*Target
+	current []int
+}
+
+func (b *weightedRoundRobin) Pick(_ *http.Request, skip func(*Target) bool) *Target {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.targets) == 0 {
+		return nil
+	}
+	if b.current == nil {
+		b.current = make([]int, len(b.targets))
+	}
+
+	total, best := 0, -1
+	for i, t := range b.targets {
+		if !usable(t, skip) {
+			continue
+		}
+		b.current[i] += t.Weight
+		total += t.Weight
+		if best == -1 || b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil
+	}
+	b.current[best] -= total
+
+	return b.targets[best]
+}
+
+// leastConnections escolhe o target com menos conexões em andamento, ponderado pelo peso
+type leastConnections struct {
+	targets []*Target
+	next    atomic.Uint64
+}
+
+func (b *leastConnections) Pick(_ *http.Request, skip func(*Target) bool) *Target {
+	if len(b.targets) == 0 {
+		return nil
+	}
+
+	// Começa de um offset rotativo para distribuir empates
+	start := int(b.next.Add(1) % uint64(len(b.targets)))
+
+	var best *Target
+	var bestLoad float64
+	for i := range b.targets {
+		t := b.targets[(start+i)%len(b.targets)]
+		if !usable(t, skip) {
+			continue
+		}
+		load := float64(t.ActiveConns()) / float64(t.Weight)
+		if best == nil || load < bestLoad {
+			best, bestLoad = t, load
+		}
+	}
+
+	return best
+}
+
+const virtualNodes = 100
+
+type ringNode struct {
+	hash   uint32
+	target *Target
+}
+
+// consistentHash mapeia a API Key (ou um header) para o mesmo target enquanto o pool não muda
+type consistentHash struct {
+	ring   []ringNode
+	header string
+}
+
+func newConsistentHash(targets []*Target, header string) *consistentHash {
+	b := &consistentHash{header: header}
+
+	for _, t := range targets {
+		for i := 0; i < virtualNodes*t.Weight; i++ {
+			b.ring = append(b.ring, ringNode{
+				hash:   hash32(t.URL.String() + "#" + strconv.Itoa(i)),
+				target: t,
+			})
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i].hash < b.ring[j].hash })
+
+	return b
+}
+
+func (b *consistentHash) Pick(r *http.Request, skip func(*Target) bool) *Target {
+	if len(b.ring) == 0 {
+		return nil
+	}
+
+	h := hash32(b.key(r))
+	i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= h })
+
+	// Segue o anel até o próximo target saudável, preservando o mapeamento dos demais
+	for n := 0; n < len(b.ring); n++ {
+		t := b.ring[(i+n)%len(b.ring)].target
+		if usable(t, skip) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (b *consistentHash) key(r *http.Request) string {
+	if b.header != "" {
+		return r.Header.Get(b.header)
+	}
+	if apiKey, ok := middleware.APIKeyFromContext(r.Context()); ok {
+		return strconv.FormatInt(apiKey.ID, 10)
+	}
+	return r.RemoteAddr
+}
+
+// hash32 aplica o finalizador do murmur3 sobre o fnv-64a para espalhar chaves parecidas
+func hash32(s string) uint32 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return uint32(x)
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func testTargets(weights ...int) []*Target {
+	targets := make([]*Target, len(weights))
+	for i, w := range weights {
+		u, _ := url.Parse("http://10.0.0." + string(rune('1'+i)) + ":8080")
+		targets[i] = &Target{ID: int64(i + 1), URL: u, Weight: w}
+	}
+	return targets
+}
+
+func pickCounts(b Balancer, r *http.Request, n int) map[int64]int {
+	counts := map[int64]int{}
+	for i := 0; i < n; i++ {
+		counts[b.Pick(r, nil).ID]++
+	}
+	return counts
+}
+
+func TestRoundRobin(t *testing.T) {
+	b := newBalancer(StrategyRoundRobin, "", testTargets(1, 1, 1))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := pickCounts(b, r, 9)
+	for id := int64(1); id <= 3; id++ {
+		if counts[id] != 3 {
+			t.Fatalf("expected 3 picks for target %d, got %v", id, counts)
+		}
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	b := newBalancer(StrategyWeightedRoundRobin, "", testTargets(5, 1, 1))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Smooth WRR nunca repete o mesmo target mais que o necessário: a,a,b,a,c,a,a
+	var seq []int64
+	for i := 0; i < 7; i++ {
+		seq = append(seq, b.Pick(r, nil).ID)
+	}
+	want := []int64{1, 1, 2, 1, 3, 1, 1}
+	for i := range want {
+		if seq[i] != want[i] {
+			t.Fatalf("expected sequence %v, got %v", want, seq)
+		}
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	targets := testTargets(1, 1)
+	b := newBalancer(StrategyLeastConnections, "", targets)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	release := targets[0].acquire()
+	for i := 0; i < 5; i++ {
+		if got := b.Pick(r, nil).ID; got != 2 {
+			t.Fatalf("expected idle target 2, got %d", got)
+		}
+	}
+
+	release()
+	release()
+	if targets[0].ActiveConns() != 0 {
+		t.Fatalf("expected release to be idempotent, got %d active", targets[0].ActiveConns())
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	targets := testTargets(1, 1, 1)
+
+	t.Run("api key is sticky", func(t *testing.T) {
+		b := newBalancer(StrategyConsistentHash, "", targets)
+
+		seen := map[int64]bool{}
+		for id := int64(1); id <= 50; id++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(middleware.SetAPIKey(r.Context(), &middleware.APIKey{ID: id}))
+
+			first := b.Pick(r, nil).ID
+			for i := 0; i < 5; i++ {
+				if got := b.Pick(r, nil).ID; got != first {
+					t.Fatalf("api key %d moved from target %d to %d", id, first, got)
+				}
+			}
+			seen[first] = true
+		}
+
+		if len(seen) != len(targets) {
+			t.Fatalf("expected keys spread over all targets, got %v", seen)
+		}
+	})
+
+	t.Run("header is used when configured", func(t *testing.T) {
+		b := newBalancer(StrategyConsistentHash, "X-Tenant", targets)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Tenant", "acme")
+		first := b.Pick(r, nil).ID
+
+		r2 := httptest.NewRequest(http.MethodGet, "/", nil)
+		r2.Header.Set("X-Tenant", "acme")
+		r2 = r2.WithContext(middleware.SetAPIKey(r2.Context(), &middleware.APIKey{ID: 99}))
+		if got := b.Pick(r2, nil).ID; got != first {
+			t.Fatalf("expected header to drive selection, got %d and %d", first, got)
+		}
+	})
+}
+
+func TestPoolStoreResolve(t *testing.T) {
+	store := NewPoolStore(nil)
+	store.SetPools([]*Pool{NewPool(1, "search", StrategyRoundRobin, "", testTargets(1, 1))})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	a, err := store.Resolve("pool://search", r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := store.Resolve("pool://search", r, nil)
+	if a.ID == b.ID {
+		t.Fatal("expected round robin between pool targets")
+	}
+
+	if _, err := store.Resolve("pool://missing", r, nil); err != ErrPoolNotFound {
+		t.Fatalf("expected ErrPoolNotFound, got %v", err)
+	}
+
+	single, err := store.Resolve("billing:8080", r, nil)
+	if err != nil || single.URL.Host != "billing:8080" {
+		t.Fatalf("expected single host target, got %v %v", single, err)
+	}
+}
+
+func TestPoolStoreReloadKeepsTargetState(t *testing.T) {
+	// Cada reload periódico traz targets novos do banco
+	load := func(weights ...int) []*Pool {
+		targets := testTargets(weights...)
+		for _, tg := range targets {
+			tg.Health = newTargetHealth()
+		}
+		return []*Pool{NewPool(1, "search", StrategyLeastConnections, "", targets)}
+	}
+
+	store := NewPoolStore(nil)
+	store.replacePools(load(1, 1))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Requisição em andamento no primeiro target escolhido
+	busy, err := store.Resolve("pool://search", r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := busy.acquire()
+
+	store.replacePools(load(1, 1))
+
+	reloaded := store.Pools()[0].Targets
+	if reloaded[busy.ID-1] != busy || busy.ActiveConns() != 1 {
+		t.Fatalf("expected in-flight target to survive the reload with 1 active conn, got %d", reloaded[busy.ID-1].ActiveConns())
+	}
+	for i := 0; i < 5; i++ {
+		if got, _ := store.Resolve("pool://search", r, nil); got == busy {
+			t.Fatal("expected least_connections to avoid the busy target after reload")
+		}
+	}
+	release()
+	if busy.ActiveConns() != 0 {
+		t.Fatalf("expected release to reach the reloaded target, got %d", busy.ActiveConns())
+	}
+
+	// Peso alterado troca o target
+	store.replacePools(load(1, 5))
+	if changed := store.Pools()[0].Targets[1]; changed.Weight != 5 || changed == reloaded[1] {
+		t.Fatal("expected target with a new weight to be replaced")
+	}
+}
@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/metrics"
+)
+
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerConfig define quando o circuito abre e por quanto tempo fica aberto
+type BreakerConfig struct {
+	ConsecutiveFailures int           // falhas seguidas que abrem o circuito
+	ErrorRate           float64       // taxa de erro na janela que abre o circuito
+	MinRequests         int           // volume mínimo na janela para avaliar a taxa
+	Window              time.Duration // janela de contagem da taxa de erro
+	Cooldown            time.Duration // tempo aberto antes de testar em half-open
+	HalfOpenRequests    int           // requisições de teste simultâneas em half-open
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.ErrorRate <= 0 || c.ErrorRate > 1 {
+		c.ErrorRate = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	return c
+}
+
+// Breaker é o circuit breaker de um upstream
+type Breaker struct {
+	name string
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu          sync.Mutex
+	state       BreakerState
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int
+	generation  uint64 // muda a cada transição; resultados de outra geração são descartados
+
+	transitions *Breakers
+}
+
+// Allow reserva a passagem da requisição; done deve ser chamado com o resultado
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.Cooldown {
+			return nil, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen, now)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	// A requisição só conta para o estado em que foi admitida
+	generation := b.generation
+	var once atomic.Bool
+	return func(failed bool) {
+		if once.CompareAndSwap(false, true) {
+			b.record(generation, failed)
+		}
+	}, nil
+}
+
+// Available informa, sem reservar a passagem, se Allow aceitaria a requisição agora
+func (b *Breaker) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) >= b.cfg.Cooldown
+	case BreakerHalfOpen:
+		return b.probes < b.cfg.HalfOpenRequests
+	}
+	return true
+}
+
+// RetryAfter é quanto falta para o circuito aceitar requisições de teste
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+	if d := b.cfg.Cooldown - b.now().Sub(b.openedAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Liberada antes da última transição (ex.: fechado → aberto → half-open): não é
+	// uma requisição de teste nem pertence à janela atual
+	if generation != b.generation {
+		return
+	}
+
+	now := b.now()
+
+	if b.state == BreakerHalfOpen {
+		b.probes--
+		if failed {
+			b.setState(BreakerOpen, now)
+		} else {
+			b.setState(BreakerClosed, now)
+		}
+		return
+	}
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+
+	if !failed {
+		b.consecutive = 0
+		return
+	}
+	b.failures++
+	b.consecutive++
+
+	rateTripped := b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.ErrorRate
+	if b.consecutive >= b.cfg.ConsecutiveFailures || rateTripped {
+		b.setState(BreakerOpen, now)
+	}
+}
+
+// setState troca o estado zerando as contagens; chamado com o lock
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	from := b.state
+	b.state = state
+	b.consecutive, b.requests, b.failures, b.probes = 0, 0, 0, 0
+	b.windowStart = now
+	b.generation++
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+
+	b.transitions.observe(b.name, from, state)
+}
+
+// Breakers mantém um circuit breaker por upstream (host do target)
+type Breakers struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+
+	// OnStateChange é chamado a cada transição, além do log e da métrica
+	OnStateChange func(upstream string, from, to BreakerState)
+
+	opened atomic.Int64
+}
+
+func NewBreakers(cfg BreakerConfig) *Breakers {
+	return &Breakers{
+		cfg:      cfg.withDefaults(),
+		now:      time.Now,
+		breakers: map[string]*Breaker{},
+	}
+}
+
+// For devolve o breaker do upstream, criando-o no primeiro uso
+func (s *Breakers) For(upstream string) *Breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[upstream]
+	if !ok {
+		b = &Breaker{name: upstream, cfg: s.cfg, now: s.now, windowStart: s.now(), transitions: s}
+		s.breakers[upstream] = b
+	}
+	return b
+}
+
+// SetConfig troca os limites de todos os breakers sem perder o estado atual
+func (s *Breakers) SetConfig(cfg BreakerConfig) {
+	cfg = cfg.withDefaults()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	for _, b := range s.breakers {
+		b.mu.Lock()
+		b.cfg = cfg
+		b.mu.Unlock()
+	}
+}
+
+// BreakerStatus é uma fotografia de um breaker para a API admin
+type BreakerStatus struct {
+	Upstream   string
+	State      BreakerState
+	RetryAfter time.Duration
+}
+
+func (s *Breakers) Status() []BreakerStatus {
+	s.mu.Lock()
+	breakers := make([]*Breaker, 0, len(s.breakers))
+	for _, b := range s.breakers {
+		breakers = append(breakers, b)
+	}
+	s.mu.Unlock()
+
+	out := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		out = append(out, BreakerStatus{Upstream: b.name, State: b.State(), RetryAfter: b.RetryAfter()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Upstream < out[j].Upstream })
+	return out
+}
+
+// OpenedTotal conta quantas vezes algum circuito abriu desde o boot
+func (s *Breakers) OpenedTotal() int64 {
+	return s.opened.Load()
+}
+
+func (s *Breakers) observe(upstream string, from, to BreakerState) {
+	if from == to {
+		return
+	}
+	if to == BreakerOpen {
+		s.opened.Add(1)
+		slog.Warn("circuit breaker opened", "upstream", upstream, "from", from.String())
+	} else {
+		slog.Info("circuit breaker state changed", "upstream", upstream, "from", from.String(), "to", to.String())
+	}
+
+	metrics.BreakerTransitions.WithLabelValues(metrics.UpstreamLabels.Value(upstream), to.String()).Inc()
+
+	if s.OnStateChange != nil {
+		s.OnStateChange(upstream, from, to)
+	}
+}
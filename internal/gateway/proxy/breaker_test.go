@@ -0,0 +1,258 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func testBreakers(cfg BreakerConfig) (*Breakers, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewBreakers(cfg)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	s, now := testBreakers(BreakerConfig{ConsecutiveFailures: 3, Cooldown: 10 * time.Second})
+	b := s.For("billing:8080")
+
+	for i := 0; i < 3; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("request %d should pass while closed: %v", i, err)
+		}
+		done(true)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open after 3 failures, got %s", b.State())
+	}
+	if _, err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// Após o cooldown, uma única requisição de teste passa
+	*now = now.Add(10 * time.Second)
+	done, err := b.Allow()
+	if err != nil || b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open probe, got state %s err %v", b.State(), err)
+	}
+	if _, err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatal("expected concurrent probe to be rejected in half-open")
+	}
+
+	done(true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed probe should reopen, got %s", b.State())
+	}
+
+	*now = now.Add(10 * time.Second)
+	done, _ = b.Allow()
+	done(false)
+	if b.State() != BreakerClosed {
+		t.Fatalf("successful probe should close, got %s", b.State())
+	}
+	if s.OpenedTotal() != 2 {
+		t.Fatalf("expected 2 openings, got %d", s.OpenedTotal())
+	}
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	s, _ := testBreakers(BreakerConfig{ConsecutiveFailures: 100, ErrorRate: 0.5, MinRequests: 10})
+	b := s.For("search:8080")
+
+	var transitions []BreakerState
+	s.OnStateChange = func(_ string, _, to BreakerState) { transitions = append(transitions, to) }
+
+	// Alterna sucesso e falha: nunca há falhas seguidas, mas a taxa chega a 50%
+	for i := 0; i < 10; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("request %d rejected early", i)
+		}
+		done(i%2 == 1)
+	}
+
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open by error rate, got %s", b.State())
+	}
+	if len(transitions) != 1 || transitions[0] != BreakerOpen {
+		t.Fatalf("expected a single transition to open, got %v", transitions)
+	}
+}
+
+// Resposta de uma requisição liberada com o circuito fechado não conta como
+// requisição de teste do half-open
+func TestBreakerIgnoresStaleCompletions(t *testing.T) {
+	s, now := testBreakers(BreakerConfig{ConsecutiveFailures: 2, Cooldown: 10 * time.Second})
+	b := s.For("billing:8080")
+
+	stale, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		done, _ := b.Allow()
+		done(true)
+	}
+
+	*now = now.Add(10 * time.Second)
+	probe, err := b.Allow()
+	if err != nil || b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open probe, got state %s err %v", b.State(), err)
+	}
+
+	stale(false)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("request from before the outage must not close the circuit, got %s", b.State())
+	}
+	if _, err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("stale completion must not free a probe slot, got %v", err)
+	}
+
+	probe(true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("failed probe should reopen, got %s", b.State())
+	}
+}
+
+func TestProxyCircuitOpenFastFails(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	breakers := NewBreakers(BreakerConfig{ConsecutiveFailures: 2, Cooldown: time.Minute})
+	prx := NewDynamicProxy(nil, breakers, nil, TransportConfig{ResponseHeaderTimeout: time.Second})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := middleware.SetAPIKey(r.Context(), &middleware.APIKey{ID: 1, UpstreamHost: upstream.URL})
+		prx.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/proxy/x", nil))
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("expected upstream 500 to pass through, got %d", rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/proxy/x", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with open circuit, got %d", rr.Code)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected upstream to be spared, got %d hits", hits.Load())
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || body["error"] != "circuit_open" {
+		t.Fatalf("expected circuit_open json error, got %v (%v)", body, err)
+	}
+}
+
+// No pool, o balancer pula o target com circuito aberto em vez de responder 503
+func TestProxyPoolSkipsOpenCircuit(t *testing.T) {
+	var hits atomic.Int64
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("target with an open circuit received a request")
+	}))
+	defer broken.Close()
+
+	healthyURL, _ := url.Parse(healthy.URL)
+	brokenURL, _ := url.Parse(broken.URL)
+
+	breakers := NewBreakers(BreakerConfig{ConsecutiveFailures: 1, Cooldown: time.Minute})
+	done, _ := breakers.For(brokenURL.Host).Allow()
+	done(true)
+
+	pools := NewPoolStore(nil)
+	pools.SetPools([]*Pool{NewPool(1, "api", StrategyRoundRobin, "", []*Target{
+		{ID: 1, URL: brokenURL, Weight: 1},
+		{ID: 2, URL: healthyURL, Weight: 1},
+	})})
+	prx := NewDynamicProxy(pools, breakers, nil, TransportConfig{})
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/x", nil)
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: "pool://api"}))
+		rec := httptest.NewRecorder()
+		prx.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 from the healthy target, got %d %q", i, rec.Code, rec.Body.String())
+		}
+	}
+	if hits.Load() != 10 {
+		t.Fatalf("expected every request on the healthy target, got %d", hits.Load())
+	}
+}
+
+// Sem target escolhido a requisição nunca pode seguir para a URL absoluta do cliente
+func TestProxyNeverForwardsToClientURL(t *testing.T) {
+	var internalHits atomic.Int64
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits.Add(1)
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	breakers := NewBreakers(BreakerConfig{ConsecutiveFailures: 1, Cooldown: time.Minute})
+	pools := NewPoolStore(nil)
+	pools.SetPools([]*Pool{NewPool(1, "empty", StrategyRoundRobin, "", nil)})
+	prx := NewDynamicProxy(pools, breakers, nil, TransportConfig{ResponseHeaderTimeout: time.Second})
+
+	serve := func(upstream string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, internal.URL+"/admin/secret", nil)
+		ctx := middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: upstream})
+		prx.ServeHTTP(rr, req.WithContext(ctx))
+		return rr.Code
+	}
+
+	// Abre o circuito do upstream
+	if code := serve(failing.URL); code != http.StatusInternalServerError {
+		t.Fatalf("expected upstream 500, got %d", code)
+	}
+
+	cases := []struct {
+		name     string
+		upstream string
+		status   int
+	}{
+		{"circuit open", failing.URL, http.StatusServiceUnavailable},
+		{"empty pool", "pool://empty", http.StatusServiceUnavailable},
+		{"unknown pool", "pool://missing", http.StatusServiceUnavailable},
+		{"no upstream", "", http.StatusBadGateway},
+	}
+	for _, c := range cases {
+		if code := serve(c.upstream); code != c.status {
+			t.Errorf("%s: expected %d, got %d", c.name, c.status, code)
+		}
+	}
+	if internalHits.Load() != 0 {
+		t.Fatalf("expected no request to reach the client supplied host, got %d", internalHits.Load())
+	}
+}
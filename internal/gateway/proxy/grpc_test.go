@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+func h2cProtocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
+
+func TestGRPCOverH2C(t *testing.T) {
+	// Upstream gRPC simulado: exige HTTP/2 e responde o status nos trailers
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Te") != "trailers" {
+			http.Error(w, "expected HTTP/2 with te: trailers", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		_, _ = w.Write(body)
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "order not found")
+	}))
+	upstream.Config.Protocols = h2cProtocols()
+	upstream.Start()
+	defer upstream.Close()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	publisher, err := middleware.NewUsagePublisher(client, "usage-grpc", filepath.Join(t.TempDir(), "spool.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.Start()
+
+	prx := NewDynamicProxy(nil, nil, nil, TransportConfig{})
+	handler := middleware.Chain(HandleProxy(prx),
+		middleware.RequestID(),
+		middleware.GRPC,
+		middleware.Metrics,
+		// A API Key chega como metadata x-api-key, que no HTTP/2 é um header comum
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-API-Key") != "grpc-key" {
+					http.Error(w, "invalid api key", http.StatusUnauthorized)
+					return
+				}
+				ctx := middleware.SetAPIKey(r.Context(), &middleware.APIKey{ID: 9, UpstreamHost: upstream.URL})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		},
+		middleware.Logger,
+		middleware.PublishUsage(publisher),
+	)
+
+	gw := httptest.NewUnstartedServer(handler)
+	gw.Config.Protocols = h2cProtocols()
+	gw.Start()
+	defer gw.Close()
+
+	h2c := &http.Transport{Protocols: new(http.Protocols)}
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+	grpcClient := &http.Client{Transport: h2c}
+
+	call := func(apiKey string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, gw.URL+"/proxy/orders.v1.Orders/Get", strings.NewReader("\x00\x00\x00\x00\x02hi"))
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("Te", "trailers")
+		req.Header.Set("X-API-Key", apiKey)
+		res, err := grpcClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := call("grpc-key")
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.ProtoMajor != 2 || res.StatusCode != http.StatusOK || string(body) != "\x00\x00\x00\x00\x02hi" {
+		t.Fatalf("expected the upstream reply over HTTP/2, got %s %d %q", res.Proto, res.StatusCode, body)
+	}
+	if got := res.Trailer.Get("Grpc-Status"); got != "5" {
+		t.Fatalf("expected grpc-status 5 in the trailers, got %q", got)
+	}
+
+	// Rejeição do gateway vira Trailers-Only com o código gRPC
+	res = call("wrong")
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Grpc-Status") != "16" || res.Header.Get("Grpc-Message") != "invalid api key" {
+		t.Fatalf("expected UNAUTHENTICATED, got %d %v", res.StatusCode, res.Header)
+	}
+
+	gw.Close()
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := client.XRange(context.Background(), "usage-grpc", "-", "+").Result()
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected one usage event, got %d (%v)", len(msgs), err)
+	}
+	var event middleware.UsageEvent
+	if err := json.Unmarshal([]byte(msgs[0].Values["payload"].(string)), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.GRPCMethod != "/orders.v1.Orders/Get" || event.GRPCStatus != "5" {
+		t.Fatalf("expected gRPC method and status in the usage event, got %+v", event)
+	}
+}
@@ -1,52 +1,407 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/martinsdevv/aegis/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewDynamicProxy() *httputil.ReverseProxy {
+type ctxKeyUpstream struct{}
+
+// upstreamState acompanha o target escolhido no Director até o fim da resposta
+type upstreamState struct {
+	upstream  string
+	target    *Target
+	release   func()
+	done      func(failed bool)
+	breaker   *Breaker
+	span      trace.Span
+	transport TransportConfig
+	cancel    context.CancelFunc
+	err       error
+
+	// pick escolhe o target de uma nova tentativa; nil quando não há upstream
+	pick    func(r *http.Request) error
+	retries int
+}
+
+// upstreamAttempt guarda a reserva de target e breaker de uma tentativa
+type upstreamAttempt struct {
+	release func()
+	done    func(failed bool)
+}
+
+func (st *upstreamState) attempt() upstreamAttempt {
+	return upstreamAttempt{release: st.release, done: st.done}
+}
+
+// end libera o target e informa o resultado ao circuit breaker
+func (a upstreamAttempt) end(failed bool) {
+	if a.release != nil {
+		a.release()
+	}
+	if a.done != nil {
+		a.done(failed)
+	}
+}
+
+// finish encerra a última tentativa e fecha o span do upstream
+func (st *upstreamState) finish(failed bool) {
+	st.attempt().end(failed)
+	if st.span != nil {
+		if st.retries > 0 {
+			st.span.SetAttributes(attribute.Int("aegis.retries", st.retries))
+		}
+		if failed {
+			st.span.SetStatus(codes.Error, "upstream failed")
+		}
+		st.span.End()
+	}
+	if st.cancel != nil {
+		st.cancel()
+	}
+}
+
+// timeout devolve o prazo configurado da fase que expirou
+func (st *upstreamState) timeout(phase string) time.Duration {
+	if st == nil {
+		return 0
+	}
+	switch phase {
+	case "connect":
+		return st.transport.ConnectTimeout
+	case "tls_handshake":
+		return st.transport.TLSHandshakeTimeout
+	case "response_header":
+		return st.transport.ResponseHeaderTimeout
+	default:
+		return st.transport.RequestTimeout
+	}
+}
+
+func upstreamStateFromContext(ctx context.Context) *upstreamState {
+	st, _ := ctx.Value(ctxKeyUpstream{}).(*upstreamState)
+	return st
+}
+
+// ErrNoUpstream indica requisição sem rota nem upstream na API Key
+var ErrNoUpstream = errors.New("no upstream configured")
+
+// NewDynamicProxy monta o proxy; breakers nil desativa o circuit breaker,
+// retries nil desativa as novas tentativas e defaults são os timeouts e limites
+// de conexão usados quando pool e rota não definem os seus
+func NewDynamicProxy(pools *PoolStore, breakers *Breakers, retries *Retries, defaults TransportConfig) *httputil.ReverseProxy {
+	return NewProxy(pools, breakers, NewTransports(defaults, retries))
+}
+
+// NewProxy monta o proxy sobre transports compartilhados, que mantêm as
+// conexões com os upstreams entre os reloads da configuração
+func NewProxy(pools *PoolStore, breakers *Breakers, transports *Transports) *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{
+		Transport: transports,
 		Director: func(r *http.Request) {
+			defaults := transports.Defaults()
+			st := &upstreamState{transport: defaults}
+			*r = *r.WithContext(context.WithValue(r.Context(), ctxKeyUpstream{}, st))
 
-			apiKey, ok := middleware.APIKeyFromContext(r.Context())
-			if !ok || apiKey.UpstreamHost == "" {
+			// Rota declarativa tem precedência sobre o upstream da API Key
+			upstream := ""
+			route, isRoute := RouteFromContext(r.Context())
+			if isRoute {
+				upstream = route.Upstream
+			} else if apiKey, ok := middleware.APIKeyFromContext(r.Context()); ok {
+				upstream = apiKey.UpstreamHost
+			}
+			if upstream == "" {
+				st.err = ErrNoUpstream
 				return
 			}
+			st.upstream = upstream
 
-			target := apiKey.UpstreamHost
+			if info := middleware.RequestInfoFromContext(r.Context()); info != nil {
+				info.Upstream = upstream
+				if isRoute {
+					info.Route = route.Name
+				} else {
+					info.Route = "proxy"
+				}
+			}
 
-			if !strings.Contains(target, "://") {
-				target = "http://" + target
+			// Span de cliente cobre do balanceamento até o fim do corpo da resposta
+			ctx, span := tracing.Start(r.Context(), "proxy.upstream",
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("aegis.upstream", upstream)),
+			)
+			st.span = span
+			*r = *r.WithContext(ctx)
+
+			// Rota sobrescreve o pool, que sobrescreve os padrões
+			st.transport = defaults.Merge(pools.TransportFor(upstream))
+			if isRoute {
+				st.transport = st.transport.Merge(route.Transport)
+			}
+
+			// No pool, targets com o circuito aberto ficam de fora do balanceamento
+			var skipOpen func(*Target) bool
+			if breakers != nil {
+				skipOpen = func(t *Target) bool { return !breakers.For(t.URL.Host).Available() }
 			}
 
-			u, err := url.Parse(target)
-			if err != nil {
+			st.pick = func(r *http.Request) error {
+				t, err := pools.Resolve(upstream, r, skipOpen)
+				if errors.Is(err, ErrNoTarget) && skipOpen != nil {
+					// Todos os circuitos abertos: escolhe um deles para responder 503 com Retry-After
+					t, err = pools.Resolve(upstream, r, nil)
+				}
+				if err != nil {
+					return err
+				}
+
+				// Circuito aberto falha rápido, sem ocupar conexão com o upstream
+				var breaker *Breaker
+				var done func(failed bool)
+				if breakers != nil {
+					breaker = breakers.For(t.URL.Host)
+					if done, err = breaker.Allow(); err != nil {
+						// Só a primeira tentativa responde 503 com o target do circuito aberto
+						if st.target == nil {
+							st.target, st.breaker = t, breaker
+						}
+						return err
+					}
+				}
+
+				st.target, st.breaker, st.done = t, breaker, done
+				st.release = t.acquire()
+				span.SetAttributes(semconv.ServerAddress(t.URL.Host))
+
+				r.URL.Scheme = t.URL.Scheme
+				r.URL.Host = t.URL.Host
+				r.Host = t.URL.Host
+				return nil
+			}
+			if err := st.pick(r); err != nil {
+				st.err = err
+				st.pick = nil
+				span.RecordError(err)
 				return
 			}
+			t := st.target
 
-			r.URL.Scheme = u.Scheme
-			r.URL.Host = u.Host
-			r.Host = u.Host
+			// O prazo total cobre também a leitura do corpo e as novas tentativas; o cancel vem no finish.
+			// Upgrade e SSE duram o quanto o cliente quiser e cancelar o contexto derrubaria a conexão
+			if d := st.transport.RequestTimeout; d > 0 && !middleware.IsUpgrade(r) && !middleware.AcceptsEventStream(r) {
+				ctx, st.cancel = context.WithTimeoutCause(ctx, d, errRequestTimeout)
+				*r = *r.WithContext(ctx)
+			}
 
-			if r.URL.Path == "/proxy" {
+			if isRoute {
+				r.URL.Path = route.RewritePath(r.URL.Path)
+				r.URL.RawPath = ""
+			} else if r.URL.Path == "/proxy" {
 				r.URL.Path = "/"
 			} else if strings.HasPrefix(r.URL.Path, "/proxy/") {
 				r.URL.Path = strings.TrimPrefix(r.URL.Path, "/proxy")
 			}
 
-			ctx := middleware.SetUpstreamHost(r.Context(), u.Host)
+			ctx = middleware.SetUpstreamHost(r.Context(), t.URL.Host)
 			*r = *r.WithContext(ctx)
+
+			// traceparent/tracestate do span do upstream substituem os recebidos
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			st := upstreamStateFromContext(resp.Request.Context())
+			if st == nil {
+				return nil
+			}
+
+			// 5xx do upstream conta como falha para o circuit breaker
+			failed := resp.StatusCode >= http.StatusInternalServerError
+			if st.span != nil {
+				st.span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			}
+
+			// Num Upgrade o corpo é a própria conexão com o upstream e precisa continuar gravável
+			if conn, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+				resp.Body = &releaseConn{ReadWriteCloser: conn, release: func() { st.finish(false) }}
+				return nil
+			}
+
+			// A conexão só termina quando o corpo for totalmente repassado
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { st.finish(failed) }}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			st := upstreamStateFromContext(r.Context())
+			if st != nil {
+				if st.span != nil && st.err == nil {
+					st.span.RecordError(err)
+				}
+				// Cliente que desistiu não é falha do upstream
+				st.finish(!errors.Is(err, context.Canceled))
+			}
+
+			upstreamLabel := metrics.LabelNone
+			if info := middleware.RequestInfoFromContext(r.Context()); info != nil {
+				upstreamLabel = metrics.UpstreamLabels.Value(info.Upstream)
+			}
+
+			if st != nil && errors.Is(st.err, ErrCircuitOpen) {
+				metrics.UpstreamErrors.WithLabelValues(upstreamLabel, "circuit_open").Inc()
+				writeCircuitOpen(w, st.target.URL.Host, st.breaker.RetryAfter())
+				return
+			}
+
+			if st != nil && st.err != nil {
+				slog.Error("upstream resolution failed", "path", r.URL.Path, "err", st.err)
+				status, reason := http.StatusBadGateway, "resolution"
+				if errors.Is(st.err, ErrNoTarget) || errors.Is(st.err, ErrPoolNotFound) {
+					status, reason = http.StatusServiceUnavailable, "no_target"
+				}
+				metrics.UpstreamErrors.WithLabelValues(upstreamLabel, reason).Inc()
+				http.Error(w, st.err.Error(), status)
+				return
+			}
+
+			// Com novas tentativas o target final pode não ser o da requisição original
+			host := r.URL.Host
+			if st != nil && st.target != nil {
+				host = st.target.URL.Host
+			}
+
+			reason := metrics.UpstreamErrorReason(err)
+			if errors.Is(context.Cause(r.Context()), errRequestTimeout) {
+				reason = "timeout"
+			}
+			metrics.UpstreamErrors.WithLabelValues(upstreamLabel, reason).Inc()
+			slog.Error("proxy error", "host", host, "path", r.URL.Path, "err", err)
+
+			if reason == "timeout" {
+				phase := timeoutPhase(r.Context(), err)
+				writeUpstreamTimeout(w, host, phase, st.timeout(phase))
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
 		},
 	}
 }
 
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// releaseConn encerra a tentativa quando a conexão do Upgrade fecha; o
+// ReverseProxy pode fechá-la mais de uma vez
+type releaseConn struct {
+	io.ReadWriteCloser
+	release func()
+	once    sync.Once
+}
+
+func (c *releaseConn) Close() error {
+	err := c.ReadWriteCloser.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func writeCircuitOpen(w http.ResponseWriter, upstream string, retryAfter time.Duration) {
+	secs := int((retryAfter + time.Second - 1) / time.Second)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":       "circuit_open",
+		"message":     "upstream temporarily unavailable",
+		"upstream":    upstream,
+		"retry_after": secs,
+	})
+}
+
+// writeUpstreamTimeout responde 504 indicando qual prazo expirou
+func writeUpstreamTimeout(w http.ResponseWriter, upstream, phase string, timeout time.Duration) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error":      "upstream_timeout",
+		"message":    "upstream did not respond in time",
+		"upstream":   upstream,
+		"timeout":    phase,
+		"timeout_ms": timeout.Milliseconds(),
+	})
+}
+
 func HandleProxy(proxy *httputil.ReverseProxy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		proxy.ServeHTTP(w, r)
 	}
 }
+
+// HandleRoutes encaminha pela tabela de rotas, exigindo que a API Key tenha acesso à rota
+func HandleRoutes(store *RouteStore, proxy *httputil.ReverseProxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		table := store.Table()
+
+		route, ok := table.Match(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		apiKey, ok := middleware.APIKeyFromContext(r.Context())
+		if !ok {
+			http.Error(w, "missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		if !table.Allowed(route.ID, apiKey.ID) {
+			http.Error(w, "route not allowed for api key", http.StatusForbidden)
+			return
+		}
+
+		// Headers de claims nunca vêm do cliente: sem JWT (ou sem a claim) são removidos
+		claims, _ := middleware.ClaimsFromContext(r.Context())
+		for header, claim := range route.ClaimHeaders {
+			r.Header.Del(header)
+			if v := claims.String(claim); v != "" {
+				r.Header.Set(header, v)
+			}
+		}
+
+		proxy.ServeHTTP(w, r.WithContext(SetRoute(r.Context(), route)))
+	}
+}
+
+func parseUpstream(target string) (*url.URL, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	return url.Parse(target)
+}
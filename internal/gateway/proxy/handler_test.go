@@ -8,6 +8,8 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
 )
 
 type Req struct {
@@ -63,9 +65,15 @@ func TestProxyHandler(t *testing.T) {
 	upstreamSrv := httptest.NewServer(upstreamMux)
 	defer upstreamSrv.Close()
 
-	prx := NewDynamicProxy()
+	prx := NewDynamicProxy(nil, nil, nil, TransportConfig{})
+
+	// O upstream vem da API Key autenticada pelo middleware
+	apiKey := &middleware.APIKey{ID: 1, UpstreamHost: upstreamSrv.URL}
+	withKey := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleProxy(prx)(w, r.WithContext(middleware.SetAPIKey(r.Context(), apiKey)))
+	})
 
-	proxySrv := httptest.NewServer(HandleProxy(prx))
+	proxySrv := httptest.NewServer(withKey)
 	defer proxySrv.Close()
 
 	client := proxySrv.Client()
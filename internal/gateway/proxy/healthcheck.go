@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheckConfig define o probe ativo dos targets de um pool
+type HealthCheckConfig struct {
+	Path               string // vazio desativa
+	Interval           time.Duration
+	Timeout            time.Duration
+	ExpectedStatus     int
+	HealthyThreshold   int // sucessos seguidos para voltar à seleção
+	UnhealthyThreshold int // falhas seguidas para sair da seleção
+}
+
+func (c HealthCheckConfig) Enabled() bool {
+	return c.Path != ""
+}
+
+// withDefaults preenche os campos zerados com os mesmos defaults da migration
+func (c HealthCheckConfig) withDefaults() HealthCheckConfig {
+	if c.Interval < time.Second {
+		c.Interval = 10 * time.Second
+	}
+	if c.Timeout < time.Second {
+		c.Timeout = 2 * time.Second
+	}
+	if c.ExpectedStatus == 0 {
+		c.ExpectedStatus = http.StatusOK
+	}
+	if c.HealthyThreshold <= 0 {
+		c.HealthyThreshold = 2
+	}
+	if c.UnhealthyThreshold <= 0 {
+		c.UnhealthyThreshold = 3
+	}
+	return c
+}
+
+// TargetHealth é o estado do health check de um target; sobrevive aos reloads do pool
+type TargetHealth struct {
+	healthy atomic.Bool
+
+	mu        sync.Mutex
+	successes int
+	failures  int
+	probing   bool
+	nextCheck time.Time
+	lastCheck time.Time
+	lastErr   string
+}
+
+// TargetHealthStatus é uma fotografia do estado para a API admin
+type TargetHealthStatus struct {
+	Healthy              bool
+	ConsecutiveSuccesses int
+	ConsecutiveFailures  int
+	LastCheck            time.Time
+	LastError            string
+}
+
+func newTargetHealth() *TargetHealth {
+	h := &TargetHealth{}
+	h.healthy.Store(true)
+	return h
+}
+
+func (h *TargetHealth) Healthy() bool {
+	return h.healthy.Load()
+}
+
+func (h *TargetHealth) Status() TargetHealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return TargetHealthStatus{
+		Healthy:              h.healthy.Load(),
+		ConsecutiveSuccesses: h.successes,
+		ConsecutiveFailures:  h.failures,
+		LastCheck:            h.lastCheck,
+		LastError:            h.lastErr,
+	}
+}
+
+// reset volta ao estado inicial, disponível
+func (h *TargetHealth) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.healthy.Store(true)
+	h.successes, h.failures = 0, 0
+	h.lastCheck, h.lastErr = time.Time{}, ""
+}
+
+// claim reserva o próximo probe se ele estiver vencido
+func (h *TargetHealth) claim(now time.Time, interval time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.probing || now.Before(h.nextCheck) {
+		return false
+	}
+	h.probing = true
+	h.nextCheck = now.Add(interval)
+	return true
+}
+
+// record aplica o resultado do probe e informa se o target mudou de estado
+func (h *TargetHealth) record(cfg HealthCheckConfig, err error) (changed, healthy bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.probing = false
+	h.lastCheck = time.Now()
+	healthy = h.healthy.Load()
+
+	if err == nil {
+		h.successes++
+		h.failures = 0
+		h.lastErr = ""
+		if !healthy && h.successes >= cfg.HealthyThreshold {
+			h.healthy.Store(true)
+			return true, true
+		}
+		return false, healthy
+	}
+
+	h.failures++
+	h.successes = 0
+	h.lastErr = err.Error()
+	if healthy && h.failures >= cfg.UnhealthyThreshold {
+		h.healthy.Store(false)
+		return true, false
+	}
+	return false, healthy
+}
+
+// HealthChecker faz probes periódicos nos targets dos pools com health check ativo
+type HealthChecker struct {
+	pools  *PoolStore
+	client *http.Client
+}
+
+func NewHealthChecker(pools *PoolStore) *HealthChecker {
+	return &HealthChecker{
+		pools: pools,
+		client: &http.Client{
+			// Não seguimos redirects: o status esperado é o do próprio target
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run verifica a cada segundo quais probes estão vencidos até o contexto ser cancelado
+func (c *HealthChecker) Run(ctx context.Context) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+
+	for {
+		c.tick(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (c *HealthChecker) tick(ctx context.Context, now time.Time) {
+	for _, p := range c.pools.Pools() {
+		cfg := p.HealthCheck
+		if !cfg.Enabled() {
+			continue
+		}
+
+		for _, t := range p.Targets {
+			if t.Health != nil && t.Health.claim(now, cfg.Interval) {
+				go c.probe(ctx, p, t)
+			}
+		}
+	}
+}
+
+func (c *HealthChecker) probe(ctx context.Context, p *Pool, t *Target) {
+	cfg := p.HealthCheck
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	err := c.check(ctx, t, cfg)
+
+	changed, healthy := t.Health.record(cfg, err)
+	if !changed {
+		return
+	}
+
+	if healthy {
+		slog.Info("upstream target healthy", "pool", p.Name, "target", t.URL.String())
+		return
+	}
+	slog.Warn("upstream target unhealthy", "pool", p.Name, "target", t.URL.String(), "err", err)
+}
+
+func (c *HealthChecker) check(ctx context.Context, t *Target, cfg HealthCheckConfig) error {
+	u := *t.URL
+	u.Path = cfg.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "aegis-health-checker")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode != cfg.ExpectedStatus {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
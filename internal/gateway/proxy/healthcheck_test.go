@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTargetHealthThresholds(t *testing.T) {
+	cfg := HealthCheckConfig{HealthyThreshold: 2, UnhealthyThreshold: 3}
+	h := newTargetHealth()
+	probeErr := errors.New("connection refused")
+
+	for i := 0; i < 2; i++ {
+		if changed, _ := h.record(cfg, probeErr); changed {
+			t.Fatalf("target should stay healthy before %d failures", cfg.UnhealthyThreshold)
+		}
+	}
+	if changed, healthy := h.record(cfg, probeErr); !changed || healthy {
+		t.Fatal("expected transition to unhealthy on the third failure")
+	}
+
+	if changed, _ := h.record(cfg, nil); changed {
+		t.Fatal("a single success should not restore the target")
+	}
+	if changed, healthy := h.record(cfg, nil); !changed || !healthy {
+		t.Fatal("expected transition to healthy on the second success")
+	}
+}
+
+func TestBalancersSkipUnhealthyTargets(t *testing.T) {
+	strategies := []string{StrategyRoundRobin, StrategyWeightedRoundRobin, StrategyLeastConnections, StrategyConsistentHash}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			targets := testTargets(1, 1, 1)
+			for _, tg := range targets {
+				tg.Health = newTargetHealth()
+			}
+			targets[0].Health.healthy.Store(false)
+			targets[2].Health.healthy.Store(false)
+
+			b := newBalancer(strategy, "", targets)
+			for i := 0; i < 10; i++ {
+				if got := b.Pick(r, nil); got == nil || got.ID != 2 {
+					t.Fatalf("expected only target 2 to be picked, got %v", got)
+				}
+			}
+
+			targets[1].Health.healthy.Store(false)
+			if got := b.Pick(r, nil); got != nil {
+				t.Fatalf("expected no target when all are unhealthy, got %d", got.ID)
+			}
+		})
+	}
+}
+
+func TestHealthCheckerProbesTargets(t *testing.T) {
+	var ready atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	target := &Target{ID: 1, URL: u, Weight: 1, Health: newTargetHealth()}
+
+	pool := NewPool(1, "search", StrategyRoundRobin, "", []*Target{target})
+	pool.HealthCheck = HealthCheckConfig{Path: "/healthz", HealthyThreshold: 1, UnhealthyThreshold: 1}.withDefaults()
+
+	store := NewPoolStore(nil)
+	store.SetPools([]*Pool{pool})
+	checker := NewHealthChecker(store)
+	ctx := context.Background()
+
+	checker.probe(ctx, pool, target)
+	if target.Healthy() {
+		t.Fatal("expected target to be unhealthy while /healthz returns 503")
+	}
+	if _, err := store.Resolve("pool://search", httptest.NewRequest(http.MethodGet, "/", nil), nil); err != ErrNoTarget {
+		t.Fatalf("expected ErrNoTarget, got %v", err)
+	}
+
+	ready.Store(true)
+	checker.probe(ctx, pool, target)
+	if !target.Healthy() {
+		t.Fatalf("expected target to recover, last error: %q", target.Health.Status().LastError)
+	}
+
+	// Um probe em andamento ou recente não é reagendado antes do intervalo
+	now := time.Now()
+	if !target.Health.claim(now, time.Minute) || target.Health.claim(now.Add(time.Second), time.Minute) {
+		t.Fatal("expected claim to respect the probe interval")
+	}
+}
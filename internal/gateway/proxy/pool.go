@@ -0,0 +1,382 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const poolScheme = "pool://"
+
+var (
+	ErrPoolNotFound   = errors.New("upstream pool not found")
+	ErrTargetNotFound = errors.New("upstream target not found")
+	ErrNoTarget       = errors.New("no upstream target available")
+)
+
+// Target é uma réplica de um upstream
+type Target struct {
+	ID     int64
+	URL    *url.URL
+	Weight int
+	Health *TargetHealth
+
+	active atomic.Int64
+}
+
+// Healthy informa se o target pode receber tráfego; sem health check ele está sempre disponível
+func (t *Target) Healthy() bool {
+	return t.Health == nil || t.Health.Healthy()
+}
+
+func (t *Target) ActiveConns() int64 {
+	return t.active.Load()
+}
+
+// acquire conta a conexão em andamento e devolve a função que a libera
+func (t *Target) acquire() func() {
+	t.active.Add(1)
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			t.active.Add(-1)
+		}
+	}
+}
+
+// Pool agrupa targets de um upstream e a estratégia de balanceamento
+type Pool struct {
+	ID          int64
+	Name        string
+	Strategy    string
+	HashHeader  string
+	HealthCheck HealthCheckConfig
+	Transport   TransportConfig
+	Targets     []*Target
+
+	balancer Balancer
+}
+
+func NewPool(id int64, name, strategy, hashHeader string, targets []*Target) *Pool {
+	return &Pool{
+		ID:         id,
+		Name:       name,
+		Strategy:   strategy,
+		HashHeader: hashHeader,
+		Targets:    targets,
+		balancer:   newBalancer(strategy, hashHeader, targets),
+	}
+}
+
+// PoolStore mantém os pools carregados do Postgres e resolve upstreams para targets
+type PoolStore struct {
+	db    *sql.DB
+	pools atomic.Pointer[map[string]*Pool]
+}
+
+func NewPoolStore(db *sql.DB) *PoolStore {
+	s := &PoolStore{db: db}
+	s.pools.Store(&map[string]*Pool{})
+	return s
+}
+
+// SetPools troca o conjunto de pools em memória
+func (s *PoolStore) SetPools(pools []*Pool) {
+	m := make(map[string]*Pool, len(pools))
+	for _, p := range pools {
+		m[p.Name] = p
+	}
+	s.pools.Store(&m)
+}
+
+func (s *PoolStore) Pools() []*Pool {
+	m := *s.pools.Load()
+	pools := make([]*Pool, 0, len(m))
+	for _, p := range m {
+		pools = append(pools, p)
+	}
+	return pools
+}
+
+// Resolve escolhe o target para o upstream: pool://<nome> passa pelo balancer,
+// que pula os targets em que skip devolve true; qualquer outro valor é tratado
+// como um host único
+func (s *PoolStore) Resolve(upstream string, r *http.Request, skip func(*Target) bool) (*Target, error) {
+	name, ok := strings.CutPrefix(upstream, poolScheme)
+	if !ok {
+		u, err := parseUpstream(upstream)
+		if err != nil {
+			return nil, err
+		}
+		return &Target{URL: u, Weight: 1}, nil
+	}
+
+	if s == nil {
+		return nil, ErrPoolNotFound
+	}
+
+	pool, ok := (*s.pools.Load())[name]
+	if !ok {
+		return nil, ErrPoolNotFound
+	}
+
+	t := pool.balancer.Pick(r, skip)
+	if t == nil {
+		return nil, ErrNoTarget
+	}
+	return t, nil
+}
+
+// TransportFor devolve a configuração de transporte do pool do upstream;
+// hosts diretos e pools desconhecidos não têm configuração própria
+func (s *PoolStore) TransportFor(upstream string) TransportConfig {
+	name, ok := strings.CutPrefix(upstream, poolScheme)
+	if !ok || s == nil {
+		return TransportConfig{}
+	}
+	if pool, ok := (*s.pools.Load())[name]; ok {
+		return pool.Transport
+	}
+	return TransportConfig{}
+}
+
+// Reload relê pools e targets e troca o snapshot atomicamente
+func (s *PoolStore) Reload(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.strategy, p.hash_header,
+		       p.health_path, p.health_interval_seconds, p.health_timeout_seconds,
+		       p.health_expected_status, p.healthy_threshold, p.unhealthy_threshold,
+		       p.connect_timeout_ms, p.tls_handshake_timeout_ms, p.response_header_timeout_ms, p.request_timeout_ms,
+		       p.max_idle_conns_per_host, p.max_conns_per_host, p.idle_conn_timeout_ms, p.keep_alive_ms,
+		       t.id, t.url, t.weight
+		FROM upstream_pools p
+		LEFT JOIN upstream_targets t ON t.pool_id = p.id
+		ORDER BY p.id, t.id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type poolRow struct {
+		id                     int64
+		name, strategy, header string
+		health                 HealthCheckConfig
+		transport              TransportConfig
+		targets                []*Target
+	}
+
+	var order []*poolRow
+	byID := map[int64]*poolRow{}
+
+	for rows.Next() {
+		var (
+			pr                poolRow
+			interval, timeout int
+			transport         transportRow
+			targetID          sql.NullInt64
+			rawURL            sql.NullString
+			weight            sql.NullInt64
+		)
+		dest := []any{
+			&pr.id, &pr.name, &pr.strategy, &pr.header,
+			&pr.health.Path, &interval, &timeout,
+			&pr.health.ExpectedStatus, &pr.health.HealthyThreshold, &pr.health.UnhealthyThreshold,
+		}
+		dest = append(dest, transport.dest()...)
+		if err := rows.Scan(append(dest, &targetID, &rawURL, &weight)...); err != nil {
+			return err
+		}
+		pr.transport = transport.config()
+		pr.health.Interval = time.Duration(interval) * time.Second
+		pr.health.Timeout = time.Duration(timeout) * time.Second
+
+		p, ok := byID[pr.id]
+		if !ok {
+			p = &pr
+			byID[pr.id] = p
+			order = append(order, p)
+		}
+
+		if !targetID.Valid {
+			continue
+		}
+		u, err := parseUpstream(rawURL.String)
+		if err != nil {
+			return err
+		}
+		p.targets = append(p.targets, &Target{ID: targetID.Int64, URL: u, Weight: int(weight.Int64)})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pools := make([]*Pool, 0, len(order))
+	for _, p := range order {
+		pool := NewPool(p.id, p.name, p.strategy, p.header, p.targets)
+		pool.HealthCheck = p.health
+		pool.Transport = p.transport
+		pools = append(pools, pool)
+	}
+	s.replacePools(pools)
+
+	return nil
+}
+
+// replacePools troca os pools pelos recarregados preservando os targets que não
+// mudaram: conexões em andamento, saúde e o estado do balanceamento sobrevivem
+// aos reloads periódicos
+func (s *PoolStore) replacePools(pools []*Pool) {
+	type targetKey struct {
+		pool, id int64
+		url      string
+		weight   int
+	}
+	previousPools := map[int64]*Pool{}
+	previous := map[targetKey]*Target{}
+	for _, p := range s.Pools() {
+		previousPools[p.ID] = p
+		for _, t := range p.Targets {
+			previous[targetKey{p.ID, t.ID, t.URL.String(), t.Weight}] = t
+		}
+	}
+
+	for _, pool := range pools {
+		for i, t := range pool.Targets {
+			old, ok := previous[targetKey{pool.ID, t.ID, t.URL.String(), t.Weight}]
+			switch {
+			case ok && old.Health != nil:
+				// Sem health check ninguém mais atualiza a saúde: volta a disponível
+				if !pool.HealthCheck.Enabled() {
+					old.Health.reset()
+				}
+				pool.Targets[i] = old
+			case t.Health == nil:
+				t.Health = newTargetHealth()
+			}
+		}
+
+		old, ok := previousPools[pool.ID]
+		if ok && old.Strategy == pool.Strategy && old.HashHeader == pool.HashHeader && slices.Equal(old.Targets, pool.Targets) {
+			pool.balancer = old.balancer
+		} else {
+			pool.balancer = newBalancer(pool.Strategy, pool.HashHeader, pool.Targets)
+		}
+	}
+	s.SetPools(pools)
+}
+
+func (s *PoolStore) CreatePool(ctx context.Context, name, strategy, hashHeader string, hc HealthCheckConfig, tc TransportConfig) (int64, error) {
+	hc = hc.withDefaults()
+
+	args := []any{
+		name, strategy, hashHeader,
+		hc.Path, int(hc.Interval / time.Second), int(hc.Timeout / time.Second),
+		hc.ExpectedStatus, hc.HealthyThreshold, hc.UnhealthyThreshold,
+	}
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO upstream_pools (
+			name, strategy, hash_header,
+			health_path, health_interval_seconds, health_timeout_seconds,
+			health_expected_status, healthy_threshold, unhealthy_threshold,
+			`+transportColumns+`
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id
+	`, append(args, transportArgs(tc)...)...).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, s.Reload(ctx)
+}
+
+// SetHealthCheck troca a configuração do health check do pool; Path vazio desativa
+func (s *PoolStore) SetHealthCheck(ctx context.Context, id int64, hc HealthCheckConfig) error {
+	hc = hc.withDefaults()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE upstream_pools
+		SET health_path = $2,
+		    health_interval_seconds = $3,
+		    health_timeout_seconds = $4,
+		    health_expected_status = $5,
+		    healthy_threshold = $6,
+		    unhealthy_threshold = $7
+		WHERE id = $1
+	`, id, hc.Path, int(hc.Interval/time.Second), int(hc.Timeout/time.Second),
+		hc.ExpectedStatus, hc.HealthyThreshold, hc.UnhealthyThreshold)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPoolNotFound
+	}
+
+	return s.Reload(ctx)
+}
+
+// SetTransport troca timeouts e limites de conexão do pool; zeros herdam os padrões do gateway
+func (s *PoolStore) SetTransport(ctx context.Context, id int64, tc TransportConfig) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE upstream_pools SET `+transportAssignments+` WHERE id = $1`,
+		append([]any{id}, transportArgs(tc)...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPoolNotFound
+	}
+
+	return s.Reload(ctx)
+}
+
+func (s *PoolStore) DeletePool(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM upstream_pools WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPoolNotFound
+	}
+
+	return s.Reload(ctx)
+}
+
+func (s *PoolStore) AddTarget(ctx context.Context, poolID int64, rawURL string, weight int) (int64, error) {
+	if weight <= 0 {
+		weight = 1
+	}
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO upstream_targets (pool_id, url, weight)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (pool_id, url) DO UPDATE SET weight = EXCLUDED.weight
+		RETURNING id
+	`, poolID, rawURL, weight).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, s.Reload(ctx)
+}
+
+func (s *PoolStore) RemoveTarget(ctx context.Context, poolID, targetID int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM upstream_targets WHERE id = $1 AND pool_id = $2`, targetID, poolID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTargetNotFound
+	}
+
+	return s.Reload(ctx)
+}
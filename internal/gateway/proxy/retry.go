@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRetryBody é o maior corpo de requisição guardado em memória para reenvio
+const maxRetryBody = 1 << 20
+
+// RetryPolicy define quando e quantas vezes uma requisição ao upstream é repetida
+type RetryPolicy struct {
+	MaxAttempts        int           // tentativas no total, incluindo a primeira; 1 desativa
+	RetryOn            []int         // status do upstream que disparam nova tentativa
+	BackoffBase        time.Duration // espera antes da primeira repetição, dobrada a cada tentativa
+	BackoffMax         time.Duration
+	BudgetRatio        float64       // fração de repetições sobre as requisições da janela
+	BudgetMinPerSecond int           // repetições sempre permitidas, para tráfego baixo
+	Window             time.Duration // janela de contagem do budget
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.BackoffBase <= 0 {
+		p.BackoffBase = 25 * time.Millisecond
+	}
+	if p.BackoffMax < p.BackoffBase {
+		p.BackoffMax = p.BackoffBase
+	}
+	if p.BudgetRatio < 0 {
+		p.BudgetRatio = 0
+	}
+	if p.BudgetMinPerSecond < 0 {
+		p.BudgetMinPerSecond = 0
+	}
+	if p.Window <= 0 {
+		p.Window = 10 * time.Second
+	}
+	return p
+}
+
+// backoff é exponencial com jitter completo: um valor aleatório entre zero e o teto da tentativa
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BackoffBase << (retry - 1)
+	if d <= 0 || d > p.BackoffMax {
+		d = p.BackoffMax
+	}
+	return rand.N(d + 1)
+}
+
+// Retries aplica a política de retry e controla o budget compartilhado por
+// todos os upstreams, para que as repetições não multipliquem uma queda
+type Retries struct {
+	now func() time.Time
+
+	mu          sync.Mutex
+	policy      RetryPolicy
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func NewRetries(policy RetryPolicy) *Retries {
+	return &Retries{policy: policy.withDefaults(), now: time.Now, windowStart: time.Now()}
+}
+
+// SetPolicy troca a política sem zerar o budget
+func (rs *Retries) SetPolicy(policy RetryPolicy) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.policy = policy.withDefaults()
+}
+
+func (rs *Retries) Policy() RetryPolicy {
+	if rs == nil {
+		return RetryPolicy{MaxAttempts: 1}
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.policy
+}
+
+// rollWindow recomeça a contagem quando a janela expira; chamado com o lock
+func (rs *Retries) rollWindow(now time.Time) {
+	if now.Sub(rs.windowStart) >= rs.policy.Window {
+		rs.windowStart, rs.requests, rs.retries = now, 0, 0
+	}
+}
+
+// observe conta uma requisição que poderia ser repetida; é o que alimenta o budget
+func (rs *Retries) observe() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.rollWindow(rs.now())
+	rs.requests++
+}
+
+// withdraw reserva uma repetição se o budget da janela permitir
+func (rs *Retries) withdraw() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.rollWindow(rs.now())
+
+	p := rs.policy
+	allowed := int(p.BudgetRatio * float64(rs.requests))
+	if floor := p.BudgetMinPerSecond * int(p.Window/time.Second); floor > allowed {
+		allowed = floor
+	}
+	if rs.retries >= allowed {
+		return false
+	}
+	rs.retries++
+	return true
+}
+
+// retryable indica se a requisição pode ser repetida sem efeito colateral
+func retryable(r *http.Request) bool {
+	if r.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryReason devolve por que a tentativa deve ser repetida, ou "" se não deve
+func retryReason(p RetryPolicy, resp *http.Response, err error) string {
+	if err != nil {
+		// Falha na conexão: a requisição não chegou a ser processada pelo upstream
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return "connection"
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return ""
+		}
+		if metrics.UpstreamErrorReason(err) == "connection" {
+			return "connection"
+		}
+		return ""
+	}
+	if slices.Contains(p.RetryOn, resp.StatusCode) {
+		return "status"
+	}
+	return ""
+}
+
+// bufferBody guarda o corpo da requisição para poder reenviá-lo; corpos
+// maiores que maxRetryBody seguem em streaming e não são repetidos
+func bufferBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+	if r.GetBody != nil {
+		return true
+	}
+	if r.ContentLength > maxRetryBody {
+		return false
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxRetryBody+1))
+	if err != nil || len(buf) > maxRetryBody {
+		// Devolve o que já foi lido na frente do restante do corpo
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return false
+	}
+	_ = r.Body.Close()
+
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return true
+}
+
+// roundTrip envia a requisição e repete as falhas transitórias enquanto a
+// política e o budget permitirem; cada nova tentativa passa de novo pelo
+// balanceamento e pelo circuit breaker
+func (rs *Retries) roundTrip(st *upstreamState, r *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	p := rs.Policy()
+	if p.MaxAttempts <= 1 || st.pick == nil || !retryable(r) {
+		return send(r)
+	}
+	canRetry := bufferBody(r)
+	rs.observe()
+
+	resp, err := send(r)
+	for attempt := 1; canRetry && attempt < p.MaxAttempts; attempt++ {
+		reason := retryReason(p, resp, err)
+		if reason == "" || r.Context().Err() != nil {
+			break
+		}
+
+		upstreamLabel := metrics.UpstreamLabels.Value(st.upstream)
+		if !rs.withdraw() {
+			metrics.UpstreamRetries.WithLabelValues(upstreamLabel, "budget_exhausted").Inc()
+			break
+		}
+
+		// Cliente que desiste durante a espera recebe a última resposta
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+
+		next := r.Clone(r.Context())
+		if r.GetBody != nil {
+			body, bodyErr := r.GetBody()
+			if bodyErr != nil {
+				break
+			}
+			next.Body = body
+		}
+
+		prev := st.attempt()
+		if pickErr := st.pick(next); pickErr != nil {
+			// Sem outro target disponível: fica a resposta da tentativa anterior
+			metrics.UpstreamRetries.WithLabelValues(upstreamLabel, "no_target").Inc()
+			break
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		}
+		// Como no ModifyResponse, só erro de transporte e 5xx contam como falha;
+		// um 429 repetido não abre o circuito
+		prev.end(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+
+		metrics.UpstreamRetries.WithLabelValues(upstreamLabel, reason).Inc()
+		if st.span != nil {
+			st.span.AddEvent("retry", trace.WithAttributes(
+				attribute.Int("aegis.retry.attempt", attempt+1),
+				attribute.String("aegis.retry.reason", reason),
+			))
+		}
+		st.retries++
+
+		r = next
+		resp, err = send(r)
+	}
+	return resp, err
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestRetryConnectionErrorOnAnotherTarget(t *testing.T) {
+	var hits atomic.Int64
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer live.Close()
+
+	// Servidor fechado: a conexão é recusada
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL, _ := url.Parse(dead.URL)
+	dead.Close()
+	liveURL, _ := url.Parse(live.URL)
+
+	pools := NewPoolStore(nil)
+	retries := NewRetries(RetryPolicy{MaxAttempts: 2, BackoffBase: time.Millisecond, BudgetMinPerSecond: 10})
+	prx := NewDynamicProxy(pools, nil, retries, TransportConfig{})
+
+	do := func(method string, header http.Header) *httptest.ResponseRecorder {
+		// Pool novo a cada requisição: o round robin sempre começa pelo target morto
+		pools.SetPools([]*Pool{NewPool(1, "api", StrategyRoundRobin, "", []*Target{
+			{ID: 1, URL: deadURL, Weight: 1},
+			{ID: 2, URL: liveURL, Weight: 1},
+		})})
+
+		req := httptest.NewRequest(method, "/proxy/x", strings.NewReader("payload"))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: "pool://api"}))
+		rec := httptest.NewRecorder()
+		prx.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPut, nil); rec.Code != http.StatusOK || rec.Body.String() != "payload" {
+		t.Fatalf("expected PUT to be retried with its body, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, nil); rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected POST without Idempotency-Key not to be retried, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, http.Header{"Idempotency-Key": {"abc"}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected POST with Idempotency-Key to be retried, got %d", rec.Code)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected 2 hits on the live target, got %d", hits.Load())
+	}
+}
+
+func TestRetryStatusAndBudget(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ímpares falham, pares respondem
+		if hits.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	// Budget de uma repetição na janela
+	retries := NewRetries(RetryPolicy{MaxAttempts: 3, RetryOn: []int{http.StatusServiceUnavailable}, BackoffBase: time.Millisecond, BudgetMinPerSecond: 1, Window: time.Second})
+	prx := NewDynamicProxy(nil, nil, retries, TransportConfig{})
+
+	do := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/x", nil)
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: upstream.URL}))
+		rec := httptest.NewRecorder()
+		prx.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do(); code != http.StatusOK {
+		t.Fatalf("expected 503 to be retried, got %d", code)
+	}
+	if code := do(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected exhausted budget to return the upstream 503, got %d", code)
+	}
+	if hits.Load() != 3 {
+		t.Fatalf("expected 3 upstream hits, got %d", hits.Load())
+	}
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	p := RetryPolicy{BackoffBase: 10 * time.Millisecond, BackoffMax: 40 * time.Millisecond}.withDefaults()
+
+	for retry, ceiling := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 10: 40 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(retry); d < 0 || d > ceiling {
+				t.Fatalf("retry %d: backoff %s outside [0, %s]", retry, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryOnNonServerErrorKeepsBreakerClosed(t *testing.T) {
+	var hits atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 429 é repetido, mas não é falha do upstream para o circuit breaker
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	breakers := NewBreakers(BreakerConfig{ConsecutiveFailures: 1, Cooldown: time.Minute})
+	retries := NewRetries(RetryPolicy{MaxAttempts: 2, RetryOn: []int{http.StatusTooManyRequests}, BackoffBase: time.Millisecond, BudgetMinPerSecond: 10})
+	prx := NewDynamicProxy(nil, breakers, retries, TransportConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy/x", nil)
+	req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: upstream.URL}))
+	rec := httptest.NewRecorder()
+	prx.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || hits.Load() != 2 {
+		t.Fatalf("expected the 429 to be retried, got %d after %d hits", rec.Code, hits.Load())
+	}
+	if state := breakers.For(upstreamURL.Host).State(); state != BreakerClosed {
+		t.Fatalf("expected the breaker to stay closed, got %s", state)
+	}
+}
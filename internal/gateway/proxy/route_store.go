@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrRouteNotFound = errors.New("route not found")
+	ErrRouteExists   = errors.New("route name already in use")
+)
+
+// uniqueViolation converte a violação do UNIQUE de routes.name em ErrRouteExists
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrRouteExists
+	}
+	return err
+}
+
+// RouteStore carrega as rotas do Postgres e mantém o snapshot usado pelo proxy
+type RouteStore struct {
+	db    *sql.DB
+	table atomic.Pointer[RouteTable]
+}
+
+func NewRouteStore(db *sql.DB) *RouteStore {
+	s := &RouteStore{db: db}
+	s.table.Store(NewRouteTable(nil, nil))
+	return s
+}
+
+func (s *RouteStore) Table() *RouteTable {
+	return s.table.Load()
+}
+
+// Reload relê rotas e concessões e troca o snapshot atomicamente
+func (s *RouteStore) Reload(ctx context.Context) error {
+	routes, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT route_id, api_key_id FROM api_key_routes`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	grants := make(map[int64][]int64)
+	for rows.Next() {
+		var routeID, keyID int64
+		if err := rows.Scan(&routeID, &keyID); err != nil {
+			return err
+		}
+		grants[routeID] = append(grants[routeID], keyID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.table.Store(NewRouteTable(routes, grants))
+	return nil
+}
+
+const routeColumns = `id, name, path_prefix, methods, host, upstream, strip_prefix, rewrite_prefix, is_active, ` + transportColumns + `,
+	auth_methods, claim_headers`
+
+func (s *RouteStore) List(ctx context.Context) ([]*Route, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+routeColumns+` FROM routes ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []*Route{}
+	for rows.Next() {
+		rt, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+
+	return routes, rows.Err()
+}
+
+func (s *RouteStore) FindByID(ctx context.Context, id int64) (*Route, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+routeColumns+` FROM routes WHERE id = $1`, id)
+	return scanRoute(row)
+}
+
+func (s *RouteStore) Create(ctx context.Context, rt Route) (*Route, error) {
+	query := `
+		INSERT INTO routes (name, path_prefix, methods, host, upstream, strip_prefix, rewrite_prefix, is_active, ` + transportColumns + `,
+			auth_methods, claim_headers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING ` + routeColumns
+
+	claimHeaders, err := json.Marshal(rt.ClaimHeaders)
+	if err != nil {
+		return nil, err
+	}
+	args := []any{rt.Name, rt.PathPrefix, strings.Join(rt.Methods, ","), rt.Host, rt.Upstream, rt.StripPrefix, rt.RewritePrefix}
+	args = append(args, transportArgs(rt.Transport)...)
+	row := s.db.QueryRowContext(ctx, query, append(args, strings.Join(rt.AuthMethods, ","), claimHeaders)...)
+
+	created, err := scanRoute(row)
+	if err != nil {
+		return nil, uniqueViolation(err)
+	}
+
+	return created, s.Reload(ctx)
+}
+
+// UpdateRouteParams altera apenas os campos não nulos; transport e auth têm
+// os próprios setters
+type UpdateRouteParams struct {
+	Name          *string
+	PathPrefix    *string
+	Methods       *[]string
+	Host          *string
+	Upstream      *string
+	StripPrefix   *bool
+	RewritePrefix *string
+	Active        *bool
+}
+
+// Update altera a rota no lugar, mantendo o id e as concessões; is_active
+// desativa a rota sem removê-la
+func (s *RouteStore) Update(ctx context.Context, id int64, p UpdateRouteParams) (*Route, error) {
+	var methods *string
+	if p.Methods != nil {
+		joined := strings.Join(*p.Methods, ",")
+		methods = &joined
+	}
+
+	query := `
+		UPDATE routes SET
+			name = COALESCE($2, name),
+			path_prefix = COALESCE($3, path_prefix),
+			methods = COALESCE($4, methods),
+			host = COALESCE($5, host),
+			upstream = COALESCE($6, upstream),
+			strip_prefix = COALESCE($7, strip_prefix),
+			rewrite_prefix = COALESCE($8, rewrite_prefix),
+			is_active = COALESCE($9, is_active)
+		WHERE id = $1
+		RETURNING ` + routeColumns
+
+	rt, err := scanRoute(s.db.QueryRowContext(ctx, query,
+		id, p.Name, p.PathPrefix, methods, p.Host, p.Upstream, p.StripPrefix, p.RewritePrefix, p.Active,
+	))
+	if err != nil {
+		return nil, uniqueViolation(err)
+	}
+
+	return rt, s.Reload(ctx)
+}
+
+// SetTransport troca timeouts e limites de conexão da rota; zeros herdam do pool
+func (s *RouteStore) SetTransport(ctx context.Context, id int64, tc TransportConfig) (*Route, error) {
+	query := `UPDATE routes SET ` + transportAssignments + ` WHERE id = $1 RETURNING ` + routeColumns
+
+	rt, err := scanRoute(s.db.QueryRowContext(ctx, query, append([]any{id}, transportArgs(tc)...)...))
+	if err != nil {
+		return nil, err
+	}
+
+	return rt, s.Reload(ctx)
+}
+
+// SetAuth troca os métodos de autenticação aceitos e os headers preenchidos com claims
+func (s *RouteStore) SetAuth(ctx context.Context, id int64, methods []string, claimHeaders map[string]string) (*Route, error) {
+	b, err := json.Marshal(claimHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE routes SET auth_methods = $2, claim_headers = $3 WHERE id = $1 RETURNING ` + routeColumns
+	rt, err := scanRoute(s.db.QueryRowContext(ctx, query, id, strings.Join(methods, ","), b))
+	if err != nil {
+		return nil, err
+	}
+
+	return rt, s.Reload(ctx)
+}
+
+func (s *RouteStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM routes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRouteNotFound
+	}
+
+	return s.Reload(ctx)
+}
+
+// Grant libera a rota para a API Key
+func (s *RouteStore) Grant(ctx context.Context, routeID, apiKeyID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_key_routes (api_key_id, route_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, apiKeyID, routeID)
+	if err != nil {
+		return err
+	}
+
+	return s.Reload(ctx)
+}
+
+func (s *RouteStore) Revoke(ctx context.Context, routeID, apiKeyID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM api_key_routes WHERE api_key_id = $1 AND route_id = $2
+	`, apiKeyID, routeID)
+	if err != nil {
+		return err
+	}
+
+	return s.Reload(ctx)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRoute(row rowScanner) (*Route, error) {
+	var (
+		rt           Route
+		methods      string
+		transport    transportRow
+		authMethods  string
+		claimHeaders []byte
+	)
+
+	dest := []any{
+		&rt.ID,
+		&rt.Name,
+		&rt.PathPrefix,
+		&methods,
+		&rt.Host,
+		&rt.Upstream,
+		&rt.StripPrefix,
+		&rt.RewritePrefix,
+		&rt.Active,
+	}
+	dest = append(dest, transport.dest()...)
+	err := row.Scan(append(dest, &authMethods, &claimHeaders)...)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrRouteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rt.Methods = parseMethods(methods)
+	rt.Transport = transport.config()
+	rt.AuthMethods = parseList(authMethods)
+	if err := json.Unmarshal(claimHeaders, &rt.ClaimHeaders); err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+func parseList(raw string) []string {
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parseMethods(raw string) []string {
+	var methods []string
+	for _, m := range strings.Split(raw, ",") {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != "" {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type ctxKeyRoute struct{}
+
+// Route encaminha requisições que casam prefixo, método e host para um upstream
+type Route struct {
+	ID            int64
+	Name          string
+	PathPrefix    string
+	Methods       []string
+	Host          string
+	Upstream      string
+	StripPrefix   bool
+	RewritePrefix string
+	Active        bool
+	Transport     TransportConfig   // sobrescreve o pool e os padrões do gateway
+	AuthMethods   []string          // métodos de autenticação aceitos; vazio usa os do gateway
+	ClaimHeaders  map[string]string // header enviado ao upstream → claim do JWT
+}
+
+func SetRoute(ctx context.Context, route *Route) context.Context {
+	return context.WithValue(ctx, ctxKeyRoute{}, route)
+}
+
+func RouteFromContext(ctx context.Context) (*Route, bool) {
+	v, ok := ctx.Value(ctxKeyRoute{}).(*Route)
+	return v, ok && v != nil
+}
+
+// Match verifica prefixo (respeitando segmentos), método e host
+func (rt *Route) Match(r *http.Request) bool {
+	if !rt.Active || !hasPathPrefix(r.URL.Path, rt.PathPrefix) {
+		return false
+	}
+
+	if len(rt.Methods) > 0 && !slices.Contains(rt.Methods, r.Method) {
+		return false
+	}
+
+	return rt.Host == "" || matchHost(rt.Host, r.Host)
+}
+
+// RewritePath aplica strip/rewrite do prefixo ao path enviado ao upstream
+func (rt *Route) RewritePath(path string) string {
+	if rt.RewritePrefix == "" && !rt.StripPrefix {
+		return path
+	}
+
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(rt.PathPrefix, "/"))
+	prefix := strings.TrimSuffix(rt.RewritePrefix, "/")
+
+	out := prefix + rest
+	if !strings.HasPrefix(out, "/") {
+		out = "/" + out
+	}
+	return out
+}
+
+// RouteTable é um snapshot imutável das rotas e das keys autorizadas em cada uma
+type RouteTable struct {
+	routes []*Route
+	grants map[int64]map[int64]struct{}
+}
+
+func NewRouteTable(routes []*Route, grants map[int64][]int64) *RouteTable {
+	sorted := slices.Clone(routes)
+
+	// Prefixo mais longo vence; rotas com host/método são mais específicas
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if len(a.PathPrefix) != len(b.PathPrefix) {
+			return len(a.PathPrefix) > len(b.PathPrefix)
+		}
+		if (a.Host != "") != (b.Host != "") {
+			return a.Host != ""
+		}
+		return len(a.Methods) > len(b.Methods)
+	})
+
+	t := &RouteTable{
+		routes: sorted,
+		grants: make(map[int64]map[int64]struct{}, len(grants)),
+	}
+	for routeID, keyIDs := range grants {
+		set := make(map[int64]struct{}, len(keyIDs))
+		for _, id := range keyIDs {
+			set[id] = struct{}{}
+		}
+		t.grants[routeID] = set
+	}
+
+	return t
+}
+
+func (t *RouteTable) Match(r *http.Request) (*Route, bool) {
+	for _, rt := range t.routes {
+		if rt.Match(r) {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+func (t *RouteTable) Allowed(routeID, apiKeyID int64) bool {
+	_, ok := t.grants[routeID][apiKeyID]
+	return ok
+}
+
+func (t *RouteTable) Routes() []*Route {
+	return t.routes
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "" || prefix == "/" {
+		return true
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
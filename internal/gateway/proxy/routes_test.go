@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestRouteTableMatch(t *testing.T) {
+	billing := &Route{ID: 1, PathPrefix: "/billing", Active: true}
+	billingV2 := &Route{ID: 2, PathPrefix: "/billing/v2", Active: true}
+	searchGet := &Route{ID: 3, PathPrefix: "/search", Methods: []string{http.MethodGet}, Active: true}
+	apiHost := &Route{ID: 4, PathPrefix: "/", Host: "*.example.com", Active: true}
+	inactive := &Route{ID: 5, PathPrefix: "/old", Active: false}
+
+	table := NewRouteTable([]*Route{billing, billingV2, searchGet, apiHost, inactive}, nil)
+
+	cases := []struct {
+		method, host, path string
+		want               int64
+	}{
+		{http.MethodGet, "gw.local", "/billing", 1},
+		{http.MethodGet, "gw.local", "/billing/invoices", 1},
+		{http.MethodGet, "gw.local", "/billing/v2/invoices", 2},
+		{http.MethodGet, "gw.local", "/billingx", 0},
+		{http.MethodGet, "gw.local", "/search?q=x", 3},
+		{http.MethodPost, "gw.local", "/search", 0},
+		{http.MethodPost, "api.example.com:8000", "/anything", 4},
+		{http.MethodGet, "example.com", "/anything", 0},
+		{http.MethodGet, "gw.local", "/old", 0},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "http://"+c.host+c.path, nil)
+		rt, ok := table.Match(r)
+
+		var got int64
+		if ok {
+			got = rt.ID
+		}
+		if got != c.want {
+			t.Errorf("%s %s%s: expected route %d, got %d", c.method, c.host, c.path, c.want, got)
+		}
+	}
+}
+
+func TestRouteRewritePath(t *testing.T) {
+	cases := []struct {
+		route Route
+		path  string
+		want  string
+	}{
+		{Route{PathPrefix: "/billing"}, "/billing/invoices", "/billing/invoices"},
+		{Route{PathPrefix: "/billing", StripPrefix: true}, "/billing/invoices", "/invoices"},
+		{Route{PathPrefix: "/billing/", StripPrefix: true}, "/billing", "/"},
+		{Route{PathPrefix: "/billing", RewritePrefix: "/api/v2"}, "/billing/invoices", "/api/v2/invoices"},
+		{Route{PathPrefix: "/billing", RewritePrefix: "/api/"}, "/billing", "/api"},
+	}
+
+	for _, c := range cases {
+		if got := c.route.RewritePath(c.path); got != c.want {
+			t.Errorf("%+v %s: expected %q, got %q", c.route, c.path, c.want, got)
+		}
+	}
+}
+
+func TestHandleRoutes(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte("billing"))
+	}))
+	defer upstream.Close()
+
+	store := NewRouteStore(nil)
+	store.table.Store(NewRouteTable(
+		[]*Route{{ID: 1, PathPrefix: "/billing", Upstream: upstream.URL, StripPrefix: true, Active: true}},
+		map[int64][]int64{1: {10}},
+	))
+
+	h := HandleRoutes(store, NewDynamicProxy(nil, nil, nil, TransportConfig{}))
+
+	do := func(apiKeyID int64, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: apiKeyID}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(10, "/billing/invoices")
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || string(body) != "billing" {
+		t.Fatalf("expected 200 billing, got %d %q", rec.Code, body)
+	}
+	if gotPath != "/invoices" {
+		t.Fatalf("expected upstream path /invoices, got %q", gotPath)
+	}
+
+	if rec := do(11, "/billing/invoices"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for key without grant, got %d", rec.Code)
+	}
+
+	if rec := do(10, "/search"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown route, got %d", rec.Code)
+	}
+}
+
+func TestHandleRoutesClaimHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	store := NewRouteStore(nil)
+	store.table.Store(NewRouteTable(
+		[]*Route{{ID: 1, PathPrefix: "/billing", Upstream: upstream.URL, Active: true,
+			ClaimHeaders: map[string]string{"X-Consumer-Id": "sub", "X-Tenant": "tenant"}}},
+		map[int64][]int64{1: {10}},
+	))
+	h := HandleRoutes(store, NewDynamicProxy(nil, nil, nil, TransportConfig{}))
+
+	do := func(claims middleware.Claims) {
+		req := httptest.NewRequest(http.MethodGet, "/billing", nil)
+		req.Header.Set("X-Consumer-Id", "spoofed")
+		req.Header.Set("X-Tenant", "spoofed")
+		ctx := middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 10})
+		if claims != nil {
+			ctx = middleware.SetClaims(ctx, claims)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req.WithContext(ctx))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	do(middleware.Claims{"sub": "consumer-1"})
+	if got.Get("X-Consumer-Id") != "consumer-1" || got.Get("X-Tenant") != "" {
+		t.Fatalf("expected claim headers from the token only, got %v", got)
+	}
+
+	// Sem JWT os headers do cliente não chegam ao upstream
+	do(nil)
+	if got.Get("X-Consumer-Id") != "" || got.Get("X-Tenant") != "" {
+		t.Fatalf("expected spoofed claim headers to be removed, got %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/martinsdevv/aegis/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEventStreamThroughMiddlewareChain(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+
+		<-release
+		_, _ = w.Write([]byte("data: second\n\n"))
+	}))
+	defer upstream.Close()
+
+	// Prazos curtos de propósito: o stream precisa sobreviver a eles
+	prx := NewDynamicProxy(nil, nil, nil, TransportConfig{RequestTimeout: 50 * time.Millisecond})
+	prx.FlushInterval = time.Minute
+
+	handler := middleware.Chain(HandleProxy(prx),
+		middleware.RequestID(),
+		middleware.ContentID(),
+		middleware.Tracing,
+		middleware.Metrics,
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := middleware.SetAPIKey(r.Context(), &middleware.APIKey{ID: 4, UpstreamHost: upstream.URL})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		},
+		middleware.Logger,
+		middleware.PublishUsage(nil),
+	)
+
+	gw := httptest.NewUnstartedServer(handler)
+	gw.Config.WriteTimeout = 100 * time.Millisecond
+	gw.Start()
+	defer gw.Close()
+
+	before := testutil.CollectAndCount(metrics.StreamDuration)
+
+	req, _ := http.NewRequest(http.MethodGet, gw.URL+"/proxy/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "data: first\n" {
+		t.Fatalf("expected first event before the upstream finished, got %q (%v)", line, err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+
+	_, _ = reader.ReadString('\n')
+	if line, err := reader.ReadString('\n'); err != nil || line != "data: second\n" {
+		t.Fatalf("expected second event after the write and request timeouts, got %q (%v)", line, err)
+	}
+
+	// O tempo do stream vai para o próprio histograma, numa série nova deste upstream
+	deadline := time.Now().Add(time.Second)
+	for testutil.CollectAndCount(metrics.StreamDuration) == before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.CollectAndCount(metrics.StreamDuration); got != before+1 {
+		t.Fatalf("expected the stream duration to be observed, got %d series (was %d)", got, before)
+	}
+}
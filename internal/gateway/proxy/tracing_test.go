@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestProxyPropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	received := make(chan trace.SpanContext, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		received <- trace.SpanContextFromContext(ctx)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	// Tracing -> rate limit -> proxy, como na cadeia do gateway
+	apiKey := &middleware.APIKey{ID: 1, Name: "tracing-test", UpstreamHost: upstream.URL}
+	handler := middleware.Chain(HandleProxy(NewDynamicProxy(nil, nil, nil, TransportConfig{})),
+		middleware.Tracing,
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(middleware.SetAPIKey(r.Context(), apiKey)))
+			})
+		},
+		middleware.RateLimit(middleware.NewRLStore(time.Minute), middleware.Limit{}),
+	)
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/proxy/ping", nil)
+	req.Header.Set("traceparent", incoming)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	server, ok := spans["GET proxy"]
+	if !ok {
+		t.Fatalf("expected server span renamed after the route, got %v", spanNames(recorder.Ended()))
+	}
+	client, ok := spans["proxy.upstream"]
+	if !ok {
+		t.Fatalf("expected proxy.upstream span, got %v", spanNames(recorder.Ended()))
+	}
+	rl, ok := spans["ratelimit"]
+	if !ok {
+		t.Fatalf("expected ratelimit span, got %v", spanNames(recorder.Ended()))
+	}
+
+	// O span de servidor continua o trace recebido
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected incoming trace id, got %s", got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Fatalf("expected incoming span as parent, got %s", got)
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() || rl.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("expected ratelimit and proxy.upstream as children of the server span")
+	}
+
+	// O upstream recebe o span do proxy como pai
+	sc := <-received
+	if sc.TraceID() != server.SpanContext().TraceID() || sc.SpanID() != client.SpanContext().SpanID() {
+		t.Fatalf("expected traceparent from proxy.upstream, got trace %s span %s", sc.TraceID(), sc.SpanID())
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
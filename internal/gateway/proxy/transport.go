@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+// TransportConfig são os timeouts e limites de conexão com um upstream. Zero
+// significa herdar: rota sobre pool, pool sobre os padrões do gateway
+type TransportConfig struct {
+	ConnectTimeout        time.Duration // estabelecimento da conexão TCP
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // espera pelos headers depois de enviar a requisição
+	RequestTimeout        time.Duration // prazo total, até o fim do corpo da resposta
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	KeepAlive             time.Duration // intervalo do TCP keep-alive
+}
+
+// Merge aplica os campos definidos em over
+func (c TransportConfig) Merge(over TransportConfig) TransportConfig {
+	pick := func(base, over time.Duration) time.Duration {
+		if over > 0 {
+			return over
+		}
+		return base
+	}
+	c.ConnectTimeout = pick(c.ConnectTimeout, over.ConnectTimeout)
+	c.TLSHandshakeTimeout = pick(c.TLSHandshakeTimeout, over.TLSHandshakeTimeout)
+	c.ResponseHeaderTimeout = pick(c.ResponseHeaderTimeout, over.ResponseHeaderTimeout)
+	c.RequestTimeout = pick(c.RequestTimeout, over.RequestTimeout)
+	c.IdleConnTimeout = pick(c.IdleConnTimeout, over.IdleConnTimeout)
+	c.KeepAlive = pick(c.KeepAlive, over.KeepAlive)
+	if over.MaxIdleConnsPerHost > 0 {
+		c.MaxIdleConnsPerHost = over.MaxIdleConnsPerHost
+	}
+	if over.MaxConnsPerHost > 0 {
+		c.MaxConnsPerHost = over.MaxConnsPerHost
+	}
+	return c
+}
+
+// IsZero indica que nada foi configurado
+func (c TransportConfig) IsZero() bool {
+	return c == TransportConfig{}
+}
+
+// transportKey separa as configurações que exigem um http.Transport próprio;
+// RequestTimeout é aplicado por requisição e não entra na chave
+func (c TransportConfig) transportKey() TransportConfig {
+	c.RequestTimeout = 0
+	return c
+}
+
+// transportID identifica um http.Transport do cache; h2c fala HTTP/2 sem TLS,
+// exigido por gRPC em upstreams http://
+type transportID struct {
+	config TransportConfig
+	h2c    bool
+}
+
+func (id transportID) newTransport() *http.Transport {
+	c := id.config
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if id.h2c {
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	dialer := &net.Dialer{Timeout: c.ConnectTimeout, KeepAlive: c.KeepAlive}
+	t.DialContext = dialer.DialContext
+	t.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	t.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+	t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = c.MaxConnsPerHost
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	return t
+}
+
+// Transports escolhe, por requisição, o http.Transport da configuração efetiva.
+// Cada configuração distinta tem seu próprio pool de conexões; é criado uma vez
+// e sobrevive aos reloads da configuração
+type Transports struct {
+	retries *Retries
+
+	mu       sync.Mutex
+	defaults TransportConfig
+	m        map[transportID]*http.Transport
+}
+
+func NewTransports(defaults TransportConfig, retries *Retries) *Transports {
+	return &Transports{defaults: defaults, retries: retries, m: map[transportID]*http.Transport{}}
+}
+
+// Defaults são os timeouts e limites usados quando pool e rota não definem os seus
+func (ts *Transports) Defaults() TransportConfig {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.defaults
+}
+
+// SetDefaults troca os padrões no reload; como toda configuração efetiva
+// herda deles, o cache é descartado e as conexões ociosas dos transports
+// antigos fechadas. Requisições em andamento terminam nos transports antigos
+func (ts *Transports) SetDefaults(defaults TransportConfig) {
+	ts.mu.Lock()
+	if defaults == ts.defaults {
+		ts.mu.Unlock()
+		return
+	}
+	old := ts.m
+	ts.defaults = defaults
+	ts.m = map[transportID]*http.Transport{}
+	ts.mu.Unlock()
+
+	for _, t := range old {
+		t.CloseIdleConnections()
+	}
+}
+
+func (ts *Transports) get(cfg TransportConfig, h2c bool) *http.Transport {
+	key := transportID{config: cfg.transportKey(), h2c: h2c}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	t, ok := ts.m[key]
+	if !ok {
+		t = key.newTransport()
+		ts.m[key] = t
+	}
+	return t
+}
+
+func (ts *Transports) RoundTrip(r *http.Request) (*http.Response, error) {
+	st := upstreamStateFromContext(r.Context())
+	// Sem target escolhido a URL ainda é a do cliente (pode ser absoluta):
+	// nunca envia, senão o gateway vira proxy aberto para hosts internos
+	if st == nil || st.upstream == "" {
+		return nil, ErrNoUpstream
+	}
+	if st.err != nil {
+		return nil, st.err
+	}
+
+	// Cada tentativa pode cair num target diferente, então o esquema é visto por envio
+	send := func(r *http.Request) (*http.Response, error) {
+		h2c := r.URL.Scheme == "http" && middleware.IsGRPC(r)
+		return ts.get(st.transport, h2c).RoundTrip(r)
+	}
+	return ts.retries.roundTrip(st, r, send)
+}
+
+// CloseIdleConnections libera as conexões ociosas de todos os transports
+func (ts *Transports) CloseIdleConnections() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, t := range ts.m {
+		t.CloseIdleConnections()
+	}
+}
+
+var errRequestTimeout = errors.New("upstream request timeout")
+
+// timeoutPhase identifica qual prazo expirou, para o erro 504
+func timeoutPhase(ctx context.Context, err error) string {
+	var opErr *net.OpError
+	switch {
+	case errors.Is(context.Cause(ctx), errRequestTimeout):
+		return "request"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "connect"
+	case strings.Contains(err.Error(), "TLS handshake timeout"):
+		return "tls_handshake"
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return "response_header"
+	default:
+		return "request"
+	}
+}
+
+// transportColumns são as colunas de TransportConfig em routes e upstream_pools, em milissegundos
+const transportColumns = `connect_timeout_ms, tls_handshake_timeout_ms, response_header_timeout_ms, request_timeout_ms,
+	max_idle_conns_per_host, max_conns_per_host, idle_conn_timeout_ms, keep_alive_ms`
+
+// transportRow recebe as colunas do banco antes da conversão para durações
+type transportRow struct {
+	connect, tls, header, request, idle, keepAlive int64
+	maxIdle, maxConns                              int
+}
+
+func (row *transportRow) dest() []any {
+	return []any{&row.connect, &row.tls, &row.header, &row.request, &row.maxIdle, &row.maxConns, &row.idle, &row.keepAlive}
+}
+
+func (row transportRow) config() TransportConfig {
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+	return TransportConfig{
+		ConnectTimeout:        ms(row.connect),
+		TLSHandshakeTimeout:   ms(row.tls),
+		ResponseHeaderTimeout: ms(row.header),
+		RequestTimeout:        ms(row.request),
+		MaxIdleConnsPerHost:   row.maxIdle,
+		MaxConnsPerHost:       row.maxConns,
+		IdleConnTimeout:       ms(row.idle),
+		KeepAlive:             ms(row.keepAlive),
+	}
+}
+
+// transportArgs devolve os valores na ordem de transportColumns
+func transportArgs(c TransportConfig) []any {
+	return []any{
+		c.ConnectTimeout.Milliseconds(), c.TLSHandshakeTimeout.Milliseconds(),
+		c.ResponseHeaderTimeout.Milliseconds(), c.RequestTimeout.Milliseconds(),
+		c.MaxIdleConnsPerHost, c.MaxConnsPerHost,
+		c.IdleConnTimeout.Milliseconds(), c.KeepAlive.Milliseconds(),
+	}
+}
+
+const transportAssignments = `connect_timeout_ms = $2, tls_handshake_timeout_ms = $3,
+	response_header_timeout_ms = $4, request_timeout_ms = $5,
+	max_idle_conns_per_host = $6, max_conns_per_host = $7,
+	idle_conn_timeout_ms = $8, keep_alive_ms = $9`
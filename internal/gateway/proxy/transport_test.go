@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+func TestTransportConfigMerge(t *testing.T) {
+	defaults := TransportConfig{ConnectTimeout: 5 * time.Second, ResponseHeaderTimeout: 30 * time.Second, MaxIdleConnsPerHost: 32}
+	pool := TransportConfig{ResponseHeaderTimeout: 10 * time.Second, MaxConnsPerHost: 100}
+	route := TransportConfig{ResponseHeaderTimeout: 2 * time.Second, RequestTimeout: 5 * time.Second}
+
+	got := defaults.Merge(pool).Merge(route)
+	want := TransportConfig{
+		ConnectTimeout:        5 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+		RequestTimeout:        5 * time.Second,
+		MaxIdleConnsPerHost:   32,
+		MaxConnsPerHost:       100,
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestRouteTimeoutReturns504(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("late"))
+	}))
+	defer upstream.Close()
+
+	store := NewRouteStore(nil)
+	store.table.Store(NewRouteTable([]*Route{
+		{ID: 1, PathPrefix: "/slow-header", Upstream: upstream.URL, Active: true,
+			Transport: TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond}},
+		{ID: 2, PathPrefix: "/slow-total", Upstream: upstream.URL, Active: true,
+			Transport: TransportConfig{RequestTimeout: 50 * time.Millisecond}},
+	}, map[int64][]int64{1: {10}, 2: {10}}))
+
+	// O padrão do gateway é folgado; só o prazo da rota expira
+	h := HandleRoutes(store, NewDynamicProxy(nil, nil, nil, TransportConfig{ResponseHeaderTimeout: time.Minute}))
+
+	for path, phase := range map[string]string{"/slow-header": "response_header", "/slow-total": "request"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 10}))
+		rec := httptest.NewRecorder()
+
+		start := time.Now()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("%s: expected 504, got %d", path, rec.Code)
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("%s: expected the route timeout to cut the request short", path)
+		}
+
+		var body struct {
+			Error     string `json:"error"`
+			Timeout   string `json:"timeout"`
+			TimeoutMS int64  `json:"timeout_ms"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: expected JSON body: %v", path, err)
+		}
+		if body.Error != "upstream_timeout" || body.Timeout != phase || body.TimeoutMS != 50 {
+			t.Fatalf("%s: unexpected body %+v", path, body)
+		}
+	}
+}
+
+func TestTransportsSetDefaultsClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 4)
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstream.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	defaults := TransportConfig{ResponseHeaderTimeout: time.Second}
+	transports := NewTransports(defaults, nil)
+	serve := func() {
+		// Cada reload monta um proxy novo sobre os mesmos transports
+		prx := NewProxy(nil, nil, transports)
+		req := httptest.NewRequest(http.MethodGet, "/proxy/", nil)
+		req = req.WithContext(middleware.SetAPIKey(req.Context(), &middleware.APIKey{ID: 1, UpstreamHost: upstream.URL}))
+		rec := httptest.NewRecorder()
+		prx.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	serve()
+	transports.SetDefaults(defaults)
+	serve()
+	select {
+	case <-closed:
+		t.Fatal("expected unchanged defaults to keep the idle connection")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	transports.SetDefaults(TransportConfig{ResponseHeaderTimeout: 2 * time.Second})
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected idle upstream connection to be closed after the defaults changed")
+	}
+	if got := transports.Defaults().ResponseHeaderTimeout; got != 2*time.Second {
+		t.Fatalf("expected new defaults, got %s", got)
+	}
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+// echoUpgrade aceita Upgrade: echo e devolve tudo que receber
+func echoUpgrade(w http.ResponseWriter, r *http.Request) {
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	_ = brw.Flush()
+	_, _ = io.Copy(conn, brw)
+}
+
+func dialUpgrade(t *testing.T, addr string) (net.Conn, *bufio.Reader, int) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("GET /proxy/ws HTTP/1.1\r\nHost: gw\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, reader, res.StatusCode
+}
+
+func TestUpgradeThroughMiddlewareChain(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(echoUpgrade))
+	defer upstream.Close()
+
+	conns := middleware.NewConnLimiter()
+	// O prazo total das requisições não vale para a conexão com Upgrade
+	prx := NewDynamicProxy(nil, nil, nil, TransportConfig{RequestTimeout: 50 * time.Millisecond})
+
+	handler := middleware.Chain(HandleProxy(prx),
+		middleware.RequestID(),
+		middleware.ContentID(),
+		middleware.Metrics,
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := middleware.SetAPIKey(r.Context(), &middleware.APIKey{ID: 3, UpstreamHost: upstream.URL})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		},
+		conns.Limit(1),
+		middleware.Logger,
+	)
+
+	gw := httptest.NewServer(handler)
+	defer gw.Close()
+	addr := strings.TrimPrefix(gw.URL, "http://")
+
+	conn, reader, status := dialUpgrade(t, addr)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", status)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_, _ = conn.Write([]byte("ping\n"))
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected echo after the request timeout, got %q (%v)", line, err)
+	}
+
+	// A segunda conexão da mesma chave passa do limite
+	second, _, status := dialUpgrade(t, addr)
+	second.Close()
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the connection limit, got %d", status)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for conns.Open(3) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := conns.Open(3); n != 0 {
+		t.Fatalf("expected the slot to be released on close, got %d open", n)
+	}
+}
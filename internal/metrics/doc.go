@@ -0,0 +1,2 @@
+// Package metrics defines the Prometheus collectors exported by the gateway on its internal listener
+package metrics
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry é próprio do gateway para não misturar com coletores globais de bibliotecas
+var Registry = prometheus.NewRegistry()
+
+// Valores usados quando um label não se aplica ou estourou o limite de cardinalidade
+const (
+	LabelNone  = "none"
+	LabelOther = "other"
+)
+
+var (
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_http_requests_total",
+		Help: "Requests served by the gateway.",
+	}, []string{"route", "upstream", "status_class", "api_key"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aegis_http_request_duration_seconds",
+		Help:    "Request latency from the first middleware until the response is complete; for event streams, until the response headers.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"route", "upstream", "status_class", "api_key"})
+
+	StreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aegis_http_stream_duration_seconds",
+		Help:    "How long event streams (text/event-stream) stayed open.",
+		Buckets: []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600},
+	}, []string{"route", "upstream"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_ratelimit_rejections_total",
+		Help: "Requests rejected by the rate limiter (429).",
+	}, []string{"api_key"})
+
+	QuotaRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_quota_rejections_total",
+		Help: "Requests rejected because the monthly quota is exhausted.",
+	}, []string{"api_key"})
+
+	APIKeyCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_apikey_cache_total",
+		Help: "API key lookups in the Redis cache by result (hit, miss).",
+	}, []string{"result"})
+
+	UpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_upstream_errors_total",
+		Help: "Upstream failures by reason.",
+	}, []string{"upstream", "reason"})
+
+	UpstreamRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_upstream_retries_total",
+		Help: "Upstream retries by reason, including retries denied by the budget.",
+	}, []string{"upstream", "reason"})
+
+	BreakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aegis_circuit_breaker_transitions_total",
+		Help: "Circuit breaker state transitions.",
+	}, []string{"upstream", "to"})
+
+	UsageEventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "aegis_usage_events_dropped_total",
+		Help: "Usage events dropped because both the publish queue and the spool queue were full.",
+	})
+
+	RedisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aegis_redis_command_duration_seconds",
+		Help:    "Redis command latency.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+
+	PostgresDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aegis_postgres_query_duration_seconds",
+		Help:    "Postgres query latency.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestsTotal,
+		RequestDuration,
+		StreamDuration,
+		RateLimitRejections,
+		QuotaRejections,
+		APIKeyCache,
+		UpstreamErrors,
+		UpstreamRetries,
+		BreakerTransitions,
+		UsageEventsDropped,
+		RedisDuration,
+		PostgresDuration,
+	)
+}
+
+// Handler expõe o Registry no formato do Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// StatusClass agrupa o status HTTP em 1xx..5xx
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Since devolve os segundos decorridos, no formato dos histogramas
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// LabelLimiter protege um label contra cardinalidade ilimitada: só os primeiros
+// max valores distintos são usados, o resto vira "other"
+type LabelLimiter struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func NewLabelLimiter(max int) *LabelLimiter {
+	return &LabelLimiter{max: max, seen: map[string]struct{}{}}
+}
+
+func (l *LabelLimiter) Value(v string) string {
+	if v == "" {
+		return LabelNone
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[v]; ok {
+		return v
+	}
+	if len(l.seen) >= l.max {
+		return LabelOther
+	}
+	l.seen[v] = struct{}{}
+	return v
+}
+
+// SetMax ajusta o limite; valores já vistos continuam aceitos
+func (l *LabelLimiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+}
+
+// Limitadores compartilhados pelos labels dinâmicos
+var (
+	APIKeyLabels   = NewLabelLimiter(200)
+	RouteLabels    = NewLabelLimiter(200)
+	UpstreamLabels = NewLabelLimiter(200)
+)
@@ -0,0 +1,34 @@
+package metrics
+
+import "testing"
+
+func TestLabelLimiter(t *testing.T) {
+	l := NewLabelLimiter(2)
+
+	if l.Value("a") != "a" || l.Value("b") != "b" {
+		t.Fatal("expected the first values to be kept")
+	}
+	if got := l.Value("c"); got != LabelOther {
+		t.Fatalf("expected overflow to collapse into %q, got %q", LabelOther, got)
+	}
+	if l.Value("a") != "a" {
+		t.Fatal("expected already seen values to stay accepted")
+	}
+	if got := l.Value(""); got != LabelNone {
+		t.Fatalf("expected empty value to become %q, got %q", LabelNone, got)
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id FROM api_keys":               "select",
+		"\n\t\tINSERT INTO usage_rollups (...)": "insert",
+		"VACUUM":                                "other",
+		"":                                      "other",
+	}
+	for sql, want := range cases {
+		if got := sqlOperation(sql); got != want {
+			t.Errorf("sqlOperation(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHook mede a latência de cada comando (ou pipeline) do go-redis
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		RedisDuration.WithLabelValues(cmd.Name(), redisResult(err)).Observe(Since(start))
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		RedisDuration.WithLabelValues("pipeline", redisResult(err)).Observe(Since(start))
+		return err
+	}
+}
+
+// redis.Nil é resposta normal (chave ausente), não erro
+func redisResult(err error) string {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return "ok"
+	}
+	return "error"
+}
+
+type ctxKeyQueryStart struct{}
+
+type queryStart struct {
+	at        time.Time
+	operation string
+}
+
+// PostgresTracer mede a latência das queries feitas pelo pgx
+type PostgresTracer struct{}
+
+func (PostgresTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, ctxKeyQueryStart{}, queryStart{at: time.Now(), operation: sqlOperation(data.SQL)})
+}
+
+func (PostgresTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	st, ok := ctx.Value(ctxKeyQueryStart{}).(queryStart)
+	if !ok {
+		return
+	}
+
+	result := "ok"
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		result = "error"
+	}
+	PostgresDuration.WithLabelValues(st.operation, result).Observe(Since(st.at))
+}
+
+// sqlOperation usa o primeiro verbo da query como label, com um conjunto fechado de valores
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "other"
+	}
+
+	switch op := strings.ToLower(fields[0]); op {
+	case "select", "insert", "update", "delete", "begin", "commit", "rollback", "with":
+		return op
+	default:
+		return "other"
+	}
+}
+
+// UpstreamErrorReason classifica o erro do transporte para o label reason
+func UpstreamErrorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "connection"
+	}
+}
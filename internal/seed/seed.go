@@ -37,6 +37,45 @@ func RunSeed(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
+// RunAdminSeed registra o token de bootstrap do operador inicial. Tokens de
+// bootstrap anteriores são desativados, então remover AEGIS_ADMIN_TOKEN (raw
+// vazio) ou trocar o valor revoga o token antigo no próximo start; voltar a um
+// valor anterior reativa o token
+func RunAdminSeed(ctx context.Context, db *sql.DB, rawToken string) error {
+	hashed := ""
+	if rawToken != "" {
+		hashed = hashKey(rawToken)
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE admin_tokens SET is_active = FALSE
+		WHERE operator = 'bootstrap' AND is_active AND token <> $1;
+	`, hashed)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Admin bootstrap token revoked (%d)", n)
+	}
+
+	if rawToken == "" {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO admin_tokens (operator, token, role, is_active)
+		VALUES ('bootstrap', $1, 'admin', TRUE)
+		ON CONFLICT (token) DO UPDATE SET is_active = TRUE
+		WHERE admin_tokens.operator = 'bootstrap';
+	`, hashed)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Admin bootstrap token registered")
+	return nil
+}
+
 func RunMigrations(databaseURL string) error {
 	m, err := migrate.New(
 		"file://internal/db/migrations",
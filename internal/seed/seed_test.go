@@ -0,0 +1,69 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/martinsdevv/aegis/internal/testdb"
+)
+
+func TestRunAdminSeedRevokesBootstrapToken(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+
+	active := func(raw string) bool {
+		t.Helper()
+		var ok bool
+		if err := db.QueryRow(`SELECT is_active FROM admin_tokens WHERE token = $1`, hashKey(raw)).Scan(&ok); err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if err := RunAdminSeed(ctx, db, "first"); err != nil {
+		t.Fatal(err)
+	}
+	// Reiniciar com o mesmo valor mantém o token
+	if err := RunAdminSeed(ctx, db, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if !active("first") {
+		t.Fatal("expected the bootstrap token to stay active")
+	}
+
+	// Trocar o valor revoga o anterior
+	if err := RunAdminSeed(ctx, db, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if active("first") || !active("second") {
+		t.Fatal("expected only the new bootstrap token to be active")
+	}
+
+	// Sem a variável nenhum token de bootstrap continua valendo; outros operadores não são afetados
+	if _, err := db.Exec(`INSERT INTO admin_tokens (operator, token, role) VALUES ('alice', $1, 'admin')`, hashKey("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunAdminSeed(ctx, db, ""); err != nil {
+		t.Fatal(err)
+	}
+	if active("second") || !active("alice") {
+		t.Fatal("expected the bootstrap token revoked and the operator token kept")
+	}
+
+	// Voltar a um valor revogado reativa o token
+	if err := RunAdminSeed(ctx, db, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if !active("first") || active("second") {
+		t.Fatal("expected the restored bootstrap token to be active again")
+	}
+
+	// O conflito com o token de outro operador não o transforma em bootstrap
+	if err := RunAdminSeed(ctx, db, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	var operator string
+	if err := db.QueryRow(`SELECT operator FROM admin_tokens WHERE token = $1`, hashKey("alice")).Scan(&operator); err != nil || operator != "alice" {
+		t.Fatalf("expected the operator token untouched, got %q (%v)", operator, err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package testdb abre um Postgres isolado para os testes que precisam do banco.
+// Sem AEGIS_TEST_DATABASE_URL os testes são pulados; com ela, cada teste ganha
+// um schema próprio com as migrations aplicadas, removido no fim
+package testdb
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Open devolve uma conexão com um schema vazio e migrado
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	raw := os.Getenv("AEGIS_TEST_DATABASE_URL")
+	if raw == "" {
+		t.Skip("AEGIS_TEST_DATABASE_URL not set")
+	}
+
+	admin, err := sql.Open("pgx", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	schema := "aegis_test_" + randomSuffix(t)
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	scoped, err := withSearchPath(raw, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.New("file://"+migrationsDir(), scoped)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate up: %v", err)
+	}
+	_, _ = m.Close()
+
+	db, err := sql.Open("pgx", scoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// withSearchPath faz as conexões (pgx e lib/pq, usado pelo migrate) enxergarem só o schema do teste
+func withSearchPath(raw, schema string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// migrationsDir localiza internal/db/migrations a partir deste arquivo, já que
+// go test roda cada pacote no próprio diretório
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "db", "migrations")
+}
+
+func randomSuffix(t testing.TB) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,2 @@
+// Package tracing configures the OpenTelemetry tracer provider and the W3C propagators used by the gateway
+package tracing
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: path, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	_, span := Start(context.Background(), "offline-span")
+	span.End()
+
+	// O shutdown descarrega o batch no arquivo
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(b), `"Name":"offline-span"`) {
+		t.Fatalf("expected span in file, got %s", b)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
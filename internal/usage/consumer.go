@@ -0,0 +1,206 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	consumerBatch      = 500
+	consumerBlock      = 5 * time.Second
+	claimIdle          = time.Minute
+	retryBackoff       = 5 * time.Second
+	seenRetention      = 7 * 24 * time.Hour
+	pruneSeenEvery     = time.Hour
+	claimOrphanedEvery = 30 * time.Second
+)
+
+// Consumer lê o stream de uso num consumer group e aplica os eventos no Store.
+// Mensagens só recebem ACK depois que a transação dos rollups foi confirmada.
+type Consumer struct {
+	client *redis.Client
+	store  *Store
+	stream string
+	group  string
+	name   string
+}
+
+func NewConsumer(client *redis.Client, store *Store, stream, group, name string) *Consumer {
+	return &Consumer{client: client, store: store, stream: stream, group: group, name: name}
+}
+
+// Run processa o stream até o contexto ser cancelado
+func (c *Consumer) Run(ctx context.Context) error {
+	if err := c.ensureGroup(ctx); err != nil {
+		return err
+	}
+
+	// Começa pelas mensagens que esta instância leu e não confirmou antes de cair
+	pending := true
+	lastPrune := time.Time{}
+	lastClaim := time.Now()
+
+	for ctx.Err() == nil {
+		if time.Since(lastPrune) >= pruneSeenEvery {
+			if n, err := c.store.PruneSeen(ctx, seenRetention); err != nil {
+				slog.Warn("usage seen prune failed", "err", err)
+			} else if n > 0 {
+				slog.Info("usage seen pruned", "events", n)
+			}
+			lastPrune = time.Now()
+		}
+
+		// Assume mensagens paradas de consumidores que sumiram
+		if !pending && time.Since(lastClaim) >= claimOrphanedEvery {
+			if err := c.claimOrphaned(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("usage claim failed", "err", err)
+			}
+			pending = true
+			lastClaim = time.Now()
+		}
+
+		start := ">"
+		if pending {
+			start = "0"
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group,
+			Consumer: c.name,
+			Streams:  []string{c.stream, start},
+			Count:    consumerBatch,
+			Block:    consumerBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Error("usage stream read failed", "err", err)
+			sleep(ctx, retryBackoff)
+			continue
+		}
+
+		var msgs []redis.XMessage
+		for _, s := range streams {
+			msgs = append(msgs, s.Messages...)
+		}
+		if pending && len(msgs) == 0 {
+			pending = false
+			continue
+		}
+
+		if err := c.process(ctx, msgs); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			// Sem ACK as mensagens ficam pendentes e são relidas
+			slog.Error("usage batch failed", "messages", len(msgs), "err", err)
+			pending = true
+			sleep(ctx, retryBackoff)
+		}
+	}
+
+	return nil
+}
+
+func (c *Consumer) process(ctx context.Context, msgs []redis.XMessage) error {
+	events := make([]middleware.UsageEvent, 0, len(msgs))
+	ids := make([]string, 0, len(msgs))
+
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+
+		e, err := decodeEvent(m)
+		if err != nil {
+			// Mensagem inválida nunca vai passar: registra e confirma para não travar o grupo
+			slog.Error("usage event discarded", "id", m.ID, "err", err)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	applied, err := c.store.Apply(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client.XAck(ctx, c.stream, c.group, ids...).Err(); err != nil {
+		// Os eventos já foram aplicados; a releitura é descartada pela deduplicação
+		return err
+	}
+
+	if duplicates := len(events) - applied; duplicates > 0 {
+		slog.Info("usage duplicates skipped", "events", duplicates)
+	}
+	return nil
+}
+
+func (c *Consumer) ensureGroup(ctx context.Context) error {
+	// "0" para agregar também o histórico já existente no primeiro deploy
+	err := c.client.XGroupCreateMkStream(ctx, c.stream, c.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (c *Consumer) claimOrphaned(ctx context.Context) error {
+	start := "0-0"
+	for {
+		_, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.stream,
+			Group:    c.group,
+			Consumer: c.name,
+			MinIdle:  claimIdle,
+			Start:    start,
+			Count:    consumerBatch,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+func decodeEvent(m redis.XMessage) (middleware.UsageEvent, error) {
+	var e middleware.UsageEvent
+
+	raw, ok := m.Values["payload"].(string)
+	if !ok {
+		return e, fmt.Errorf("missing payload")
+	}
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return e, err
+	}
+	if e.EventID == "" {
+		return e, fmt.Errorf("missing event_id")
+	}
+	if _, _, err := parseEvent(e); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
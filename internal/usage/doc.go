@@ -0,0 +1,2 @@
+// Package usage consumes the gateway usage stream and maintains the rollups used for billing and reports
+package usage
@@ -0,0 +1,216 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// maxReportPaths limita a quantidade de paths no relatório, priorizando os mais usados
+const maxReportPaths = 100
+
+// Stats resume um conjunto de requisições
+type Stats struct {
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"` // 5xx / requisições
+	BytesOut     int64   `json:"bytes_out"`
+	Latency      Latency `json:"latency_ms"`
+}
+
+// Latency traz a média e os percentis estimados pelo histograma (limite superior do bucket)
+type Latency struct {
+	Avg int64 `json:"avg"`
+	P50 int64 `json:"p50"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+type DayStats struct {
+	Date string `json:"date"`
+	Stats
+}
+
+type PathStats struct {
+	Path string `json:"path"`
+	Stats
+}
+
+// Report é o consumo de uma chave num intervalo de dias (UTC, inclusivo)
+type Report struct {
+	Totals Stats       `json:"totals"`
+	Days   []DayStats  `json:"days"`
+	Paths  []PathStats `json:"paths"`
+}
+
+// statsAcc acumula contagens e o histograma antes de calcular os percentis
+type statsAcc struct {
+	Stats
+	latencySum int64
+	histogram  map[int]int64
+}
+
+func newStatsAcc() *statsAcc {
+	return &statsAcc{histogram: map[int]int64{}}
+}
+
+func (a *statsAcc) addCounts(status int, requests, bytesOut, latencySum, latencyMax int64) {
+	a.Requests += requests
+	a.BytesOut += bytesOut
+	a.latencySum += latencySum
+	a.Latency.Max = max(a.Latency.Max, latencyMax)
+
+	switch {
+	case status >= 500:
+		a.ServerErrors += requests
+	case status >= 400:
+		a.ClientErrors += requests
+	}
+}
+
+func (a *statsAcc) finish() Stats {
+	s := a.Stats
+	if s.Requests == 0 {
+		return s
+	}
+
+	s.ErrorRate = float64(s.ServerErrors) / float64(s.Requests)
+	s.Latency.Avg = a.latencySum / s.Requests
+	s.Latency.P50 = percentile(a.histogram, 0.50, s.Latency.Max)
+	s.Latency.P95 = percentile(a.histogram, 0.95, s.Latency.Max)
+	s.Latency.P99 = percentile(a.histogram, 0.99, s.Latency.Max)
+	return s
+}
+
+// percentile devolve o limite superior do bucket que contém o percentil; no
+// bucket +Inf (ou acima do máximo observado) usa a maior latência vista
+func percentile(histogram map[int]int64, p float64, maxSeen int64) int64 {
+	var total int64
+	for _, n := range histogram {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := int64(float64(total)*p + 0.999999)
+	var seen int64
+	for _, le := range LatencyBuckets {
+		seen += histogram[le]
+		if seen >= rank {
+			if le == LatencyInf || int64(le) > maxSeen {
+				return maxSeen
+			}
+			return int64(le)
+		}
+	}
+	return maxSeen
+}
+
+// Report monta o relatório diário da chave a partir dos rollups
+func (s *Store) Report(ctx context.Context, apiKeyID int64, from, to time.Time) (Report, error) {
+	totals := newStatsAcc()
+	days := map[string]*statsAcc{}
+	paths := map[string]*statsAcc{}
+
+	acc := func(m map[string]*statsAcc, k string) *statsAcc {
+		a, ok := m[k]
+		if !ok {
+			a = newStatsAcc()
+			m[k] = a
+		}
+		return a
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT bucket, path, status_code, requests, bytes_out, latency_ms_sum, latency_ms_max
+		FROM usage_rollups
+		WHERE granularity = 'day' AND api_key_id = $1 AND bucket >= $2 AND bucket <= $3
+	`, apiKeyID, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+	for rows.Next() {
+		var (
+			bucket                                     time.Time
+			path                                       string
+			status                                     int
+			requests, bytesOut, latencySum, latencyMax int64
+		)
+		if err := rows.Scan(&bucket, &path, &status, &requests, &bytesOut, &latencySum, &latencyMax); err != nil {
+			rows.Close()
+			return Report{}, err
+		}
+
+		day := bucket.Format(time.DateOnly)
+		for _, a := range []*statsAcc{totals, acc(days, day), acc(paths, path)} {
+			a.addCounts(status, requests, bytesOut, latencySum, latencyMax)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Report{}, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT bucket, path, le_ms, requests
+		FROM usage_latency_rollups
+		WHERE granularity = 'day' AND api_key_id = $1 AND bucket >= $2 AND bucket <= $3
+	`, apiKeyID, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+	for rows.Next() {
+		var (
+			bucket   time.Time
+			path     string
+			le       int
+			requests int64
+		)
+		if err := rows.Scan(&bucket, &path, &le, &requests); err != nil {
+			rows.Close()
+			return Report{}, err
+		}
+
+		day := bucket.Format(time.DateOnly)
+		for _, a := range []*statsAcc{totals, acc(days, day), acc(paths, path)} {
+			a.histogram[le] += requests
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Report{}, err
+	}
+
+	return buildReport(totals, days, paths), nil
+}
+
+func buildReport(totals *statsAcc, days, paths map[string]*statsAcc) Report {
+	report := Report{
+		Totals: totals.finish(),
+		Days:   make([]DayStats, 0, len(days)),
+		Paths:  make([]PathStats, 0, len(paths)),
+	}
+
+	for day, a := range days {
+		report.Days = append(report.Days, DayStats{Date: day, Stats: a.finish()})
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+
+	for path, a := range paths {
+		report.Paths = append(report.Paths, PathStats{Path: path, Stats: a.finish()})
+	}
+	sort.Slice(report.Paths, func(i, j int) bool {
+		if report.Paths[i].Requests != report.Paths[j].Requests {
+			return report.Paths[i].Requests > report.Paths[j].Requests
+		}
+		return report.Paths[i].Path < report.Paths[j].Path
+	})
+	if len(report.Paths) > maxReportPaths {
+		report.Paths = report.Paths[:maxReportPaths]
+	}
+
+	return report
+}
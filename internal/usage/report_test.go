@@ -0,0 +1,60 @@
+package usage
+
+import "testing"
+
+func TestPercentile(t *testing.T) {
+	// 90 requisições até 10ms, 9 até 250ms e 1 acima de 10s
+	histogram := map[int]int64{10: 90, 250: 9, LatencyInf: 1}
+
+	if got := percentile(histogram, 0.50, 12000); got != 10 {
+		t.Fatalf("p50: expected 10, got %d", got)
+	}
+	if got := percentile(histogram, 0.95, 12000); got != 250 {
+		t.Fatalf("p95: expected 250, got %d", got)
+	}
+	if got := percentile(histogram, 0.99, 12000); got != 250 {
+		t.Fatalf("p99: expected 250, got %d", got)
+	}
+	if got := percentile(histogram, 1, 12000); got != 12000 {
+		t.Fatalf("p100: expected the observed max, got %d", got)
+	}
+
+	// O limite do bucket nunca passa do máximo observado
+	if got := percentile(map[int]int64{1000: 3}, 0.5, 420); got != 420 {
+		t.Fatalf("expected percentile capped at max, got %d", got)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	totals := newStatsAcc()
+	days := map[string]*statsAcc{"2026-03-11": newStatsAcc(), "2026-03-10": newStatsAcc()}
+	paths := map[string]*statsAcc{"/a": newStatsAcc(), "/b": newStatsAcc()}
+
+	for _, a := range []*statsAcc{totals, days["2026-03-10"], paths["/a"]} {
+		a.addCounts(200, 8, 800, 80, 20)
+		a.addCounts(404, 1, 10, 5, 5)
+		a.addCounts(503, 1, 0, 30, 30)
+		a.histogram[10] += 8
+		a.histogram[50] += 2
+	}
+	days["2026-03-11"].addCounts(200, 1, 1, 1, 1)
+	paths["/b"].addCounts(200, 1, 1, 1, 1)
+
+	report := buildReport(totals, days, paths)
+
+	if report.Totals.Requests != 10 || report.Totals.ClientErrors != 1 || report.Totals.ServerErrors != 1 {
+		t.Fatalf("unexpected totals: %+v", report.Totals)
+	}
+	if report.Totals.ErrorRate != 0.1 {
+		t.Fatalf("expected error rate 0.1, got %v", report.Totals.ErrorRate)
+	}
+	if report.Totals.Latency.Avg != 11 || report.Totals.Latency.P50 != 10 || report.Totals.Latency.P99 != 30 {
+		t.Fatalf("unexpected latency: %+v", report.Totals.Latency)
+	}
+	if report.Days[0].Date != "2026-03-10" || report.Days[1].Date != "2026-03-11" {
+		t.Fatalf("expected days in chronological order, got %+v", report.Days)
+	}
+	if report.Paths[0].Path != "/a" {
+		t.Fatalf("expected most used path first, got %+v", report.Paths)
+	}
+}
@@ -0,0 +1,109 @@
+package usage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// LatencyInf é o limite superior do último bucket do histograma
+const LatencyInf = math.MaxInt32
+
+// LatencyBuckets são os limites superiores (ms) do histograma de latência
+var LatencyBuckets = []int{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, LatencyInf}
+
+type rollupKey struct {
+	Granularity string
+	Bucket      time.Time
+	APIKeyID    int64
+	Path        string
+	StatusCode  int
+}
+
+type rollupValue struct {
+	Requests     int64
+	BytesOut     int64
+	LatencyMSSum int64
+	LatencyMSMax int64
+}
+
+type latencyKey struct {
+	Granularity string
+	Bucket      time.Time
+	APIKeyID    int64
+	Path        string
+	LeMS        int
+}
+
+// Rollups acumula em memória um lote de eventos antes de gravar no Postgres
+type Rollups struct {
+	counts  map[rollupKey]*rollupValue
+	latency map[latencyKey]int64
+}
+
+func NewRollups() *Rollups {
+	return &Rollups{
+		counts:  map[rollupKey]*rollupValue{},
+		latency: map[latencyKey]int64{},
+	}
+}
+
+// parseEvent extrai os campos do evento que viram chave dos rollups
+func parseEvent(e middleware.UsageEvent) (int64, time.Time, error) {
+	apiKeyID, err := strconv.ParseInt(e.APIKeyID, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid api_key_id %q: %w", e.APIKeyID, err)
+	}
+	ts, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid timestamp %q: %w", e.Timestamp, err)
+	}
+	return apiKeyID, ts.UTC(), nil
+}
+
+// Add agrega o evento nos buckets de hora e de dia
+func (r *Rollups) Add(e middleware.UsageEvent) error {
+	apiKeyID, ts, err := parseEvent(e)
+	if err != nil {
+		return err
+	}
+
+	buckets := map[string]time.Time{
+		GranularityHour: ts.Truncate(time.Hour),
+		GranularityDay:  time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC),
+	}
+
+	for granularity, bucket := range buckets {
+		k := rollupKey{granularity, bucket, apiKeyID, e.Path, e.StatusCode}
+		v, ok := r.counts[k]
+		if !ok {
+			v = &rollupValue{}
+			r.counts[k] = v
+		}
+		v.Requests++
+		v.BytesOut += e.BytesOut
+		v.LatencyMSSum += e.LatencyMS
+		v.LatencyMSMax = max(v.LatencyMSMax, e.LatencyMS)
+
+		r.latency[latencyKey{granularity, bucket, apiKeyID, e.Path, latencyBucket(e.LatencyMS)}]++
+	}
+
+	return nil
+}
+
+func latencyBucket(ms int64) int {
+	for _, le := range LatencyBuckets {
+		if ms <= int64(le) {
+			return le
+		}
+	}
+	return LatencyInf
+}
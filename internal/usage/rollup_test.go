@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRollupsAdd(t *testing.T) {
+	r := NewRollups()
+
+	events := []middleware.UsageEvent{
+		{EventID: "a", APIKeyID: "3", Path: "/v1/items", StatusCode: 200, BytesOut: 100, LatencyMS: 12, Timestamp: "2026-03-10T14:05:00Z"},
+		{EventID: "b", APIKeyID: "3", Path: "/v1/items", StatusCode: 200, BytesOut: 50, LatencyMS: 40, Timestamp: "2026-03-10T14:59:59Z"},
+		{EventID: "c", APIKeyID: "3", Path: "/v1/items", StatusCode: 200, BytesOut: 10, LatencyMS: 3, Timestamp: "2026-03-10T15:00:00Z"},
+		{EventID: "d", APIKeyID: "3", Path: "/v1/items", StatusCode: 500, LatencyMS: 20000, Timestamp: "2026-03-10T15:10:00Z"},
+	}
+	for _, e := range events {
+		if err := r.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hour := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	got := r.counts[rollupKey{GranularityHour, hour, 3, "/v1/items", 200}]
+	if got == nil || got.Requests != 2 || got.BytesOut != 150 || got.LatencyMSSum != 52 || got.LatencyMSMax != 40 {
+		t.Fatalf("unexpected hourly rollup: %+v", got)
+	}
+
+	got = r.counts[rollupKey{GranularityDay, day, 3, "/v1/items", 200}]
+	if got == nil || got.Requests != 3 {
+		t.Fatalf("expected 3 successful requests in the daily rollup, got %+v", got)
+	}
+
+	if n := r.latency[latencyKey{GranularityDay, day, 3, "/v1/items", 50}]; n != 1 {
+		t.Fatalf("expected one request in the 50ms bucket, got %d", n)
+	}
+	if n := r.latency[latencyKey{GranularityDay, day, 3, "/v1/items", LatencyInf}]; n != 1 {
+		t.Fatalf("expected one request in the +Inf bucket, got %d", n)
+	}
+}
+
+func TestDecodeEventRejectsInvalidPayloads(t *testing.T) {
+	cases := map[string]any{
+		"missing payload": nil,
+		"invalid json":    "{",
+		"missing id":      `{"api_key_id":"1","timestamp":"2026-03-10T14:05:00Z"}`,
+		"invalid key":     `{"event_id":"x","api_key_id":"abc","timestamp":"2026-03-10T14:05:00Z"}`,
+		"invalid time":    `{"event_id":"x","api_key_id":"1","timestamp":"yesterday"}`,
+	}
+
+	for name, payload := range cases {
+		m := redis.XMessage{ID: "1-0", Values: map[string]interface{}{}}
+		if payload != nil {
+			m.Values["payload"] = payload
+		}
+		if _, err := decodeEvent(m); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	m := redis.XMessage{ID: "1-0", Values: map[string]interface{}{
+		"payload": `{"event_id":"x","api_key_id":"1","timestamp":"2026-03-10T14:05:00Z","status_code":201}`,
+	}}
+	e, err := decodeEvent(m)
+	if err != nil || e.StatusCode != 201 {
+		t.Fatalf("expected valid event, got %+v (%v)", e, err)
+	}
+}
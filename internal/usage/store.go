@@ -0,0 +1,128 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/martinsdevv/aegis/internal/gateway/middleware"
+)
+
+// Store grava os rollups de uso no Postgres
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Apply agrega os eventos ainda não vistos numa única transação e devolve
+// quantos foram aplicados; reentregas do stream são ignoradas pelo event_id
+func (s *Store) Apply(ctx context.Context, events []middleware.UsageEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.EventID)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO usage_events_seen (event_id)
+		SELECT unnest($1::text[])
+		ON CONFLICT (event_id) DO NOTHING
+		RETURNING event_id
+	`, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	fresh := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		fresh[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rollups := NewRollups()
+	applied := 0
+	for _, e := range events {
+		if !fresh[e.EventID] {
+			continue
+		}
+		// Duplicado dentro do mesmo lote conta uma vez só
+		delete(fresh, e.EventID)
+
+		if err := rollups.Add(e); err != nil {
+			return 0, err
+		}
+		applied++
+	}
+
+	if err := s.write(ctx, tx, rollups); err != nil {
+		return 0, err
+	}
+
+	return applied, tx.Commit()
+}
+
+func (s *Store) write(ctx context.Context, tx *sql.Tx, r *Rollups) error {
+	for k, v := range r.counts {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO usage_rollups (
+				granularity, bucket, api_key_id, path, status_code,
+				requests, bytes_out, latency_ms_sum, latency_ms_max
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (granularity, bucket, api_key_id, path, status_code) DO UPDATE SET
+				requests = usage_rollups.requests + EXCLUDED.requests,
+				bytes_out = usage_rollups.bytes_out + EXCLUDED.bytes_out,
+				latency_ms_sum = usage_rollups.latency_ms_sum + EXCLUDED.latency_ms_sum,
+				latency_ms_max = GREATEST(usage_rollups.latency_ms_max, EXCLUDED.latency_ms_max)
+		`, k.Granularity, k.Bucket, k.APIKeyID, k.Path, k.StatusCode,
+			v.Requests, v.BytesOut, v.LatencyMSSum, v.LatencyMSMax)
+		if err != nil {
+			return err
+		}
+	}
+
+	for k, n := range r.latency {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO usage_latency_rollups (granularity, bucket, api_key_id, path, le_ms, requests)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (granularity, bucket, api_key_id, path, le_ms) DO UPDATE SET
+				requests = usage_latency_rollups.requests + EXCLUDED.requests
+		`, k.Granularity, k.Bucket, k.APIKeyID, k.Path, k.LeMS, n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PruneSeen remove os event_id antigos; reentregas acontecem em minutos, não dias
+func (s *Store) PruneSeen(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM usage_events_seen WHERE seen_at < NOW() - make_interval(secs => $1)
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}